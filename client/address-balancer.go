@@ -0,0 +1,65 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// failedAddressCooldown is how long an address is skipped after a failed call.
+const failedAddressCooldown = 10 * time.Second
+
+// addressBalancer spreads calls across several server addresses in round-robin order,
+// skipping addresses that failed recently.
+type addressBalancer struct {
+	// addresses lists the server addresses
+	addresses []string
+	// lock guards next and failedAt
+	lock sync.Mutex
+	// next is the index of the next address to use
+	next int
+	// failedAt records when each address last failed
+	failedAt map[string]time.Time
+}
+
+// newAddressBalancer creates a balancer over the given addresses.
+func newAddressBalancer(addresses []string) *addressBalancer {
+	return &addressBalancer{
+		addresses: addresses,
+		failedAt:  make(map[string]time.Time),
+	}
+}
+
+// pick returns the next address in round-robin order that hasn't failed within the cooldown.
+// When every address failed recently, the next one is returned anyway.
+func (balancer *addressBalancer) pick() string {
+	balancer.lock.Lock()
+	defer balancer.lock.Unlock()
+
+	count := len(balancer.addresses)
+	for i := 0; i < count; i++ {
+		address := balancer.addresses[(balancer.next+i)%count]
+		failedAt, failed := balancer.failedAt[address]
+		if !failed || time.Since(failedAt) > failedAddressCooldown {
+			balancer.next = (balancer.next + i + 1) % count
+			return address
+		}
+	}
+
+	address := balancer.addresses[balancer.next]
+	balancer.next = (balancer.next + 1) % count
+	return address
+}
+
+// markFailed records a failed call to the address, so it's skipped during the cooldown.
+func (balancer *addressBalancer) markFailed(address string) {
+	balancer.lock.Lock()
+	defer balancer.lock.Unlock()
+	balancer.failedAt[address] = time.Now()
+}
+
+// markSucceeded clears the failure of the address after a successful call.
+func (balancer *addressBalancer) markSucceeded(address string) {
+	balancer.lock.Lock()
+	defer balancer.lock.Unlock()
+	delete(balancer.failedAt, address)
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// ClientStats is a snapshot of the cumulative stats of a RestClient, e.g. for dashboards.
+type ClientStats struct {
+	// Requests is the number of requests made, including the ones served from the cache
+	Requests int64
+	// Successes is the number of requests that got a response
+	Successes int64
+	// Failures is the number of requests that failed after all attempts
+	Failures int64
+	// Retries is the number of retry attempts across all requests
+	Retries int64
+	// AverageLatency is the average total duration of the requests, including retries
+	AverageLatency time.Duration
+}
+
+// clientStats accumulates the stats of the requests of a client, safe for concurrent use.
+type clientStats struct {
+	// lock guards the counters
+	lock sync.Mutex
+	// requests, successes, failures and retries count the requests by outcome
+	requests, successes, failures, retries int64
+	// totalLatency is the sum of the request durations
+	totalLatency time.Duration
+}
+
+// record adds the outcome of a request, described by its log entry, to the stats.
+func (stats *clientStats) record(logEntry *RequestLogEntry, start time.Time) {
+	latency := time.Since(start)
+
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	stats.requests++
+	if logEntry.Status == "SUCCESS" {
+		stats.successes++
+	} else {
+		stats.failures++
+	}
+	stats.retries += int64(logEntry.RetryCount)
+	stats.totalLatency += latency
+}
+
+// snapshot returns the current stats.
+func (stats *clientStats) snapshot() ClientStats {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	snapshot := ClientStats{
+		Requests:  stats.requests,
+		Successes: stats.successes,
+		Failures:  stats.failures,
+		Retries:   stats.retries,
+	}
+	if stats.requests > 0 {
+		snapshot.AverageLatency = stats.totalLatency / time.Duration(stats.requests)
+	}
+	return snapshot
+}
+
+// reset clears the stats.
+func (stats *clientStats) reset() {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	stats.requests, stats.successes, stats.failures, stats.retries = 0, 0, 0, 0
+	stats.totalLatency = 0
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"strconv"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// concurrencyLimit caps the number of requests in flight, providing backpressure to the callers.
+type concurrencyLimit struct {
+	// slots holds a token for every request in flight
+	slots chan struct{}
+	// failFast when true, rejects requests with a CLIENT_BUSY error instead of waiting for a free slot
+	failFast bool
+}
+
+// newConcurrencyLimit creates a limit of max requests in flight.
+func newConcurrencyLimit(max int, failFast bool) *concurrencyLimit {
+	return &concurrencyLimit{
+		slots:    make(chan struct{}, max),
+		failFast: failFast,
+	}
+}
+
+// acquire takes a slot for a request, blocking until one is free, or returns a CLIENT_BUSY error
+// right away when all slots are taken and the limit fails fast.
+func (limit *concurrencyLimit) acquire() error {
+	if !limit.failFast {
+		limit.slots <- struct{}{}
+		return nil
+	}
+	select {
+	case limit.slots <- struct{}{}:
+		return nil
+	default:
+		return common.NewError("CLIENT_BUSY", "client has reached its maximum of "+strconv.Itoa(cap(limit.slots))+" concurrent requests")
+	}
+}
+
+// release frees the slot taken by a request.
+func (limit *concurrencyLimit) release() {
+	<-limit.slots
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// agedConn is a connection remembering when it was opened.
+type agedConn struct {
+	net.Conn
+	// createdAt is when the connection was dialed
+	createdAt time.Time
+}
+
+// connAgeTransport retires connections older than maxAge: the next request sent over such
+// a connection asks the server to close it, so the following requests open a new one.
+// This spreads long-lived clients across the instances behind a load balancer.
+type connAgeTransport struct {
+	// base is the wrapped transport, dialing agedConns
+	base *http.Transport
+	// maxAge is the age after which connections are retired
+	maxAge time.Duration
+}
+
+// newConnAgeTransport wraps the transport, tracking the creation time of the connections it dials.
+func newConnAgeTransport(base *http.Transport, maxAge time.Duration) *connAgeTransport {
+	dial := base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	base.DialContext = func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &agedConn{Conn: conn, createdAt: time.Now()}, nil
+	}
+	return &connAgeTransport{base: base, maxAge: maxAge}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *connAgeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var traced *http.Request
+	trace := &httptrace.ClientTrace{
+		// called before the request is written, so marking it Close sends "Connection: close"
+		// and keeps the transport from reusing the connection afterwards
+		GotConn: func(info httptrace.GotConnInfo) {
+			if t.expired(info.Conn) {
+				traced.Close = true
+			}
+		},
+	}
+	// the traced request is a copy, so the caller's request isn't modified
+	traced = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return t.base.RoundTrip(traced)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport.
+func (t *connAgeTransport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// expired reports whether the connection is older than the maximum age.
+func (t *connAgeTransport) expired(conn net.Conn) bool {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	aged, ok := conn.(*agedConn)
+	return ok && time.Since(aged.createdAt) >= t.maxAge
+}
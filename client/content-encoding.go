@@ -0,0 +1,51 @@
+package client
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+)
+
+// responseDecoder returns a reader decompressing a response body.
+type responseDecoder func(content []byte) (io.ReadCloser, error)
+
+// responseDecoders are the content codings the client can decode, in order of preference.
+// Brotli (br) isn't part of the standard library, so it isn't advertised.
+var responseDecoders = []struct {
+	encoding string
+	decode   responseDecoder
+}{
+	{"gzip", func(content []byte) (io.ReadCloser, error) {
+		return gzip.NewReader(bytes.NewReader(content))
+	}},
+	{"deflate", func(content []byte) (io.ReadCloser, error) {
+		// deflate is meant to be zlib-wrapped, but some servers send raw deflate data
+		if zr, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+			return zr, nil
+		}
+		return flate.NewReader(bytes.NewReader(content)), nil
+	}},
+}
+
+// acceptEncoding is the Accept-Encoding header value advertising every supported decoder.
+var acceptEncoding = func() string {
+	encodings := make([]string, len(responseDecoders))
+	for i, decoder := range responseDecoders {
+		encodings[i] = decoder.encoding
+	}
+	return strings.Join(encodings, ", ")
+}()
+
+// findDecoder returns the decoder of the content coding, or nil when it isn't supported.
+func findDecoder(encoding string) responseDecoder {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	for _, decoder := range responseDecoders {
+		if decoder.encoding == encoding {
+			return decoder.decode
+		}
+	}
+	return nil
+}
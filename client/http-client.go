@@ -4,18 +4,20 @@ package client
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	sdk "github.com/phnam/go-protocol-adapter"
 	"github.com/phnam/go-protocol-adapter/common"
 	"github.com/phnam/go-protocol-adapter/request"
 )
@@ -38,13 +40,43 @@ type RestClient[T any] struct {
 	timeOut time.Duration
 	// errorLogOnly when true, only logs errors and not successful requests
 	errorLogOnly bool
-	// logExpiration defines how long logs should be kept
+	// slowRequestThreshold when set, only logs errors and requests slower than it
+	slowRequestThreshold time.Duration
+	// logExpiration defines how long logs are kept for RecentLogs, no logs are kept when nil
 	logExpiration *time.Duration
+	// recentLogs holds the recent request log entries when logExpiration is set
+	recentLogs *logBuffer
 
 	// debug enables debug logging when true
 	debug bool
 	// acceptHttpError when true, treats HTTP error codes as valid responses
 	acceptHttpError bool
+	// onRetryExhausted is called with the request log entry when all retry attempts failed
+	onRetryExhausted func(entry *RequestLogEntry)
+	// disableResponseCompression when true, doesn't ask for compressed responses
+	disableResponseCompression bool
+	// compressRequestBody when true, gzips request bodies that aren't already encoded
+	compressRequestBody bool
+	// signer signs each request once its body and headers are set
+	signer RequestSigner
+	// maxElapsedTime caps the total time spent on a request across all attempts and waits (no limit when 0)
+	maxElapsedTime time.Duration
+	// retryOnErrorCodes lists the response error codes worth retrying
+	retryOnErrorCodes map[string]bool
+	// maxResponseBodySize caps the size in bytes of response bodies read (no limit when 0)
+	maxResponseBodySize int64
+	// balancer spreads requests across several base URLs when multiple addresses are configured
+	balancer *addressBalancer
+	// cache holds responses of GET/HEAD requests when response caching is enabled
+	cache *responseCache
+	// stats accumulates the outcome of the requests
+	stats clientStats
+	// redaction masks secrets in the emitted request logs
+	redaction redaction
+	// marshaler encodes JSON request bodies and decodes JSON responses, encoding/json is used when nil
+	marshaler common.Marshaler
+	// concurrency caps the number of requests in flight, no limit when nil
+	concurrency *concurrencyLimit
 }
 
 // RequestLogEntry represents a log entry for an API request with all relevant information.
@@ -74,6 +106,8 @@ type RequestLogEntry struct {
 	ErrorLog *string `json:"errorLog,omitempty" bson:"error_log,omitempty"`
 	// Keys contains any associated keys for the request
 	Keys *[]string `json:"keys,omitempty" bson:"keys,omitempty"`
+	// Metadata contains caller-provided attributes of the request (e.g. tenant, user id) for indexing logs
+	Metadata map[string]string `json:"metadata,omitempty" bson:"metadata,omitempty"`
 	// Date is the timestamp when the request was made
 	Date *time.Time `json:"date,omitempty" bson:"date,omitempty"`
 }
@@ -92,6 +126,22 @@ type CallResult struct {
 	ErrorLog *string `json:"errorLog,omitempty" bson:"error_log,omitempty"`
 }
 
+// RetryError is returned when every attempt of a request failed.
+// It carries the result of each attempt, so callers can inspect their status codes and bodies.
+type RetryError struct {
+	// URL is the requested URL
+	URL string
+	// Attempts contains the results of each failed attempt
+	Attempts []*CallResult
+	// message is the error message
+	message string
+}
+
+// Error implements the error interface.
+func (e *RetryError) Error() string {
+	return e.message
+}
+
 // RestResult represents the result of a REST API call.
 type RestResult struct {
 	// Body is the response body as a string
@@ -100,8 +150,16 @@ type RestResult struct {
 	Content []byte `json:"content,omitempty" bson:"content,omitempty"`
 	// Code is the HTTP status code
 	Code int `json:"code,omitempty" bson:"code,omitempty"`
+	// ContentType is the Content-Type header of the response
+	ContentType string `json:"content_type,omitempty" bson:"content_type,omitempty"`
+	// NextCursor is the X-Next-Cursor header of the response, the cursor of the next page of a paginated result
+	NextCursor string `json:"next_cursor,omitempty" bson:"next_cursor,omitempty"`
 }
 
+// RawBody is a request body sent verbatim rather than encoded as JSON, e.g. content already encoded
+// or compressed by the caller. Its Content-Type is application/octet-stream unless set in the headers.
+type RawBody []byte
+
 // HTTPMethod is a type representing HTTP methods as strings.
 type HTTPMethod string
 
@@ -123,19 +181,25 @@ type HTTPMethodEnum struct {
 	Delete HTTPMethod
 	// Option represents the HTTP OPTION method
 	Option HTTPMethod
+	// Trace represents the HTTP TRACE method
+	Trace HTTPMethod
+	// Connect represents the HTTP CONNECT method
+	Connect HTTPMethod
 }
 
 // HTTPMethods is a global variable containing all supported HTTP methods.
 // It provides easy access to HTTP method constants throughout the application.
 var HTTPMethods = &HTTPMethodEnum{
-	Get:    "GET",
-	Query:  "QUERY",
-	Post:   "POST",
-	Put:    "PUT",
-	Patch:  "PATCH",
-	Head:   "HEAD",
-	Delete: "DELETE",
-	Option: "OPTION",
+	Get:     "GET",
+	Query:   "QUERY",
+	Post:    "POST",
+	Put:     "PUT",
+	Patch:   "PATCH",
+	Head:    "HEAD",
+	Delete:  "DELETE",
+	Option:  "OPTION",
+	Trace:   "TRACE",
+	Connect: "CONNECT",
 }
 
 // NewHTTPClient creates a new HTTP client based on the provided configuration.
@@ -149,8 +213,32 @@ var HTTPMethods = &HTTPMethodEnum{
 func NewHTTPClient[T any](config *APIClientConfiguration) APIClient[T] {
 	var restCl RestClient[T]
 
+	// Unix socket addresses are replaced with placeholder hosts, dialed to their socket
+	sockets := make(map[string]string)
+	httpAddress := func(address string) string {
+		if path, ok := unixSocketPath(address); ok {
+			host := "unix-" + strconv.Itoa(len(sockets))
+			sockets[host+":80"] = path
+			return "http://" + host
+		}
+		return address
+	}
+
 	// Ensure the base URL has the http prefix
-	baseURL := config.Address
+	baseURL := httpAddress(config.Address)
+	if len(config.Addresses) > 0 {
+		// spread requests across the addresses, the first one is the default base URL
+		baseURLs := make([]string, len(config.Addresses))
+		for i, address := range config.Addresses {
+			address = httpAddress(address)
+			if !strings.HasPrefix(address, "http") {
+				address = "http://" + address
+			}
+			baseURLs[i] = address
+		}
+		baseURL = baseURLs[0]
+		restCl.balancer = newAddressBalancer(baseURLs)
+	}
 	if !strings.HasPrefix(baseURL, "http") {
 		baseURL = "http://" + baseURL
 	}
@@ -161,9 +249,26 @@ func NewHTTPClient[T any](config *APIClientConfiguration) APIClient[T] {
 		restCl.BaseURL = u
 	}
 
-	// Create transport with TLS configuration that skips certificate verification
+	// Create transport with TLS configuration that skips certificate verification,
+	// failing connection attempts after the connect timeout
+	dialer := &net.Dialer{
+		Timeout:   config.connectTimeout(),
+		KeepAlive: 30 * time.Second,
+	}
+	dial := dialer.DialContext
+	if config.DialContext != nil {
+		dial = config.DialContext
+	}
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		// Accept-Encoding is set and decoded by the client
+		DisableCompression: true,
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			if path, ok := sockets[address]; ok {
+				return dialer.DialContext(ctx, "unix", path)
+			}
+			return dial(ctx, network, address)
+		},
 	}
 
 	// Initialize the HTTP client with the transport and timeout
@@ -178,6 +283,21 @@ func NewHTTPClient[T any](config *APIClientConfiguration) APIClient[T] {
 	restCl.SetTimeout(config.Timeout)
 	restCl.debug = false
 	restCl.errorLogOnly = config.ErrorLogOnly
+	restCl.SetSlowRequestThreshold(config.SlowRequestThreshold)
+	restCl.SetLogExpiration(config.LogExpiration)
+	restCl.onRetryExhausted = config.OnRetryExhausted
+	restCl.compressRequestBody = config.CompressRequestBody
+	restCl.SetDisableResponseCompression(config.DisableResponseCompression)
+	restCl.signer = config.RequestSigner
+	restCl.SetResponseCache(config.ResponseCache)
+	restCl.SetMaxElapsedTime(config.MaxElapsedTime)
+	restCl.SetMaxResponseBodySize(config.MaxResponseBodySize)
+	restCl.SetRetryOnErrorCodes(config.RetryOnErrorCodes)
+	restCl.SetMaxConnAge(config.MaxConnAge)
+	restCl.SetRedactFields(config.RedactFields)
+	restCl.SetRedactHeaders(config.RedactHeaders)
+	restCl.SetMarshaler(config.Marshaler)
+	restCl.SetMaxConcurrentRequests(config.MaxConcurrentRequests, config.FailFastWhenBusy)
 	return &restCl
 }
 
@@ -226,6 +346,8 @@ func NewRESTClientWithProxy[T any](baseURL string, logName string, proxyUrl stri
 	// Create transport with TLS configuration that skips certificate verification
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		// Accept-Encoding is set and decoded by the client
+		DisableCompression: true,
 	}
 
 	// Configure proxy if provided
@@ -256,16 +378,73 @@ func NewRESTClientWithProxy[T any](baseURL string, logName string, proxyUrl stri
 // Parameters:
 //   - baseURL: The base URL to append parameters to
 //   - params: A map of parameter names to values
+//   - multiParams: A map of parameter names to repeated values, merged with params
+//
+// Returns:
+//   - The URL with query parameters appended, unchanged without parameters so the request URI
+//     signed by a RequestSigner is the path the server sees
+func addParams(baseURL string, params map[string]string, multiParams map[string][]string) string {
+	query := mergeParams(params, multiParams).Encode()
+	if query == "" {
+		return baseURL
+	}
+	return baseURL + "?" + query
+}
+
+// mergeParams builds the query values from single and repeated parameters.
+//
+// Parameters:
+//   - params: A map of parameter names to values
+//   - multiParams: A map of parameter names to repeated values
 //
 // Returns:
-//   - The URL with query parameters appended
-func addParams(baseURL string, params map[string]string) string {
-	baseURL += "?"
+//   - The merged query values, keys present in both maps keep every value
+func mergeParams(params map[string]string, multiParams map[string][]string) url.Values {
 	p := url.Values{}
 	for key, value := range params {
 		p.Add(key, value)
 	}
-	return baseURL + p.Encode()
+	for key, values := range multiParams {
+		for _, value := range values {
+			p.Add(key, value)
+		}
+	}
+	return p
+}
+
+// getHeader returns the value of a header from a header map, matching the name case-insensitively.
+//
+// Parameters:
+//   - headers: The header map to search
+//   - name: The header name
+//
+// Returns:
+//   - The header value, or an empty string if the header is not set
+func getHeader(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// lastCallError describes the failure of the last attempt recorded in the log entry.
+//
+// Parameters:
+//   - logEntry: The request log entry
+//
+// Returns:
+//   - The error of the last attempt, or its HTTP status code when it got a response
+func lastCallError(logEntry *RequestLogEntry) string {
+	if len(logEntry.Results) == 0 {
+		return "no attempt made"
+	}
+	last := logEntry.Results[len(logEntry.Results)-1]
+	if last.ErrorLog != nil {
+		return *last.ErrorLog
+	}
+	return "HTTP status " + strconv.Itoa(last.RespCode)
 }
 
 // addResult adds a CallResult to the RequestLogEntry's Results slice.
@@ -317,12 +496,250 @@ func (c *RestClient[T]) SetMaxRetryTime(maxRetryTime int) {
 	c.maxRetryTime = maxRetryTime
 }
 
+// SetOnRetryExhausted sets the callback invoked when all retry attempts of a request failed.
+// The callback receives the full request log entry, including the results of every attempt,
+// so failed requests can be persisted for later replay (dead-letter processing).
+// The configured fields and headers are redacted from the entry (see SetRedactFields).
+//
+// Parameters:
+//   - fn: The callback to invoke, or nil to disable it
+func (c *RestClient[T]) SetOnRetryExhausted(fn func(entry *RequestLogEntry)) {
+	c.onRetryExhausted = fn
+}
+
+// SetMarshaler sets the JSON marshaler encoding request bodies and decoding responses,
+// e.g. backed by jsoniter or sonic for large payloads.
+//
+// Parameters:
+//   - marshaler: The marshaler to use, or nil for encoding/json
+func (c *RestClient[T]) SetMarshaler(marshaler common.Marshaler) {
+	c.marshaler = marshaler
+}
+
+// SetRedactFields sets the JSON fields (at any depth) and form params masked with "***" in the
+// emitted request logs, e.g. password or token. Names are matched case-insensitively.
+//
+// Parameters:
+//   - fields: The names of the fields to mask, or nil to mask none
+func (c *RestClient[T]) SetRedactFields(fields []string) {
+	c.redaction.fields = redactionSet(fields, strings.ToLower)
+}
+
+// SetRedactHeaders sets the request and response headers masked with "***" in the emitted request logs.
+//
+// Parameters:
+//   - headers: The names of the headers to mask, nil for DefaultRedactHeaders (Authorization)
+//     or an empty list to mask none
+func (c *RestClient[T]) SetRedactHeaders(headers []string) {
+	c.redaction.headers = redactionSet(headers, http.CanonicalHeaderKey)
+}
+
+// SetDisableResponseCompression configures whether responses are requested uncompressed.
+// By default the Accept-Encoding header advertises every encoding the client can decode (gzip, deflate);
+// disabling it omits the header, for servers or debugging proxies that choke on compressed bodies.
+//
+// Parameters:
+//   - disable: When true, the Accept-Encoding header isn't sent
+func (c *RestClient[T]) SetDisableResponseCompression(disable bool) {
+	c.disableResponseCompression = disable
+}
+
+// SetCompressRequestBody configures whether request bodies are gzip-compressed.
+// Bodies of requests that already carry a Content-Encoding header are sent as-is.
+//
+// Parameters:
+//   - compress: When true, request bodies are compressed with gzip
+func (c *RestClient[T]) SetCompressRequestBody(compress bool) {
+	c.compressRequestBody = compress
+}
+
+// SetRequestSigner sets the signer applied to every request, e.g. an HMACSigner.
+// Requests are signed after the body is built, so the signature covers the exact bytes sent.
+// An HMAC over the body needs it whole: JSON and RawBody bodies are already in memory, but
+// streamed io.Reader bodies aren't buffered. HMACSigner rejects them unless UnsignedStreams is set.
+//
+// Parameters:
+//   - signer: The request signer, or nil to disable signing
+func (c *RestClient[T]) SetRequestSigner(signer RequestSigner) {
+	c.signer = signer
+}
+
+// SetMaxElapsedTime caps the total time spent on a request, including retries and waits between them.
+// No further attempt is made once the next one would start after the budget, and the last error is returned.
+//
+// Parameters:
+//   - maxElapsedTime: The maximum total duration, or 0 for no limit
+func (c *RestClient[T]) SetMaxElapsedTime(maxElapsedTime time.Duration) {
+	c.maxElapsedTime = maxElapsedTime
+}
+
+// SetMaxResponseBodySize caps the size of response bodies, protecting the client from huge responses.
+// Requests whose response is larger fail with a RESPONSE_TOO_LARGE error and aren't retried.
+// For compressed responses, the limit applies to both the compressed and the decompressed body.
+//
+// Parameters:
+//   - maxResponseBodySize: The maximum body size in bytes, or 0 for no limit
+func (c *RestClient[T]) SetMaxResponseBodySize(maxResponseBodySize int64) {
+	c.maxResponseBodySize = maxResponseBodySize
+}
+
+// SetMaxConnAge retires connections older than the given age: the next request sent over such a
+// connection closes it, so the following requests open a new one. Long-lived clients behind a load
+// balancer are then periodically rebalanced across backend instances.
+//
+// Parameters:
+//   - maxConnAge: The maximum connection age, or 0 to keep connections open
+func (c *RestClient[T]) SetMaxConnAge(maxConnAge time.Duration) {
+	switch tr := c.httpClient.Transport.(type) {
+	case *connAgeTransport:
+		if maxConnAge > 0 {
+			tr.maxAge = maxConnAge
+		} else {
+			c.httpClient.Transport = tr.base
+		}
+	case *http.Transport:
+		if maxConnAge > 0 {
+			c.httpClient.Transport = newConnAgeTransport(tr, maxConnAge)
+		}
+	}
+}
+
+// SetMaxConcurrentRequests caps the number of requests in flight, so a client shared by many goroutines
+// doesn't flood the upstream. Requests over the limit wait for a free slot, or fail right away with a
+// CLIENT_BUSY error when failFast is true. Responses served from the cache don't take a slot.
+// Requests already in flight keep counting against the previous limit.
+//
+// Parameters:
+//   - maxConcurrentRequests: The maximum number of requests in flight, or 0 for no limit
+//   - failFast: Whether requests over the limit fail instead of waiting
+func (c *RestClient[T]) SetMaxConcurrentRequests(maxConcurrentRequests int, failFast bool) {
+	if maxConcurrentRequests <= 0 {
+		c.concurrency = nil
+		return
+	}
+	c.concurrency = newConcurrencyLimit(maxConcurrentRequests, failFast)
+}
+
+// SetLogExpiration keeps the log entries of the requests made within the duration in memory, e.g. to expose
+// the recent failures of the client. Up to the last 1000 entries are kept, redacted like the emitted logs.
+// When ErrorLogOnly is set, only failed requests are kept.
+//
+// Parameters:
+//   - logExpiration: How long entries are kept, or 0 to keep none
+func (c *RestClient[T]) SetLogExpiration(logExpiration time.Duration) {
+	if logExpiration <= 0 {
+		c.logExpiration = nil
+		c.recentLogs = nil
+		return
+	}
+	c.logExpiration = &logExpiration
+	if c.recentLogs == nil {
+		c.recentLogs = newLogBuffer(recentLogsCapacity)
+	}
+}
+
+// RecentLogs returns the log entries of the requests completed within the log expiration, oldest first.
+// Older entries are evicted. It returns nil when no log expiration is set.
+//
+// Returns:
+//   - The recent log entries
+func (c *RestClient[T]) RecentLogs() []*RequestLogEntry {
+	buf, expiration := c.recentLogs, c.logExpiration
+	if buf == nil || expiration == nil {
+		return nil
+	}
+	return buf.recent(*expiration)
+}
+
+// keepLog adds the entry of a completed request to the recent logs, when they're enabled.
+func (c *RestClient[T]) keepLog(logEntry *RequestLogEntry) {
+	buf := c.recentLogs
+	if buf == nil || !c.shouldLog(logEntry) {
+		return
+	}
+	buf.add(c.redaction.entry(logEntry))
+}
+
+// SetSlowRequestThreshold only logs the successful requests slower than the threshold, errors being always logged.
+// It takes precedence over errorLogOnly for slow requests: those are logged even when errorLogOnly is set.
+//
+// Parameters:
+//   - threshold: The minimum total time of the logged successful requests, or 0 to follow errorLogOnly
+func (c *RestClient[T]) SetSlowRequestThreshold(threshold time.Duration) {
+	c.slowRequestThreshold = threshold
+}
+
+// shouldLog reports whether the entry of a completed request is logged: errors always are,
+// successful requests when they're slower than the slow request threshold, or unless errorLogOnly is set.
+func (c *RestClient[T]) shouldLog(logEntry *RequestLogEntry) bool {
+	if logEntry.Status != "SUCCESS" {
+		return true
+	}
+	if c.slowRequestThreshold > 0 {
+		return time.Duration(logEntry.TotalTime)*time.Millisecond > c.slowRequestThreshold
+	}
+	return !c.errorLogOnly
+}
+
+// Stats returns the cumulative stats of the requests made by the client since it was created
+// or since the last ResetStats: request count, successes, failures, retries and average latency.
+//
+// Returns:
+//   - A snapshot of the stats
+func (c *RestClient[T]) Stats() ClientStats {
+	return c.stats.snapshot()
+}
+
+// ResetStats clears the cumulative stats of the client.
+func (c *RestClient[T]) ResetStats() {
+	c.stats.reset()
+}
+
+// SetRetryOnErrorCodes sets the response error codes worth retrying, e.g. OVERLOAD or SERVER_BUSY.
+// Responses carrying one of them are retried like failed calls: they share the maximum number of retries,
+// the wait between attempts and the MaxElapsedTime budget. Once retries are exhausted, the last response is returned.
+//
+// Parameters:
+//   - codes: The retryable error codes
+func (c *RestClient[T]) SetRetryOnErrorCodes(codes []string) {
+	c.retryOnErrorCodes = errorCodeSet(codes)
+}
+
+// SetResponseCache enables an in-memory LRU cache for GET and HEAD responses.
+// Responses are cached according to their Cache-Control directives, or the configured default TTL.
+// Entries are keyed by method and URL only, so requests carrying an Authorization or Cookie header
+// bypass the cache: a client shared by several callers never serves one caller's response to another.
+//
+// Parameters:
+//   - config: The cache configuration, or nil to disable caching
+func (c *RestClient[T]) SetResponseCache(config *ResponseCacheConfig) {
+	if config == nil {
+		c.cache = nil
+		return
+	}
+	c.cache = newResponseCache(config)
+}
+
+// CacheStats returns the number of requests served from the response cache and the number of cache misses.
+//
+// Returns:
+//   - The number of cache hits
+//   - The number of cache misses
+func (c *RestClient[T]) CacheStats() (hits int64, misses int64) {
+	if c.cache == nil {
+		return 0, 0
+	}
+	return c.cache.hits.Load(), c.cache.misses.Load()
+}
+
 // initRequest creates and initializes an HTTP request with the specified parameters.
 //
 // Parameters:
+//   - baseURL: The base URL the path is appended to
 //   - method: The HTTP method to use
 //   - headers: HTTP headers to include in the request
 //   - params: Query parameters to include in the URL
+//   - multiParams: Repeated query parameters to include in the URL
 //   - body: The request body (for POST, PUT, etc.)
 //   - path: The path to append to the base URL
 //   - userAgent: The User-Agent header value
@@ -330,41 +747,62 @@ func (c *RestClient[T]) SetMaxRetryTime(maxRetryTime int) {
 // Returns:
 //   - A pointer to an http.Request
 //   - An error if request creation fails
-func (c *RestClient[T]) initRequest(method HTTPMethod, headers map[string]string, params map[string]string, body interface{}, path string, userAgent string) (*http.Request, error) {
+func (c *RestClient[T]) initRequest(baseURL *url.URL, method HTTPMethod, headers map[string]string, params map[string]string, multiParams map[string][]string, body interface{}, path string, userAgent string) (*http.Request, error) {
 
 	// Construct the full URL by combining base URL and path
-	urlStr := c.BaseURL.String()
-	if path != "" {
-		if strings.HasSuffix(urlStr, "/") || strings.HasPrefix(path, "/") {
-			urlStr += path
-		} else {
-			urlStr += "/" + path
-		}
+	urlStr := buildURL(baseURL, path)
+
+	// an io.Reader body is streamed as-is (chunked), without being buffered in memory
+	if reader, ok := body.(io.Reader); ok {
+		return c.initStreamRequest(method, urlStr, headers, params, multiParams, reader, userAgent)
 	}
 
-	// Prepare the request body if provided
+	// Prepare the request body if provided, RawBody is sent verbatim
 	var buf io.ReadWriter
+	raw, isRaw := body.(RawBody)
 	if body != nil {
 		buf = new(bytes.Buffer)
-		err := json.NewEncoder(buf).Encode(body)
-		if err != nil {
+		if isRaw {
+			buf.Write(raw)
+		} else if c.marshaler != nil {
+			encoded, err := c.marshaler.Marshal(body)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(encoded)
+		} else {
+			err := json.NewEncoder(buf).Encode(body)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Compress the body unless the caller already encoded it (e.g. pre-gzipped content)
+	compressed := false
+	if buf != nil && c.compressRequestBody && getHeader(headers, "Content-Encoding") == "" {
+		gzBuf := new(bytes.Buffer)
+		gw := gzip.NewWriter(gzBuf)
+		if _, err := io.Copy(gw, buf); err != nil {
 			return nil, err
 		}
+		if err := gw.Close(); err != nil {
+			return nil, err
+		}
+		buf = gzBuf
+		compressed = true
 	}
 
 	var err error
 	var req *http.Request
 
 	// Handle form-encoded POST requests differently
-	if method == HTTPMethods.Post && headers != nil && headers["Content-Type"] == "application/x-www-form-urlencoded" && params != nil && len(params) > 0 {
-		data := url.Values{}
-		for key, val := range params {
-			data.Set(key, val)
-		}
+	if method == HTTPMethods.Post && headers != nil && headers["Content-Type"] == "application/x-www-form-urlencoded" && (len(params) > 0 || len(multiParams) > 0) {
+		data := mergeParams(params, multiParams)
 		req, err = http.NewRequest(string(method), urlStr, strings.NewReader(data.Encode()))
 	} else {
 		// For other requests, add params to the URL
-		urlStr = addParams(urlStr, params)
+		urlStr = addParams(urlStr, params, multiParams)
 		req, err = http.NewRequest(string(method), urlStr, buf)
 	}
 
@@ -373,11 +811,55 @@ func (c *RestClient[T]) initRequest(method HTTPMethod, headers map[string]string
 	}
 
 	// Set common headers
-	if body != nil {
+	if isRaw {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	} else if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Accept-Encoding", "gzip")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return c.finishRequest(req, headers, userAgent)
+}
+
+// initStreamRequest creates an HTTP request streaming the reader as its body, compressed on the fly
+// when request compression is enabled. The content type defaults to application/octet-stream.
+func (c *RestClient[T]) initStreamRequest(method HTTPMethod, urlStr string, headers map[string]string, params map[string]string, multiParams map[string][]string, body io.Reader, userAgent string) (*http.Request, error) {
+	var pipe *io.PipeReader
+	if c.compressRequestBody && getHeader(headers, "Content-Encoding") == "" {
+		pr, pw := io.Pipe()
+		go func(src io.Reader) {
+			gw := gzip.NewWriter(pw)
+			_, err := io.Copy(gw, src)
+			if err == nil {
+				err = gw.Close()
+			}
+			pw.CloseWithError(err)
+		}(body)
+		body = pr
+		pipe = pr
+	}
+
+	req, err := http.NewRequest(string(method), addParams(urlStr, params, multiParams), body)
+	if err == nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if pipe != nil {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		req, err = c.finishRequest(req, headers, userAgent)
+	}
+	// stop the compressing goroutine when the request won't be sent
+	if err != nil && pipe != nil {
+		pipe.Close()
+	}
+	return req, err
+}
+
+// finishRequest sets the common and custom headers of the request, then signs it.
+func (c *RestClient[T]) finishRequest(req *http.Request, headers map[string]string, userAgent string) (*http.Request, error) {
+	if !c.disableResponseCompression {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
 	req.Header.Set("User-Agent", userAgent)
 
 	// Set custom headers
@@ -385,9 +867,47 @@ func (c *RestClient[T]) initRequest(method HTTPMethod, headers map[string]string
 		req.Header.Set(key, value)
 	}
 
+	// Default to JSON unless the caller asked for another representation
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	// Sign the final request
+	if c.signer != nil {
+		if err := c.signer.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+
 	return req, nil
 }
 
+// buildURL returns the full URL for a request path.
+// Absolute URLs (starting with http:// or https://) are used as-is,
+// other paths are joined with the base URL.
+//
+// Parameters:
+//   - baseURL: The base URL the path is appended to
+//   - path: The request path or absolute URL
+//
+// Returns:
+//   - The full URL of the request
+func buildURL(baseURL *url.URL, path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+
+	urlStr := baseURL.String()
+	if path != "" {
+		if strings.HasSuffix(urlStr, "/") || strings.HasPrefix(path, "/") {
+			urlStr += path
+		} else {
+			urlStr += "/" + path
+		}
+	}
+	return urlStr
+}
+
 // MakeHTTPRequest makes an HTTP request with the specified parameters.
 // This is a convenience wrapper around MakeHTTPRequestWithKey without keys.
 //
@@ -395,7 +915,8 @@ func (c *RestClient[T]) initRequest(method HTTPMethod, headers map[string]string
 //   - method: The HTTP method to use
 //   - headers: HTTP headers to include in the request
 //   - params: Query parameters to include in the URL
-//   - body: The request body (for POST, PUT, etc.)
+//   - body: The request body (for POST, PUT, etc.), encoded as JSON. RawBody bodies are sent verbatim and
+//     io.Reader bodies are streamed without buffering, e.g. to proxy large uploads (such requests aren't retried)
 //   - path: The path to append to the base URL
 //
 // Returns:
@@ -406,7 +927,8 @@ func (c *RestClient[T]) MakeHTTPRequest(method HTTPMethod, headers map[string]st
 }
 
 // writeLog writes a request log entry to the console.
-// If errorLogOnly is true, it only logs entries with a status other than "SUCCESS".
+// If errorLogOnly is true, it only logs entries with a status other than "SUCCESS",
+// and with a slow request threshold, only those and the slow requests.
 //
 // Parameters:
 //   - logEntry: The RequestLogEntry to log
@@ -416,9 +938,9 @@ func (c *RestClient[T]) writeLog(logEntry *RequestLogEntry) {
 		fmt.Println(" +++ Writing log ...")
 	}
 
-	// Only log errors if errorLogOnly is true
-	if logEntry.Status != "SUCCESS" || !c.errorLogOnly {
-		str, err := json.Marshal(logEntry)
+	// Only log errors (and slow requests) if errorLogOnly is true or a slow request threshold is set
+	if c.shouldLog(logEntry) {
+		str, err := json.Marshal(c.redaction.entry(logEntry))
 		if err != nil {
 			fmt.Println("Error when marshal log entry")
 		} else {
@@ -442,6 +964,30 @@ func (c *RestClient[T]) writeLog(logEntry *RequestLogEntry) {
 //   - A pointer to a RestResult containing the response
 //   - An error if the request fails after all retry attempts
 func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[string]string, params map[string]string, body interface{}, path string, keys *[]string) (*RestResult, error) {
+	return c.makeHTTPRequest(method, headers, params, nil, body, path, keys, nil)
+}
+
+// MakeHTTPRequestWithMetadata makes an HTTP request like MakeHTTPRequestWithKey, attaching metadata
+// (e.g. tenant or user id) to the request log entry so log sinks can index and correlate calls.
+//
+// Parameters:
+//   - method: The HTTP method to use
+//   - headers: HTTP headers to include in the request
+//   - params: Query parameters to include in the URL
+//   - body: The request body (for POST, PUT, etc.)
+//   - path: The path to append to the base URL
+//   - keys: Optional keys associated with this request for tracking/logging
+//   - metadata: Optional attributes stored on the request log entry
+//
+// Returns:
+//   - A pointer to a RestResult containing the response
+//   - An error if the request fails after all retry attempts
+func (c *RestClient[T]) MakeHTTPRequestWithMetadata(method HTTPMethod, headers map[string]string, params map[string]string, body interface{}, path string, keys *[]string, metadata map[string]string) (*RestResult, error) {
+	return c.makeHTTPRequest(method, headers, params, nil, body, path, keys, metadata)
+}
+
+// makeHTTPRequest implements MakeHTTPRequestWithKey, also sending repeated query parameters and logging metadata.
+func (c *RestClient[T]) makeHTTPRequest(method HTTPMethod, headers map[string]string, params map[string]string, multiParams map[string][]string, body interface{}, path string, keys *[]string, metadata map[string]string) (*RestResult, error) {
 
 	date := time.Now()
 	// init log
@@ -451,27 +997,67 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 		userAgent += " " + hostname + "/" + os.Getenv("env")
 	}
 	logEntry := &RequestLogEntry{
-		ReqURL:      c.BaseURL.String() + path,
+		ReqURL:      buildURL(c.BaseURL, path),
 		ReqMethod:   string(method),
 		ReqFormData: &params,
 		ReqHeader:   &headers,
 		ReqBody:     &body,
 		Keys:        keys,
+		Metadata:    metadata,
 		Date:        &date,
 		Caller:      userAgent,
 	}
+	defer c.keepLog(logEntry)
+	defer c.stats.record(logEntry, date)
+
+	// serve safe requests from the cache when possible
+	cacheKey := ""
+	if c.cache != nil && isCacheable(method) && !hasCredentials(headers) {
+		cacheKey = string(method) + " " + addParams(logEntry.ReqURL, params, multiParams)
+		if cached := c.cache.get(cacheKey); cached != nil {
+			logEntry.Status = "SUCCESS"
+			return cached, nil
+		}
+	}
+
+	// hold a slot for the whole request, retries included, when the concurrency is capped
+	if limit := c.concurrency; limit != nil {
+		if err := limit.acquire(); err != nil {
+			logEntry.Status = "FAILED"
+			return nil, err
+		}
+		defer limit.release()
+	}
 
 	if c.debug {
 		fmt.Println(" +++ Try to init request ...")
 	}
 
 	canRetryCount := c.maxRetryTime
+	budgetExceeded := false
+	// codedResult is the last response when it carried a retryable error code, returned once retries are exhausted
+	var codedResult *RestResult
+
+	// a streamed body is consumed by the first attempt, so it can't be retried
+	if _, ok := body.(io.Reader); ok {
+		canRetryCount = 0
+	}
 
 	tstart := time.Now().UnixNano() / 1e6
 
 	for canRetryCount >= 0 {
 
-		req, reqErr := c.initRequest(method, headers, params, body, path, userAgent)
+		// spread attempts across the configured addresses
+		baseURL := c.BaseURL
+		address := ""
+		if c.balancer != nil {
+			address = c.balancer.pick()
+			if u, err := url.Parse(address); err == nil {
+				baseURL = u
+			}
+		}
+
+		req, reqErr := c.initRequest(baseURL, method, headers, params, multiParams, body, path, userAgent)
 
 		if c.debug {
 			fmt.Println(" +++ Request inited.")
@@ -493,6 +1079,17 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 
 		// add call result
 		callRs := &CallResult{}
+		recorded := false
+		codedResult = nil
+
+		// an attempt can't outlast the elapsed time budget, whatever its own timeout;
+		// its context is canceled once the body is read, not when all the attempts are done
+		cancel := func() {}
+		if c.maxElapsedTime > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(req.Context(), c.maxElapsedTime-time.Since(date))
+			req = req.WithContext(ctx)
+		}
 
 		// do request
 		resp, err := c.httpClient.Do(req)
@@ -503,8 +1100,23 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 		// make request successful
 		if err == nil {
 			restResult, err := c.readBody(resp, callRs, logEntry, canRetryCount, startCallTime, tstart)
-			if restResult != nil {
+			cancel()
+			if c.balancer != nil {
+				if restResult != nil && resp.StatusCode < 500 {
+					c.balancer.markSucceeded(address)
+				} else {
+					c.balancer.markFailed(address)
+				}
+			}
+			if restResult != nil && err == nil && c.hasRetryableErrorCode(restResult) {
+				// retried like failed calls, the attempt is already recorded by readBody
+				codedResult = restResult
+				recorded = true
+			} else if restResult != nil {
 				logEntry.Status = "SUCCESS"
+				if cacheKey != "" && err == nil {
+					c.cache.put(cacheKey, restResult, resp.Header)
+				}
 				return restResult, err
 			}
 
@@ -512,7 +1124,18 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 				logEntry.Status = "FAILED"
 				return restResult, err
 			}
+
+			// the server would send the same oversized body again, don't retry
+			if isResponseTooLarge(err) {
+				logEntry.addResult(callRs)
+				logEntry.Status = "FAILED"
+				return nil, err
+			}
 		} else {
+			cancel()
+			if c.balancer != nil {
+				c.balancer.markFailed(address)
+			}
 			if c.debug {
 				fmt.Println("HTTP Error: " + err.Error())
 			}
@@ -525,6 +1148,12 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 
 		canRetryCount--
 
+		// give up when waiting for the next attempt would exceed the elapsed time budget
+		if canRetryCount >= 0 && c.maxElapsedTime > 0 && time.Since(date)+c.waitTime >= c.maxElapsedTime {
+			canRetryCount = -1
+			budgetExceeded = true
+		}
+
 		if canRetryCount >= 0 {
 			time.Sleep(c.waitTime)
 			if c.debug {
@@ -538,7 +1167,9 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 		if canRetryCount >= 0 {
 			logEntry.RetryCount = c.maxRetryTime - canRetryCount
 		}
-		logEntry.addResult(callRs)
+		if !recorded {
+			logEntry.addResult(callRs)
+		}
 		if c.debug {
 			fmt.Println("Try to exit loop ...")
 		}
@@ -551,11 +1182,106 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 	tend := time.Now().UnixNano() / 1e6
 	logEntry.TotalTime = tend - tstart
 	logEntry.Status = "FAILED"
-	return nil, errors.New("fail to call endpoint API " + logEntry.ReqURL)
+	if c.onRetryExhausted != nil {
+		c.onRetryExhausted(c.redaction.entry(logEntry))
+	}
+	// the response carrying the retryable error code is still the answer of the server
+	if codedResult != nil {
+		return codedResult, nil
+	}
+	retryErr := &RetryError{
+		URL:      logEntry.ReqURL,
+		Attempts: logEntry.Results,
+		message:  "fail to call endpoint API " + logEntry.ReqURL,
+	}
+	if budgetExceeded {
+		retryErr.message += " within " + c.maxElapsedTime.String() + ", last error: " + lastCallError(logEntry)
+	}
+	return nil, retryErr
+}
+
+// hasRetryableErrorCode reports whether the response carries one of the error codes set with
+// SetRetryOnErrorCodes, e.g. OVERLOAD.
+//
+// Parameters:
+//   - result: The result of the HTTP request
+//
+// Returns:
+//   - true if the request should be retried for its error code
+func (c *RestClient[T]) hasRetryableErrorCode(result *RestResult) bool {
+	if len(c.retryOnErrorCodes) == 0 {
+		return false
+	}
+	code := decodeResponse[any](result, nil, c.marshaler).ErrorCode
+	return code != "" && c.retryOnErrorCodes[code]
+}
+
+// readLimited reads the whole reader, failing with a RESPONSE_TOO_LARGE error
+// when the content exceeds the maximum response body size.
+//
+// Parameters:
+//   - r: The reader to read
+//
+// Returns:
+//   - The content read
+//   - An error if reading fails or the content is too large
+func (c *RestClient[T]) readLimited(r io.Reader) ([]byte, error) {
+	if c.maxResponseBodySize <= 0 {
+		return io.ReadAll(r)
+	}
+
+	// read one more byte than allowed to detect oversized bodies
+	v, err := io.ReadAll(io.LimitReader(r, c.maxResponseBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(v)) > c.maxResponseBodySize {
+		return nil, common.NewError("RESPONSE_TOO_LARGE", "response body exceeds the maximum size of "+strconv.FormatInt(c.maxResponseBodySize, 10)+" bytes")
+	}
+	return v, nil
+}
+
+// maxErrorBodySnippet is the maximum number of body bytes included in synthesized error messages.
+const maxErrorBodySnippet = 200
+
+// httpErrorMessage describes an HTTP error response with its status text and the beginning of its body.
+//
+// Parameters:
+//   - code: The HTTP status code
+//   - body: The response body
+//
+// Returns:
+//   - A message like "403 Forbidden: access denied"
+func httpErrorMessage(code int, body string) string {
+	message := strconv.Itoa(code) + " " + http.StatusText(code)
+	body = strings.TrimSpace(body)
+	if len(body) > maxErrorBodySnippet {
+		body = strings.ToValidUTF8(body[:maxErrorBodySnippet], "") + "..."
+	}
+	if body != "" {
+		message += ": " + body
+	}
+	return message
+}
+
+// isResponseTooLarge reports whether the error was returned for a response exceeding the maximum body size.
+func isResponseTooLarge(err error) bool {
+	var e *common.Error
+	return errors.As(err, &e) && e.ErrorCode == "RESPONSE_TOO_LARGE"
+}
+
+// decodeBody decompresses the response content with the decoder, within the response body size limit.
+func (c *RestClient[T]) decodeBody(decode responseDecoder, content []byte) ([]byte, error) {
+	reader, err := decode(content)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return c.readLimited(reader)
 }
 
 // readBody reads and processes the HTTP response body.
-// It handles gzip decompression and updates the call result and log entry.
+// It decompresses gzip and deflate encoded bodies and updates the call result and log entry.
 //
 // Parameters:
 //   - resp: The HTTP response
@@ -570,7 +1296,7 @@ func (c *RestClient[T]) MakeHTTPRequestWithKey(method HTTPMethod, headers map[st
 //   - An error if processing fails
 func (c *RestClient[T]) readBody(resp *http.Response, callRs *CallResult, logEntry *RequestLogEntry, canRetryCount int, startCallTime int64, tstart int64) (*RestResult, error) {
 	defer resp.Body.Close()
-	v, err := io.ReadAll(resp.Body)
+	v, err := c.readLimited(resp.Body)
 	if err != nil {
 		msg := err.Error()
 		callRs.ErrorLog = &msg
@@ -581,24 +1307,26 @@ func (c *RestClient[T]) readBody(resp *http.Response, callRs *CallResult, logEnt
 		fmt.Println("+++ IO read ended!")
 	}
 	restResult := RestResult{
-		Code:    resp.StatusCode,
-		Body:    string(v),
-		Content: v,
+		Code:        resp.StatusCode,
+		Body:        string(v),
+		Content:     v,
+		ContentType: resp.Header.Get("Content-Type"),
+		NextCursor:  resp.Header.Get(common.NextCursorHeader),
 	}
 
 	encoding := resp.Header.Get("Content-Encoding")
-	if encoding == "gzip" {
+	if decode := findDecoder(encoding); decode != nil {
 		if c.debug {
-			fmt.Println("+++ Start to gunzip")
+			fmt.Println("+++ Start to decode " + encoding)
 		}
-		gr, _ := gzip.NewReader(bytes.NewBuffer(restResult.Content))
-		data, err := io.ReadAll(gr)
-		gr.Close()
+		data, err := c.decodeBody(decode, restResult.Content)
 		if err != nil {
+			msg := err.Error()
+			callRs.ErrorLog = &msg
 			return nil, err
 		}
 		if c.debug {
-			fmt.Println("+++ gunzip successfully")
+			fmt.Println("+++ decode " + encoding + " successfully")
 		}
 		restResult.Content = data
 		restResult.Body = string(data)
@@ -620,7 +1348,7 @@ func (c *RestClient[T]) readBody(resp *http.Response, callRs *CallResult, logEnt
 	}
 
 	if c.debug {
-		fmt.Println("+++ Read data end, http code: " + string(resp.StatusCode))
+		fmt.Println("+++ Read data end, http code: " + strconv.Itoa(resp.StatusCode))
 	}
 	if c.acceptHttpError || (resp.StatusCode >= 200 && resp.StatusCode < 300) || (resp.StatusCode >= 400 && resp.StatusCode < 500) {
 		// add log
@@ -647,6 +1375,43 @@ func (c *RestClient[T]) readBody(resp *http.Response, callRs *CallResult, logEnt
 // Returns:
 //   - A pointer to a common.APIResponse containing the response
 func (c *RestClient[T]) MakeRequest(req request.APIRequest) *common.APIResponse[T] {
+	return makeRestRequest[T](c, req)
+}
+
+// MakeRequestInto makes the API request like MakeRequest, decoding the response data into the target
+// instead of the client's type T, so one client can call endpoints returning different shapes.
+// The target must be a pointer: to a slice to receive every data item, or to an object to receive the first one.
+//
+// Parameters:
+//   - req: The API request to process
+//   - target: A pointer receiving the response data
+//
+// Returns:
+//   - A pointer to a common.APIResponse with the response status and message, without data
+func (c *RestClient[T]) MakeRequestInto(req request.APIRequest, target interface{}) *common.APIResponse[any] {
+	return decodeInto(makeRestRequest[json.RawMessage](c, req), target, c.marshaler)
+}
+
+// Do makes a request with the method given as a string, e.g. chosen at runtime or a custom method
+// like PURGE, building it from the options. It's a shortcut for MakeRequest with an OutboundAPIRequest.
+//
+// Parameters:
+//   - method: The request method, any valid HTTP method token
+//   - path: The path to append to the base URL
+//   - opts: The query parameters, headers and body of the request
+//
+// Returns:
+//   - A pointer to a common.APIResponse containing the response, or an INVALID one if the request can't be built
+func (c *RestClient[T]) Do(method string, path string, opts RequestOptions) *common.APIResponse[T] {
+	req, err := buildRequest(method, path, opts)
+	if err != nil {
+		return invalidRequestResponse[T](err)
+	}
+	return c.MakeRequest(req)
+}
+
+// makeRestRequest implements MakeRequest, decoding the response data items as R.
+func makeRestRequest[R any, T any](c *RestClient[T], req request.APIRequest) *common.APIResponse[R] {
 	var data interface{}
 	var reqMethod = req.GetMethod()
 	var method HTTPMethod
@@ -663,10 +1428,22 @@ func (c *RestClient[T]) MakeRequest(req request.APIRequest) *common.APIResponse[
 	case "PATCH":
 		method = HTTPMethods.Put
 		req.ParseBody(&data)
+	case "QUERY":
+		// safe like GET, but the query is carried by the body, sent verbatim since it may not be JSON (e.g. SQL)
+		method = HTTPMethods.Query
+		if content := req.GetContentText(); content != "" {
+			data = RawBody(content)
+		}
 	case "DELETE":
 		method = HTTPMethods.Delete
 	case "OPTIONS":
 		method = HTTPMethods.Option
+	default:
+		// other methods (TRACE, CONNECT, QUERY or custom ones) are sent as-is, with their body if any
+		method = HTTPMethod(reqMethod.Value)
+		if req.GetContentText() != "" {
+			req.ParseBody(&data)
+		}
 	}
 
 	if c.debug {
@@ -676,40 +1453,65 @@ func (c *RestClient[T]) MakeRequest(req request.APIRequest) *common.APIResponse[
 		}
 	}
 
-	result, err := c.MakeHTTPRequest(method, req.GetHeaders(), req.GetParams(), data, req.GetPath())
+	// repeated query parameters are only carried by outbound requests
+	var multiParams map[string][]string
+	if outbound, ok := req.(*request.OutboundAPIRequest); ok {
+		multiParams = outbound.MultiParams
+	}
+
+	result, err := c.makeHTTPRequest(method, req.GetHeaders(), req.GetParams(), multiParams, data, req.GetPath(), nil, nil)
+	return decodeResponse[R](result, err, c.marshaler)
+}
 
+// decodeResponse converts the result of an HTTP request into an APIResponse.
+// Responses without a status get one from the HTTP status code.
+//
+// Parameters:
+//   - result: The result of the HTTP request
+//   - err: The error of the HTTP request
+//   - marshaler: The JSON marshaler decoding the body, or nil for encoding/json
+//
+// Returns:
+//   - A pointer to a common.APIResponse containing the response
+func decodeResponse[T any](result *RestResult, err error, marshaler common.Marshaler) *common.APIResponse[T] {
 	if err != nil {
+		var e *common.Error
+		if errors.As(err, &e) {
+			return &common.APIResponse[T]{
+				Status:    common.APIStatus.Error,
+				Message:   "HTTP Endpoint Error: " + e.Message,
+				ErrorCode: e.ErrorCode,
+			}
+		}
 		return &common.APIResponse[T]{
 			Status:  common.APIStatus.Error,
 			Message: "HTTP Endpoint Error: " + err.Error(),
 		}
 	}
 
-	var resp = &common.APIResponse[T]{}
-	err = json.Unmarshal(result.Content, &resp)
+	if isFormContent(result.ContentType) {
+		return decodeFormResponse[T](result, marshaler)
+	}
 
-	if resp.Data != nil {
-		jsonStr, err := json.Marshal(resp.Data)
-		if err == nil {
-			resp.Data = sdk.ConvertToObjectSlice[T](string(jsonStr))
-		}
+	// 204 responses have no body to decode
+	if result.Code == http.StatusNoContent {
+		return &common.APIResponse[T]{Status: common.APIStatus.Ok}
 	}
 
+	resp, err := common.UnmarshalResponse[T](result.Content, marshaler)
+
 	if resp.Status == "" {
-		if result.Code >= 500 {
-			resp.Status = common.APIStatus.Error
-		} else if result.Code >= 400 {
-			if result.Code == 404 {
-				resp.Status = common.APIStatus.NotFound
-			} else if result.Code == 403 {
-				resp.Status = common.APIStatus.Forbidden
-			} else if result.Code == 401 {
-				resp.Status = common.APIStatus.Unauthorized
-			} else {
-				resp.Status = common.APIStatus.Invalid
+		resp.Status = statusFromCode(result.Code)
+
+		// describe errors from upstreams that don't answer with an API response, e.g. plain-text error pages
+		if result.Code >= 400 {
+			if resp.ErrorCode == "" && (resp.Status == common.APIStatus.PreconditionFailed || resp.Status == common.APIStatus.Timeout) {
+				resp.ErrorCode = resp.Status
 			}
-		} else {
-			resp.Status = common.APIStatus.Ok
+			if resp.Message == "" {
+				resp.Message = httpErrorMessage(result.Code, result.Body)
+			}
+			return resp
 		}
 	}
 
@@ -719,5 +1521,85 @@ func (c *RestClient[T]) MakeRequest(req request.APIRequest) *common.APIResponse[
 			Message: "Response Data Error: " + err.Error() + " body=" + result.Body,
 		}
 	}
+
+	// the cursor of paginated results is sent in a header, kept like the headers of Thrift responses
+	if result.NextCursor != "" {
+		if resp.Headers == nil {
+			resp.Headers = make(map[string]string)
+		}
+		resp.Headers[common.NextCursorHeader] = result.NextCursor
+	}
+	return resp
+}
+
+// statusFromCode returns the API status matching the HTTP status code,
+// for upstreams that don't answer with an API response.
+func statusFromCode(code int) string {
+	switch {
+	case code == 504:
+		return common.APIStatus.Timeout
+	case code >= 500:
+		return common.APIStatus.Error
+	case code == 404:
+		return common.APIStatus.NotFound
+	case code == 403:
+		return common.APIStatus.Forbidden
+	case code == 401:
+		return common.APIStatus.Unauthorized
+	case code == 412:
+		return common.APIStatus.PreconditionFailed
+	case code >= 400:
+		return common.APIStatus.Invalid
+	case code == 207:
+		return common.APIStatus.PartialSuccess
+	}
+	return common.APIStatus.Ok
+}
+
+// isFormContent reports whether the Content-Type is application/x-www-form-urlencoded.
+func isFormContent(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.EqualFold(strings.TrimSpace(mediaType), "application/x-www-form-urlencoded")
+}
+
+// decodeFormResponse decodes a form-encoded body, as sent by some legacy upstreams, into a single Data item
+// with the marshaler (encoding/json when nil). Fields with one value are decoded as strings and repeated
+// fields as string arrays.
+func decodeFormResponse[T any](result *RestResult, marshaler common.Marshaler) *common.APIResponse[T] {
+	values, err := url.ParseQuery(result.Body)
+	if err != nil {
+		return &common.APIResponse[T]{
+			Status:  common.APIStatus.Error,
+			Message: "Response Data Error: " + err.Error() + " body=" + result.Body,
+		}
+	}
+
+	fields := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if len(value) == 1 {
+			fields[key] = value[0]
+		} else {
+			fields[key] = value
+		}
+	}
+	if marshaler == nil {
+		marshaler = common.StdMarshaler
+	}
+	jsonStr, _ := marshaler.Marshal(fields)
+	var item T
+	if err := marshaler.Unmarshal(jsonStr, &item); err != nil {
+		return &common.APIResponse[T]{
+			Status:  common.APIStatus.Error,
+			Message: "Response Data Error: " + err.Error() + " body=" + result.Body,
+		}
+	}
+
+	resp := &common.APIResponse[T]{
+		Status: statusFromCode(result.Code),
+		Data:   []T{item},
+	}
+	if result.Code >= 400 {
+		resp.Message = httpErrorMessage(result.Code, result.Body)
+	}
 	return resp
 }
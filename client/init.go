@@ -2,42 +2,189 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/phnam/go-protocol-adapter/common"
 	sdk "github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/thriftapi"
 )
 
 // APIClient defines the interface for making API requests across different protocols.
 type APIClient[T any] interface {
 	MakeRequest(sdk.APIRequest) *common.APIResponse[T]
+	// MakeRequestInto makes the request, decoding the response data into the target pointer instead of T
+	MakeRequestInto(sdk.APIRequest, interface{}) *common.APIResponse[any]
+	// Do makes a request with a method chosen at runtime, including custom methods like PURGE
+	Do(method string, path string, opts RequestOptions) *common.APIResponse[T]
 	SetDebug(bool)
 }
 
 // APIClientConfiguration contains all the configuration parameters needed to create an API client.
 type APIClientConfiguration struct {
-	// Address is the endpoint URL or host:port of the API server
+	// Address is the endpoint URL or host:port of the API server, or the path of its Unix socket prefixed
+	// with "unix://" (e.g. "unix:///var/run/api.sock")
 	Address string
+	// Addresses lists several endpoints of the API server (replicas) to spread calls across in round-robin order.
+	// Addresses that recently failed are skipped. When set, it's used instead of Address.
+	Addresses []string
 	// Protocol specifies the communication protocol ("HTTP" or "THRIFT")
 	Protocol string
 	// Timeout is the maximum duration to wait for a request to complete
 	Timeout time.Duration
+	// ConnectTimeout is the maximum duration to wait for a connection to be established, so an unreachable
+	// server fails faster than a slow response. Timeout is used when 0
+	ConnectTimeout time.Duration
 	// MaxRetry is the maximum number of retry attempts for failed requests
 	MaxRetry int
 	// WaitToRetry is the duration to wait between retry attempts
 	WaitToRetry time.Duration
+	// RetryBackoffFactor multiplies the wait after each retry attempt, e.g. 2 for an exponential backoff
+	// of WaitToRetry, 2*WaitToRetry, 4*WaitToRetry... The wait is constant when 1 or less (for Thrift)
+	RetryBackoffFactor float64
+	// RetryOnErrorCodes lists the response error codes worth retrying (e.g. OVERLOAD), up to MaxRetry times
+	RetryOnErrorCodes []string
+	// MaxElapsedTime caps the total time of a request across all attempts and waits, no limit when 0 (used for HTTP client)
+	MaxElapsedTime time.Duration
+	// MaxResponseBodySize caps the size in bytes of response bodies, no limit when 0 (used for HTTP client).
+	// For Thrift it caps the content of compressed responses once decoded, DefaultMaxDecodedContentSize when 0.
+	MaxResponseBodySize int64
+	// DialContext dials the connections of the client instead of the default TCP dialer, e.g. through a tunnel.
+	// It isn't used for "unix://" addresses (used for HTTP client)
+	DialContext func(ctx context.Context, network string, address string) (net.Conn, error)
+	// MaxConcurrentRequests caps the number of requests in flight, so callers over the limit wait for a free slot,
+	// no limit when 0 (used for HTTP client)
+	MaxConcurrentRequests int
+	// FailFastWhenBusy when true, makes requests over MaxConcurrentRequests fail right away with a CLIENT_BUSY error
+	// instead of waiting (used for HTTP client)
+	FailFastWhenBusy bool
+	// MaxConnAge retires connections older than the age, so new ones are opened periodically and rebalanced
+	// across backend instances, no limit when 0 (used for HTTP client)
+	MaxConnAge time.Duration
 
 	// MaxConnection defines the maximum number of concurrent connections (for Thrift)
 	MaxConnection int
+	// ConnAcquireTimeout is the maximum duration to wait for a free connection when the pool is full (for Thrift), 100ms by default
+	ConnAcquireTimeout time.Duration
+	// ConnAcquireRetries is the number of attempts to pick a free connection within ConnAcquireTimeout (for Thrift), 10 by default
+	ConnAcquireRetries int
+	// ConnHealthCheckPath when set, makes the Thrift client check pooled connections are alive before reusing them,
+	// with a GET call to this path (any answer, even NOT_FOUND, counts). Dead connections, e.g. silently dropped
+	// by a firewall, are discarded. It adds a round trip to every call, so it's disabled by default.
+	ConnHealthCheckPath string
+	// ConnHealthCheckTimeout is how long the connection health check waits for an answer (for Thrift), 100ms by default
+	ConnHealthCheckTimeout time.Duration
 	// ErrorLogOnly when true, only logs errors and not successful requests
 	ErrorLogOnly bool
+	// SlowRequestThreshold only logs successful requests slower than it, errors are logged regardless.
+	// Slow requests are logged even with ErrorLogOnly (used for HTTP client)
+	SlowRequestThreshold time.Duration
+	// LogExpiration keeps the log entries of the recent requests in memory for the duration, see RestClient.RecentLogs.
+	// No entries are kept when 0 (used for HTTP client)
+	LogExpiration time.Duration
+
+	// Marshaler encodes JSON request bodies and decodes JSON responses, the envelope and the data alike
+	// (including MakeRequestInto targets), e.g. backed by jsoniter or sonic for large payloads.
+	// encoding/json is used when nil.
+	Marshaler common.Marshaler
 
 	// ResultObject can hold a custom result object for the client
 	ResultObject interface{}
 
 	// KeepDataStringFormat when true, keeps response data as string format (used for Thrift client)
 	KeepDataStringFormat *bool
+
+	// OnRetryExhausted is called with the request log entry when all retry attempts failed (used for HTTP client)
+	OnRetryExhausted func(entry *RequestLogEntry)
+
+	// RedactFields lists the JSON fields and form params masked with "***" in request logs, e.g. password (used for HTTP client)
+	RedactFields []string
+	// RedactHeaders lists the headers masked with "***" in request logs, DefaultRedactHeaders when nil (used for HTTP client)
+	RedactHeaders []string
+
+	// DisableResponseCompression when true, omits the Accept-Encoding header so responses come uncompressed
+	// (used for HTTP client and Thrift)
+	DisableResponseCompression bool
+
+	// CompressRequestBody when true, gzips request bodies that don't already have a Content-Encoding (used for HTTP client)
+	CompressRequestBody bool
+
+	// RequestSigner signs each request after its body is built, e.g. NewHMACSigner(key) (used for HTTP client)
+	RequestSigner RequestSigner
+
+	// ResponseCache enables an in-memory LRU cache of GET/HEAD responses when set, except for requests
+	// with an Authorization or Cookie header (used for HTTP client)
+	ResponseCache *ResponseCacheConfig
+
+	// SingleConnection when true, makes the Thrift client keep exactly one long-lived connection,
+	// serializing calls through it and reconnecting on failure
+	SingleConnection bool
+
+	// ThriftTransport specifies the Thrift transport layer (common.ThriftTransport), FRAMED by default.
+	// It must match the transport of the server.
+	ThriftTransport string
+
+	// ThriftMethodName is the Thrift method called, matching the server's ThriftMethodName ("call" by default, used for Thrift client)
+	ThriftMethodName string
+
+	// RequestMapper overrides how requests are mapped to Thrift requests, e.g. to move large content into
+	// a header or inject default params; DefaultThriftRequestMapper when nil (used for Thrift client)
+	RequestMapper func(sdk.APIRequest) *thriftapi.APIRequest
+}
+
+// Clone returns a deep copy of the configuration, so it can be tweaked for another client
+// without affecting this one. Slices and pointed values are copied; functions, the request
+// signer and ResultObject are shared.
+func (config *APIClientConfiguration) Clone() *APIClientConfiguration {
+	if config == nil {
+		return nil
+	}
+	clone := *config
+	if config.Addresses != nil {
+		clone.Addresses = append([]string(nil), config.Addresses...)
+	}
+	if config.RetryOnErrorCodes != nil {
+		clone.RetryOnErrorCodes = append([]string(nil), config.RetryOnErrorCodes...)
+	}
+	if config.RedactFields != nil {
+		clone.RedactFields = append([]string(nil), config.RedactFields...)
+	}
+	if config.RedactHeaders != nil {
+		// an empty list disables the default redacted headers, so it must stay non-nil
+		clone.RedactHeaders = append(config.RedactHeaders[:0:0], config.RedactHeaders...)
+	}
+	if config.KeepDataStringFormat != nil {
+		keep := *config.KeepDataStringFormat
+		clone.KeepDataStringFormat = &keep
+	}
+	if config.ResponseCache != nil {
+		cache := *config.ResponseCache
+		clone.ResponseCache = &cache
+	}
+	return &clone
+}
+
+// WithTimeout returns a copy of the configuration with the given timeout, e.g.
+//
+//	slowClient := client.NewAPIClient[Report](baseConfig.WithTimeout(10 * time.Second))
+func (config *APIClientConfiguration) WithTimeout(timeout time.Duration) *APIClientConfiguration {
+	clone := config.Clone()
+	clone.Timeout = timeout
+	return clone
+}
+
+// WithRetry returns a copy of the configuration with the given number of retries and wait between them.
+func (config *APIClientConfiguration) WithRetry(maxRetry int, waitToRetry time.Duration) *APIClientConfiguration {
+	clone := config.Clone()
+	clone.MaxRetry = maxRetry
+	clone.WaitToRetry = waitToRetry
+	return clone
 }
 
 // NewAPIClient creates a new API client based on the specified protocol in the configuration.
@@ -62,3 +209,128 @@ func NewAPIClient[T any](config *APIClientConfiguration) APIClient[T] {
 	}
 	return nil
 }
+
+// errorCodeSet builds the set of the given error codes.
+func errorCodeSet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// connectTimeout returns the timeout of connection establishment, the overall Timeout when unset.
+func (config *APIClientConfiguration) connectTimeout() time.Duration {
+	if config.ConnectTimeout > 0 {
+		return config.ConnectTimeout
+	}
+	return config.Timeout
+}
+
+// unixSocketPath returns the socket path of a Unix socket address like "unix:///var/run/api.sock"
+// or "unix:/var/run/api.sock", and whether the address is a Unix socket address.
+func unixSocketPath(address string) (string, bool) {
+	path, ok := strings.CutPrefix(address, "unix:")
+	if !ok {
+		return "", false
+	}
+	return "/" + strings.TrimLeft(path, "/"), true
+}
+
+// RequestOptions holds the optional parts of a request made with Do.
+type RequestOptions struct {
+	// Params are the query parameters
+	Params map[string]string
+	// MultiParams are the repeated query parameters (e.g. ?id=1&id=2), only sent by the HTTP client
+	MultiParams map[string][]string
+	// Headers are the request headers
+	Headers map[string]string
+	// Body is the request body, encoded as JSON unless it's a string or []byte, which are sent as-is
+	Body interface{}
+}
+
+// buildRequest builds the outbound request of a Do call, validating the method and encoding the body.
+func buildRequest(method string, path string, opts RequestOptions) (*sdk.OutboundAPIRequest, error) {
+	builder := sdk.NewRequest(method, path)
+	for name, value := range opts.Params {
+		builder.WithParam(name, value)
+	}
+	for name, values := range opts.MultiParams {
+		builder.WithMultiParam(name, values...)
+	}
+	for name, value := range opts.Headers {
+		builder.WithHeader(name, value)
+	}
+	switch body := opts.Body.(type) {
+	case nil:
+	case string:
+		builder.WithBody(body)
+	case []byte:
+		builder.WithBody(string(body))
+	default:
+		builder.WithJSONBody(body)
+	}
+	return builder.Build()
+}
+
+// invalidRequestResponse returns the response of a request that couldn't be built.
+func invalidRequestResponse[T any](err error) *common.APIResponse[T] {
+	resp := &common.APIResponse[T]{
+		Status:  common.APIStatus.Invalid,
+		Message: err.Error(),
+	}
+	var e *common.Error
+	if errors.As(err, &e) {
+		resp.ErrorCode = e.ErrorCode
+		resp.Message = e.Message
+	}
+	return resp
+}
+
+// decodeInto decodes the raw data items of the response into the target, a pointer to a slice
+// receiving every item or a pointer to an object receiving the first one.
+// The returned response carries the status, message and headers, without data.
+// The data is decoded with the marshaler, encoding/json when nil.
+func decodeInto(resp *common.APIResponse[json.RawMessage], target interface{}, marshaler common.Marshaler) *common.APIResponse[any] {
+	result := &common.APIResponse[any]{
+		Status:    resp.Status,
+		Message:   resp.Message,
+		ErrorCode: resp.ErrorCode,
+		Total:     resp.Total,
+		Headers:   resp.Headers,
+	}
+	if target == nil || len(resp.Data) == 0 {
+		return result
+	}
+
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		result.Status = common.APIStatus.Error
+		result.Message = "Response Data Error: target must be a non-nil pointer"
+		return result
+	}
+
+	if marshaler == nil {
+		marshaler = common.StdMarshaler
+	}
+	var err error
+	if value.Elem().Kind() == reflect.Slice {
+		// the raw items are joined back into an array rather than encoded again
+		content := []byte{'['}
+		for i, item := range resp.Data {
+			if i > 0 {
+				content = append(content, ',')
+			}
+			content = append(content, item...)
+		}
+		content = append(content, ']')
+		err = marshaler.Unmarshal(content, target)
+	} else {
+		err = marshaler.Unmarshal(resp.Data[0], target)
+	}
+	if err != nil {
+		result.Status = common.APIStatus.Error
+		result.Message = "Response Data Error: " + err.Error()
+	}
+	return result
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// recentLogsCapacity caps the number of entries kept for RecentLogs, whatever the log expiration.
+const recentLogsCapacity = 1000
+
+// bufferedLog is a request log entry kept in the buffer, with the time it was added.
+type bufferedLog struct {
+	entry   *RequestLogEntry
+	addedAt time.Time
+}
+
+// logBuffer is a ring buffer of the most recent request log entries, safe for concurrent use.
+type logBuffer struct {
+	// lock guards the ring
+	lock sync.Mutex
+	// logs is the ring of entries, the oldest one at index start
+	logs []bufferedLog
+	// start is the index of the oldest entry
+	start int
+	// count is the number of entries in the ring
+	count int
+}
+
+// newLogBuffer creates a buffer keeping up to capacity entries, the oldest ones being overwritten.
+func newLogBuffer(capacity int) *logBuffer {
+	return &logBuffer{logs: make([]bufferedLog, capacity)}
+}
+
+// add appends the entry, overwriting the oldest one when the buffer is full.
+func (buf *logBuffer) add(entry *RequestLogEntry) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	index := (buf.start + buf.count) % len(buf.logs)
+	buf.logs[index] = bufferedLog{entry: entry, addedAt: time.Now()}
+	if buf.count < len(buf.logs) {
+		buf.count++
+	} else {
+		buf.start = (buf.start + 1) % len(buf.logs)
+	}
+}
+
+// recent evicts the entries added longer than expiration ago, and returns the remaining ones, oldest first.
+func (buf *logBuffer) recent(expiration time.Duration) []*RequestLogEntry {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	cutoff := time.Now().Add(-expiration)
+	for buf.count > 0 && buf.logs[buf.start].addedAt.Before(cutoff) {
+		buf.logs[buf.start] = bufferedLog{}
+		buf.start = (buf.start + 1) % len(buf.logs)
+		buf.count--
+	}
+
+	entries := make([]*RequestLogEntry, buf.count)
+	for i := range entries {
+		entries[i] = buf.logs[(buf.start+i)%len(buf.logs)].entry
+	}
+	return entries
+}
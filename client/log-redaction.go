@@ -0,0 +1,173 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RedactedValue replaces the values of redacted fields and headers in request logs.
+const RedactedValue = "***"
+
+// DefaultRedactHeaders are the headers redacted from request logs when RedactHeaders isn't set.
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// redaction masks secrets (passwords, tokens, PII) in request log entries before they're emitted.
+type redaction struct {
+	// fields are the lower-cased JSON fields and form params to mask
+	fields map[string]bool
+	// headers are the canonical names of the headers to mask, DefaultRedactHeaders are used when nil
+	headers map[string]bool
+}
+
+// redactionSet builds a lookup set of the names, normalized with the function.
+// It returns nil for a nil list, and an empty set for an empty one.
+func redactionSet(names []string, normalize func(string) string) map[string]bool {
+	if names == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[normalize(name)] = true
+	}
+	return set
+}
+
+// isRedactedHeader reports whether the header must be masked.
+func (r *redaction) isRedactedHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if r.headers == nil {
+		for _, header := range DefaultRedactHeaders {
+			if http.CanonicalHeaderKey(header) == name {
+				return true
+			}
+		}
+		return false
+	}
+	return r.headers[name]
+}
+
+// entry returns a copy of the log entry with the configured fields and headers masked.
+// The entry itself is left untouched, since its results are also returned to the caller in RetryError.
+func (r *redaction) entry(logEntry *RequestLogEntry) *RequestLogEntry {
+	redacted := *logEntry
+	if logEntry.ReqHeader != nil {
+		headers := r.stringMap(*logEntry.ReqHeader, r.isRedactedHeader)
+		redacted.ReqHeader = &headers
+	}
+	if logEntry.ReqFormData != nil {
+		params := r.stringMap(*logEntry.ReqFormData, r.isRedactedField)
+		redacted.ReqFormData = &params
+	}
+	if logEntry.ReqBody != nil && len(r.fields) > 0 {
+		body := r.body(*logEntry.ReqBody)
+		redacted.ReqBody = &body
+	}
+
+	redacted.Results = make([]*CallResult, len(logEntry.Results))
+	for i, result := range logEntry.Results {
+		redacted.Results[i] = r.callResult(result)
+	}
+	return &redacted
+}
+
+// callResult returns a copy of the call result with the response headers and body masked.
+func (r *redaction) callResult(result *CallResult) *CallResult {
+	if result == nil {
+		return nil
+	}
+	redacted := *result
+	if result.RespHeader != nil {
+		redacted.RespHeader = make(map[string][]string, len(result.RespHeader))
+		for name, values := range result.RespHeader {
+			if r.isRedactedHeader(name) {
+				values = []string{RedactedValue}
+			}
+			redacted.RespHeader[name] = values
+		}
+	}
+	if result.RespBody != nil && len(r.fields) > 0 {
+		if masked, ok := r.jsonText(*result.RespBody); ok {
+			redacted.RespBody = &masked
+		}
+	}
+	return &redacted
+}
+
+// isRedactedField reports whether the JSON field or form param must be masked.
+func (r *redaction) isRedactedField(name string) bool {
+	return r.fields[strings.ToLower(name)]
+}
+
+// stringMap returns a copy of the map with the values of the matching keys masked.
+func (r *redaction) stringMap(values map[string]string, redacted func(string) bool) map[string]string {
+	if values == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(values))
+	for key, value := range values {
+		if redacted(key) {
+			value = RedactedValue
+		}
+		masked[key] = value
+	}
+	return masked
+}
+
+// body returns the request body with the configured fields masked. Bodies that aren't JSON,
+// like streamed or binary content, are returned as-is.
+func (r *redaction) body(body interface{}) interface{} {
+	var content []byte
+	switch b := body.(type) {
+	case []byte:
+		content = b
+	case RawBody:
+		content = b
+	case string:
+		content = []byte(b)
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return body
+		}
+		content = encoded
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return body
+	}
+	return r.value(decoded)
+}
+
+// jsonText returns the JSON text with the configured fields masked, or false when it isn't JSON.
+func (r *redaction) jsonText(text string) (string, bool) {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		return "", false
+	}
+	masked, err := json.Marshal(r.value(decoded))
+	if err != nil {
+		return "", false
+	}
+	return string(masked), true
+}
+
+// value masks the configured fields in a decoded JSON value, at any depth.
+func (r *redaction) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if r.isRedactedField(key) {
+				v[key] = RedactedValue
+			} else {
+				v[key] = r.value(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.value(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,53 @@
+package client
+
+import (
+	"github.com/phnam/go-protocol-adapter/common"
+	sdk "github.com/phnam/go-protocol-adapter/request"
+)
+
+// ForEachPage iterates over the pages of a paginated result: it makes the request, then requests the next
+// page as long as the response carries a cursor in the X-Next-Cursor header, passing it back in the "cursor"
+// query parameter. The request is copied for each page, so it isn't modified.
+//
+//	err := client.ForEachPage(cli, &request.OutboundAPIRequest{Method: "GET", Path: "/orders"},
+//		func(page *common.APIResponse[Order]) error {
+//			return store(page.Data)
+//		})
+//
+// Parameters:
+//   - cli: The client making the requests
+//   - req: The request of the first page
+//   - fn: The function called with every page, stopping the iteration when it returns an error
+//
+// Returns:
+//   - nil once the last page is handled, the error of the first failed page (see APIResponse.AsError),
+//     the error returned by fn, or a PAGINATION_LOOP error when the server sends the same cursor again
+func ForEachPage[T any](cli APIClient[T], req *sdk.OutboundAPIRequest, fn func(page *common.APIResponse[T]) error) error {
+	page := *req
+	cursor := req.Params[common.CursorParam]
+	for {
+		resp := cli.MakeRequest(&page)
+		if err := resp.AsError(); err != nil {
+			return err
+		}
+		if err := fn(resp); err != nil {
+			return err
+		}
+
+		next := resp.GetNextCursor()
+		if next == "" {
+			return nil
+		}
+		if next == cursor {
+			return common.NewError("PAGINATION_LOOP", "server sent the cursor \""+next+"\" of the current page as the next one")
+		}
+		cursor = next
+
+		params := make(map[string]string, len(req.Params)+1)
+		for key, value := range req.Params {
+			params[key] = value
+		}
+		params[common.CursorParam] = cursor
+		page.Params = params
+	}
+}
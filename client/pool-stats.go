@@ -0,0 +1,78 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// PoolStats is a snapshot of the connection pool stats of a ThriftClient, e.g. for saturation alerts:
+// long waits to acquire a connection signal that MaxConnection is too small.
+type PoolStats struct {
+	// Acquisitions is the number of connections acquired from the pool
+	Acquisitions int64
+	// Waits is the number of acquisitions that had to wait for a connection to be freed
+	Waits int64
+	// Timeouts is the number of calls failing with OVERLOAD, no connection being freed within ConnAcquireTimeout
+	Timeouts int64
+	// AverageWait is the average time spent acquiring a connection, timeouts included
+	AverageWait time.Duration
+	// MaxWait is the longest time spent acquiring a connection, timeouts included
+	MaxWait time.Duration
+	// OpenConnections is the number of connections currently in the pool
+	OpenConnections int
+	// InUseConnections is the number of pooled connections currently used by a call
+	InUseConnections int
+}
+
+// poolStats accumulates the connection acquisition times of a pool, safe for concurrent use.
+type poolStats struct {
+	// lock guards the counters
+	lock sync.Mutex
+	// acquisitions, waits and timeouts count the acquisition attempts by outcome
+	acquisitions, waits, timeouts int64
+	// totalWait and maxWait are the sum and the maximum of the acquisition times
+	totalWait, maxWait time.Duration
+}
+
+// record adds an acquisition attempt to the stats, with the time it took and whether it had to wait
+// and got a connection.
+func (stats *poolStats) record(wait time.Duration, waited bool, acquired bool) {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	if acquired {
+		stats.acquisitions++
+	} else {
+		stats.timeouts++
+	}
+	if waited {
+		stats.waits++
+	}
+	stats.totalWait += wait
+	if wait > stats.maxWait {
+		stats.maxWait = wait
+	}
+}
+
+// snapshot returns the current stats, without the connection counts.
+func (stats *poolStats) snapshot() PoolStats {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	snapshot := PoolStats{
+		Acquisitions: stats.acquisitions,
+		Waits:        stats.waits,
+		Timeouts:     stats.timeouts,
+		MaxWait:      stats.maxWait,
+	}
+	if attempts := stats.acquisitions + stats.timeouts; attempts > 0 {
+		snapshot.AverageWait = stats.totalWait / time.Duration(attempts)
+	}
+	return snapshot
+}
+
+// reset clears the stats.
+func (stats *poolStats) reset() {
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	stats.acquisitions, stats.waits, stats.timeouts = 0, 0, 0
+	stats.totalWait, stats.maxWait = 0, 0
+}
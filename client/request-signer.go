@@ -0,0 +1,165 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// RequestSigner signs outgoing HTTP requests, usually by adding signature headers.
+// Sign is called once the request body and headers are final, so the signature covers the exact bytes sent.
+type RequestSigner interface {
+	Sign(req *http.Request) error
+}
+
+// Default headers used by HMACSigner.
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+)
+
+// UnsignedPayload replaces the body hash in the signature of streamed bodies signed with HMACSigner.UnsignedStreams.
+// It's sent in the ContentHashHeader so servers know the body isn't covered by the signature.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// ContentHashHeader is the header flagging requests signed without their body, see HMACSigner.UnsignedStreams.
+const ContentHashHeader = "X-Content-Sha256"
+
+// HMACSigner is a RequestSigner computing an HMAC-SHA256 signature over
+// the request method, path, timestamp and body.
+type HMACSigner struct {
+	// Key is the shared secret used to compute the signature
+	Key []byte
+	// SignatureHeader is the header receiving the hex-encoded signature, X-Signature by default
+	SignatureHeader string
+	// TimestampHeader is the header receiving the unix timestamp of the request, X-Timestamp by default
+	TimestampHeader string
+	// UnsignedStreams when true, signs streamed bodies (io.Reader bodies that can't be read twice) with
+	// UnsignedPayload instead of their hash, so they're still streamed rather than buffered in memory.
+	// When false, signing such requests fails with an UNSIGNABLE_STREAM error.
+	UnsignedStreams bool
+}
+
+// NewHMACSigner creates an HMACSigner with the given key and the default headers.
+//
+// Parameters:
+//   - key: The shared secret used to compute the signature
+//
+// Returns:
+//   - A pointer to a new HMACSigner instance
+func NewHMACSigner(key []byte) *HMACSigner {
+	return &HMACSigner{
+		Key:             key,
+		SignatureHeader: DefaultSignatureHeader,
+		TimestampHeader: DefaultTimestampHeader,
+	}
+}
+
+// Sign implements the RequestSigner interface.
+// It sets the timestamp header, then the signature computed with ComputeHMACSignature,
+// or ComputeUnsignedHMACSignature for streamed bodies when UnsignedStreams is set.
+//
+// Parameters:
+//   - req: The request to sign
+//
+// Returns:
+//   - An error if the request body cannot be read, or is a stream and UnsignedStreams isn't set
+func (s *HMACSigner) Sign(req *http.Request) error {
+	unsigned := s.UnsignedStreams && isStreamBody(req)
+	var body []byte
+	if !unsigned {
+		var err error
+		if body, err = readRequestBody(req); err != nil {
+			return err
+		}
+	}
+
+	signatureHeader := s.SignatureHeader
+	if signatureHeader == "" {
+		signatureHeader = DefaultSignatureHeader
+	}
+	timestampHeader := s.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = DefaultTimestampHeader
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(timestampHeader, timestamp)
+	if unsigned {
+		req.Header.Set(ContentHashHeader, UnsignedPayload)
+		req.Header.Set(signatureHeader, ComputeUnsignedHMACSignature(s.Key, req.Method, req.URL.RequestURI(), timestamp))
+		return nil
+	}
+	req.Header.Set(signatureHeader, ComputeHMACSignature(s.Key, req.Method, req.URL.RequestURI(), timestamp, body))
+	return nil
+}
+
+// ComputeHMACSignature computes the hex-encoded HMAC-SHA256 signature of a request.
+// The signed string is the method, path (with query), timestamp and hex-encoded SHA-256 of the body,
+// separated by new lines. Servers can use it to verify signatures made by HMACSigner.
+//
+// Parameters:
+//   - key: The shared secret
+//   - method: The HTTP method
+//   - path: The request path, including the query string
+//   - timestamp: The value of the timestamp header
+//   - body: The raw request body
+//
+// Returns:
+//   - The hex-encoded signature
+func ComputeHMACSignature(key []byte, method string, path string, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return hmacSignature(key, method, path, timestamp, hex.EncodeToString(bodyHash[:]))
+}
+
+// ComputeUnsignedHMACSignature computes the signature of a request whose body isn't signed, flagged by
+// the X-Content-Sha256: UNSIGNED-PAYLOAD header. It's ComputeHMACSignature with UnsignedPayload in place
+// of the body hash.
+//
+// Parameters:
+//   - key: The shared secret
+//   - method: The HTTP method
+//   - path: The request path, including the query string
+//   - timestamp: The value of the timestamp header
+//
+// Returns:
+//   - The hex-encoded signature
+func ComputeUnsignedHMACSignature(key []byte, method string, path string, timestamp string) string {
+	return hmacSignature(key, method, path, timestamp, UnsignedPayload)
+}
+
+// hmacSignature computes the hex-encoded HMAC-SHA256 of the signed string of a request.
+func hmacSignature(key []byte, method string, path string, timestamp string, payloadHash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n" + payloadHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isStreamBody reports whether the request body is a stream that can only be read once.
+func isStreamBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody && req.GetBody == nil
+}
+
+// readRequestBody returns the body of a request without consuming it.
+// Streamed bodies aren't buffered in memory to be read twice: they fail with an UNSIGNABLE_STREAM error.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if isStreamBody(req) {
+		return nil, common.NewError("UNSIGNABLE_STREAM",
+			"streamed request body can't be signed without buffering it, send it as RawBody or set HMACSigner.UnsignedStreams")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResponseCacheConfig configures the in-memory response cache of RestClient.
+// Only GET and HEAD requests with a 2xx response are cached.
+type ResponseCacheConfig struct {
+	// MaxSize is the maximum number of cached responses, the least recently used are evicted first (default 100)
+	MaxSize int
+	// DefaultTTL is how long responses are cached when they don't carry a Cache-Control max-age directive.
+	// Such responses aren't cached when DefaultTTL is 0.
+	DefaultTTL time.Duration
+}
+
+// responseCache is an LRU cache of REST results keyed by method and URL.
+type responseCache struct {
+	// lock guards entries and order
+	lock sync.Mutex
+	// maxSize is the maximum number of entries
+	maxSize int
+	// defaultTTL is used when the response has no max-age directive
+	defaultTTL time.Duration
+	// entries maps cache keys to elements of order
+	entries map[string]*list.Element
+	// order lists the entries from the most to the least recently used
+	order *list.List
+	// hits counts the requests served from the cache
+	hits atomic.Int64
+	// misses counts the cacheable requests that weren't found in the cache
+	misses atomic.Int64
+}
+
+// cacheEntry is a cached REST result with its expiration time.
+type cacheEntry struct {
+	key       string
+	result    RestResult
+	expiresAt time.Time
+}
+
+// newResponseCache creates a response cache from the given configuration.
+func newResponseCache(config *ResponseCacheConfig) *responseCache {
+	maxSize := config.MaxSize
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+	return &responseCache{
+		maxSize:    maxSize,
+		defaultTTL: config.DefaultTTL,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// isCacheable reports whether responses to the method can be cached.
+func isCacheable(method HTTPMethod) bool {
+	return method == HTTPMethods.Get || method == HTTPMethods.Head
+}
+
+// hasCredentials reports whether the request headers carry credentials (Authorization or Cookie).
+// Responses to such requests are personal, and cache keys don't include the headers, so they aren't cached.
+func hasCredentials(headers map[string]string) bool {
+	return getHeader(headers, "Authorization") != "" || getHeader(headers, "Cookie") != ""
+}
+
+// get returns a copy of the cached result for the key, or nil if it's missing or expired.
+func (cache *responseCache) get(key string) *RestResult {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	element := cache.entries[key]
+	if element == nil {
+		cache.misses.Add(1)
+		return nil
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		cache.misses.Add(1)
+		return nil
+	}
+
+	cache.order.MoveToFront(element)
+	cache.hits.Add(1)
+	result := entry.result
+	return &result
+}
+
+// put stores a successful result, using the TTL given by the response Cache-Control header.
+func (cache *responseCache) put(key string, result *RestResult, header http.Header) {
+	if result.Code < 200 || result.Code >= 300 {
+		return
+	}
+	ttl := cacheTTL(header, cache.defaultTTL)
+	if ttl <= 0 {
+		return
+	}
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	entry := &cacheEntry{key: key, result: *result, expiresAt: time.Now().Add(ttl)}
+	if element := cache.entries[key]; element != nil {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(entry)
+
+	// evict the least recently used entries
+	for cache.order.Len() > cache.maxSize {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheTTL returns how long a response may be cached according to its Cache-Control header.
+// no-store and no-cache disable caching, max-age overrides the default TTL.
+func cacheTTL(header http.Header, defaultTTL time.Duration) time.Duration {
+	ttl := defaultTTL
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "no-cache":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+			if err == nil {
+				ttl = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return ttl
+}
@@ -2,13 +2,17 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"math/rand"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
@@ -19,18 +23,26 @@ import (
 
 // ThriftClient implements the APIClient interface for Thrift protocol communication.
 type ThriftClient[T any] struct {
-	// adr is the address of the Thrift server in host:port format
-	adr string
+	// balancer picks the Thrift server address (host:port) of each call
+	balancer *addressBalancer
 	// timeout is the maximum duration to wait for a request to complete
 	timeout time.Duration
+	// connectTimeout is the maximum duration to wait for a connection to be established
+	connectTimeout time.Duration
 	// maxConnection is the maximum number of concurrent connections to maintain
 	maxConnection int
 	// maxRetry is the maximum number of retry attempts for failed requests
 	maxRetry int
 	// waitToRetry is the duration to wait between retry attempts
 	waitToRetry time.Duration
-	// cons is a map of connection IDs to ThriftCon objects
-	cons map[string]*ThriftCon
+	// retryBackoffFactor multiplies the wait after each retry attempt, constant waits when 1 or less
+	retryBackoffFactor float64
+	// retryOnErrorCodes lists the response error codes worth retrying
+	retryOnErrorCodes map[string]bool
+	// cons maps each server address to its connection pool, keyed by connection ID
+	cons map[string]map[string]*ThriftCon
+	// lastConnID is the ID of the last pooled connection, incremented for each new one
+	lastConnID atomic.Int64
 	// debug enables debug logging when true
 	debug bool
 	// lock is a mutex for thread-safe access to the connections map
@@ -39,6 +51,38 @@ type ThriftClient[T any] struct {
 	maxAge int
 	// skipUnmarshal when true, keeps response data as string format
 	skipUnmarshal bool
+	// disableResponseCompression when true, doesn't ask the server to compress the response content
+	disableResponseCompression bool
+	// transport is the Thrift transport layer, matching the server's
+	transport string
+	// methodName is the Thrift method called, matching the server's ("call" when empty)
+	methodName string
+	// requestMapper maps the requests to Thrift requests, DefaultThriftRequestMapper when nil
+	requestMapper func(sdk.APIRequest) *thriftapi.APIRequest
+	// connAcquireTimeout is the maximum duration to wait for a free connection when the pool is full
+	connAcquireTimeout time.Duration
+	// connAcquireRetries is the number of attempts to pick a free connection within connAcquireTimeout
+	connAcquireRetries int
+	// connHealthCheckPath is the path called to check pooled connections before reusing them, no check when empty
+	connHealthCheckPath string
+	// connHealthCheckTimeout is how long the connection health check waits for an answer
+	connHealthCheckTimeout time.Duration
+	// singleConnection when true, uses one long-lived connection instead of the pool
+	singleConnection bool
+	// singleCon is the long-lived connection used in single connection mode
+	singleCon *ThriftCon
+	// pingLock guards the cached result of Ping
+	pingLock *sync.Mutex
+	// pingAt is when the backend was last pinged
+	pingAt time.Time
+	// pingErr is the result of the last ping
+	pingErr error
+	// poolStats accumulates the time spent acquiring pooled connections
+	poolStats poolStats
+	// marshaler decodes the JSON content of responses, encoding/json is used when nil
+	marshaler common.Marshaler
+	// maxContentSize caps the size in bytes of the content of compressed responses once decoded
+	maxContentSize int64
 
 	config *APIClientConfiguration
 }
@@ -49,6 +93,8 @@ type ThriftCon struct {
 	Client *thriftapi.APIServiceClient
 	// socket is the underlying transport for the connection
 	socket *thrift.TTransport
+	// tsocket is the TCP socket below the transport layers
+	tsocket *thrift.TSocket
 	// inUsed indicates whether the connection is currently being used
 	inUsed bool
 	// hasError indicates whether the connection has encountered an error
@@ -57,6 +103,8 @@ type ThriftCon struct {
 	lock *sync.Mutex
 	// id is the unique identifier for this connection
 	id string
+	// adr is the server address this connection is opened to
+	adr string
 	// createdTime is when this connection was created
 	createdTime time.Time
 }
@@ -76,18 +124,121 @@ func NewThriftClient[T any](config *APIClientConfiguration) *ThriftClient[T] {
 		skipUnmarshal = *config.KeepDataStringFormat
 	}
 
+	// Default to 10 attempts over 100ms to pick a free connection
+	connAcquireTimeout := config.ConnAcquireTimeout
+	if connAcquireTimeout <= 0 {
+		connAcquireTimeout = 100 * time.Millisecond
+	}
+	connAcquireRetries := config.ConnAcquireRetries
+	if connAcquireRetries <= 0 {
+		connAcquireRetries = 10
+	}
+	connHealthCheckTimeout := config.ConnHealthCheckTimeout
+	if connHealthCheckTimeout <= 0 {
+		connHealthCheckTimeout = 100 * time.Millisecond
+	}
+
+	// Compressed content is bounded by the message size, not once decoded
+	maxContentSize := config.MaxResponseBodySize
+	if maxContentSize <= 0 {
+		maxContentSize = DefaultMaxDecodedContentSize
+	}
+
+	// Spread calls across Addresses when set
+	addresses := config.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{config.Address}
+	}
+
 	// Create and return a new ThriftClient with the provided configuration
 	return &ThriftClient[T]{
-		adr:           config.Address,
-		timeout:       config.Timeout,
-		maxConnection: config.MaxConnection,
-		maxRetry:      config.MaxRetry,
-		waitToRetry:   config.WaitToRetry,
-		cons:          make(map[string]*ThriftCon),
-		lock:          &sync.Mutex{},
-		maxAge:        600, // Default max age of 10 minutes
-		skipUnmarshal: skipUnmarshal,
+		balancer:       newAddressBalancer(addresses),
+		timeout:        config.Timeout,
+		connectTimeout: config.connectTimeout(),
+		maxConnection:  config.MaxConnection,
+		maxRetry:       config.MaxRetry,
+		waitToRetry:    config.WaitToRetry,
+		cons:           make(map[string]map[string]*ThriftCon),
+		lock:           &sync.Mutex{},
+		maxAge:         600, // Default max age of 10 minutes
+		skipUnmarshal:  skipUnmarshal,
+		transport:      config.ThriftTransport,
+		methodName:     config.ThriftMethodName,
+		requestMapper:  config.RequestMapper,
+
+		disableResponseCompression: config.DisableResponseCompression,
+
+		retryOnErrorCodes:  errorCodeSet(config.RetryOnErrorCodes),
+		retryBackoffFactor: config.RetryBackoffFactor,
+
+		connAcquireTimeout: connAcquireTimeout,
+		connAcquireRetries: connAcquireRetries,
+		singleConnection:   config.SingleConnection,
+		pingLock:           &sync.Mutex{},
+
+		connHealthCheckPath:    config.ConnHealthCheckPath,
+		connHealthCheckTimeout: connHealthCheckTimeout,
+
+		marshaler:      config.Marshaler,
+		maxContentSize: maxContentSize,
+	}
+}
+
+// Stats returns the connection pool stats of the client since it was created or since the last ResetStats:
+// acquisitions, waits and timeouts, the average and maximum acquisition time and the current connection counts.
+// Calls in single connection mode don't use the pool and aren't counted.
+//
+// Returns:
+//   - A snapshot of the stats
+func (client *ThriftClient[T]) Stats() PoolStats {
+	stats := client.poolStats.snapshot()
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	for _, pool := range client.cons {
+		for _, con := range pool {
+			stats.OpenConnections++
+			if con.inUsed {
+				stats.InUseConnections++
+			}
+		}
+	}
+	return stats
+}
+
+// ResetStats clears the cumulative connection pool stats of the client.
+func (client *ThriftClient[T]) ResetStats() {
+	client.poolStats.reset()
+}
+
+// pingCacheDuration is how long the result of Ping is reused before the backend is checked again.
+const pingCacheDuration = time.Second
+
+// Ping verifies the backend is reachable by opening a connection to it, e.g. for readiness probes.
+// With several addresses, the backend is reachable when any of them accepts the connection.
+// The result is cached for a second so frequent probes don't hammer the backend.
+//
+// Returns:
+//   - nil if the backend is reachable, otherwise the connection error
+func (client *ThriftClient[T]) Ping() error {
+	client.pingLock.Lock()
+	defer client.pingLock.Unlock()
+
+	if !client.pingAt.IsZero() && time.Since(client.pingAt) < pingCacheDuration {
+		return client.pingErr
 	}
+
+	client.pingErr = nil
+	for _, adr := range client.balancer.addresses {
+		socket := thrift.NewTSocketFromAddrConf(thriftAddr(adr), &thrift.TConfiguration{ConnectTimeout: client.connectTimeout})
+		client.pingErr = socket.Open()
+		if client.pingErr == nil {
+			socket.Close()
+			break
+		}
+		client.pingErr = errors.New("Thrift endpoint " + adr + " is unreachable: " + client.pingErr.Error())
+	}
+	client.pingAt = time.Now()
+	return client.pingErr
 }
 
 // SetDebug enables or disables debug logging for the ThriftClient.
@@ -98,28 +249,43 @@ func (client *ThriftClient[T]) SetDebug(val bool) {
 	client.debug = val
 }
 
+// thriftAddr resolves the server address, a TCP host:port or a Unix socket address.
+func thriftAddr(adr string) net.Addr {
+	if path, ok := unixSocketPath(adr); ok {
+		return &net.UnixAddr{Name: path, Net: "unix"}
+	}
+	addr, _ := net.ResolveTCPAddr("tcp", adr)
+	return addr
+}
+
 // newThriftCon creates a new Thrift connection to the server.
 //
+// Parameters:
+//   - adr: The server address in host:port format, or a Unix socket address like "unix:///var/run/api.sock"
+//
 // Returns:
 //   - A pointer to a new ThriftCon instance
-func (client *ThriftClient[T]) newThriftCon() *ThriftCon {
+func (client *ThriftClient[T]) newThriftCon(adr string) *ThriftCon {
 	// Create a binary protocol factory
 	protocolFactory := thrift.NewTBinaryProtocolFactoryDefault()
 
-	// Resolve the server address
-	addr, _ := net.ResolveTCPAddr("tcp", client.adr)
-
 	// Create a socket transport with timeout configuration
-	var transport thrift.TTransport
-	transport = thrift.NewTSocketFromAddrConf(addr, &thrift.TConfiguration{
-		ConnectTimeout: client.timeout,
+	socket := thrift.NewTSocketFromAddrConf(thriftAddr(adr), &thrift.TConfiguration{
+		ConnectTimeout: client.connectTimeout,
 		SocketTimeout:  client.timeout,
 	},
 	)
-
-	// Create a framed transport with buffering
-	transportFactory := thrift.NewTFramedTransportFactory(thrift.NewTBufferedTransportFactory(8192))
-	transport, _ = transportFactory.GetTransport(transport)
+	var transport thrift.TTransport = socket
+
+	// Wrap the socket with the configured transport layer, framed with buffering by default
+	switch client.transport {
+	case common.ThriftTransport.BUFFERED:
+		transport = thrift.NewTBufferedTransport(transport, 8192)
+	case common.ThriftTransport.PLAIN:
+	default:
+		transportFactory := thrift.NewTFramedTransportFactory(thrift.NewTBufferedTransportFactory(8192))
+		transport, _ = transportFactory.GetTransport(transport)
+	}
 
 	// Get input and output protocols
 	iprot := protocolFactory.GetProtocol(transport)
@@ -128,44 +294,82 @@ func (client *ThriftClient[T]) newThriftCon() *ThriftCon {
 	// Open the transport connection
 	transport.Open()
 
+	// Call the configured method name instead of the generated "call"
+	var tClient thrift.TClient = thrift.NewTStandardClient(iprot, oprot)
+	if client.methodName != "" && client.methodName != "call" {
+		tClient = &renamedClient{TClient: tClient, method: client.methodName}
+	}
+
 	// Create and return a new ThriftCon
 	return &ThriftCon{
 		socket:      &transport,
-		Client:      thriftapi.NewAPIServiceClient(thrift.NewTStandardClient(iprot, oprot)),
+		tsocket:     socket,
+		Client:      thriftapi.NewAPIServiceClient(tClient),
 		inUsed:      false,
 		lock:        &sync.Mutex{},
 		hasError:    false,
 		createdTime: time.Now(),
+		adr:         adr,
 	}
 }
 
+// renamedClient calls every method under a fixed method name,
+// so the generated client can reach a server serving the API under another name.
+type renamedClient struct {
+	thrift.TClient
+	method string
+}
+
+// Call implements thrift.TClient.
+func (c *renamedClient) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	return c.TClient.Call(ctx, c.method, args, result)
+}
+
 // pickCon selects an available connection from the pool or creates a new one.
 //
 // Parameters:
 //   - useOld: When true, tries to reuse an existing connection before creating a new one
+//   - adr: The server address whose pool is used
 //
 // Returns:
 //   - A pointer to a ThriftCon that is ready to use, or nil if no connection could be obtained
-func (client *ThriftClient[T]) pickCon(useOld bool) *ThriftCon {
+func (client *ThriftClient[T]) pickCon(useOld bool, adr string) *ThriftCon {
+	client.lock.Lock()
+	pool := client.cons[adr]
+	if pool == nil {
+		pool = make(map[string]*ThriftCon)
+		client.cons[adr] = pool
+	}
+	client.lock.Unlock()
+
 	if useOld {
 		client.lock.Lock()
-		for conID, con := range client.cons {
-			// verify if connection is free
+		for conID, con := range pool {
+			// verify if connection is free, only free connections are checked
+			// since the connectivity check blocks while a call is reading from the socket
 			con.lock.Lock()
-			if (*con.socket).IsOpen() {
-				if !con.inUsed {
+			if !con.inUsed {
+				if (*con.socket).IsOpen() {
 					con.inUsed = true
 					con.lock.Unlock()
 					client.lock.Unlock()
+
+					// an open socket may still be dead when the peer vanished without closing it
+					if client.connHealthCheckPath != "" && !client.isAlive(con) {
+						client.lock.Lock()
+						(*con.socket).Close()
+						delete(pool, conID)
+						client.lock.Unlock()
+						return client.pickCon(true, adr)
+					}
 					return con
 				}
-			} else {
-				delete(client.cons, conID)
+				delete(pool, conID)
 				(*con.socket).Close()
 			}
 			con.lock.Unlock()
 		}
-		if len(client.cons) < client.maxConnection || client.maxConnection == 0 {
+		if len(pool) < client.maxConnection || client.maxConnection == 0 {
 			useOld = false
 		}
 
@@ -175,20 +379,16 @@ func (client *ThriftClient[T]) pickCon(useOld bool) *ThriftCon {
 	if !useOld {
 
 		// if not find any available connection, create new
-		con := client.newThriftCon()
+		con := client.newThriftCon(adr)
 		con.inUsed = true
 
-		// append to connection pool if have space
-		if len(client.cons) < client.maxConnection {
-			id := rand.Intn(999999999) + 1000000000
-			for client.cons[strconv.Itoa(id)] != nil {
-				id = rand.Intn(999999999) + 1000000000
-			}
-			con.id = strconv.Itoa(id)
-			client.lock.Lock()
-			client.cons[con.id] = con
-			client.lock.Unlock()
+		// append to connection pool if have space, ids come from a counter so they never collide
+		client.lock.Lock()
+		if len(pool) < client.maxConnection {
+			con.id = strconv.FormatInt(client.lastConnID.Add(1), 10)
+			pool[con.id] = con
 		}
+		client.lock.Unlock()
 
 		return con
 	}
@@ -196,6 +396,31 @@ func (client *ThriftClient[T]) pickCon(useOld bool) *ThriftCon {
 	return nil
 }
 
+// isAlive checks the connection answers a call to the health check path within the health check timeout.
+// Any answer counts, including errors sent by the server like an unknown path.
+//
+// Parameters:
+//   - con: The connection to check, reserved by the caller
+//
+// Returns:
+//   - true if the connection is alive
+func (client *ThriftClient[T]) isAlive(con *ThriftCon) bool {
+	con.tsocket.SetSocketTimeout(client.connHealthCheckTimeout)
+	defer con.tsocket.SetSocketTimeout(client.timeout)
+
+	_, err := con.Client.Call(context.Background(), &thriftapi.APIRequest{
+		Method: "GET",
+		Path:   client.connHealthCheckPath,
+	})
+	if err != nil && !isApplicationError(err) {
+		if client.debug {
+			fmt.Println(" +++ Discarding dead Thrift connection " + con.id + " to " + con.adr + ": " + err.Error())
+		}
+		return false
+	}
+	return true
+}
+
 // call makes a Thrift API call with the given request.
 // It handles connection management and error handling.
 //
@@ -209,26 +434,43 @@ func (client *ThriftClient[T]) pickCon(useOld bool) *ThriftCon {
 func (client *ThriftClient[T]) call(req sdk.APIRequest, useNewCon bool) (*thriftapi.APIResponse, error) {
 
 	// map to thrift request
-	var r = &thriftapi.APIRequest{
-		Path:    req.GetPath(),
-		Params:  req.GetParams(),
-		Headers: req.GetHeaders(),
-		Method:  req.GetMethod().Value,
+	var r *thriftapi.APIRequest
+	if client.requestMapper != nil {
+		r = client.requestMapper(req)
+		if r == nil {
+			return nil, errors.New("request mapper returned no Thrift request")
+		}
+	} else {
+		r = DefaultThriftRequestMapper(req)
 	}
 
-	if r.Method != "GET" && r.Method != "DELETE" {
-		r.Content = req.GetContentText()
+	// ask for compressed content, decoded by decodeThriftContent; the headers belong to the caller's request
+	if !client.disableResponseCompression {
+		headers := make(map[string]string, len(r.Headers)+1)
+		for key, value := range r.Headers {
+			headers[key] = value
+		}
+		headers["Accept-Encoding"] = acceptEncoding
+		r.Headers = headers
+	}
+
+	if client.singleConnection {
+		return client.callSingle(r)
 	}
 
-	// pick available connection
+	// pick available connection, waiting up to connAcquireTimeout for one to be freed
+	var adr = client.balancer.pick()
 	var con *ThriftCon
-	con = client.pickCon(!useNewCon)
+	acquireStart := time.Now()
+	con = client.pickCon(!useNewCon, adr)
 	var retryToGetCon = 0
-	for retryToGetCon < 10 && con == nil {
-		time.Sleep(10 * time.Millisecond)
-		con = client.pickCon(!useNewCon)
+	var waitToGetCon = client.connAcquireTimeout / time.Duration(client.connAcquireRetries)
+	for retryToGetCon < client.connAcquireRetries && con == nil {
+		time.Sleep(waitToGetCon)
+		con = client.pickCon(!useNewCon, adr)
 		retryToGetCon++
 	}
+	client.poolStats.record(time.Since(acquireStart), retryToGetCon > 0, con != nil)
 
 	if con == nil {
 		return &thriftapi.APIResponse{
@@ -236,33 +478,103 @@ func (client *ThriftClient[T]) call(req sdk.APIRequest, useNewCon bool) (*thrift
 			Message: "Connection pool is temporary overloaded!",
 		}, &common.Error{ErrorCode: "OVERLOAD", Message: "Connection pool is overloaded! Fail to make request to " + req.GetPath()}
 	}
+	client.traceCon(r, con)
 	result, err := con.Client.Call(context.Background(), r)
 
 	// verify error
 	if err == nil {
+		client.balancer.markSucceeded(adr)
 		if con.createdTime.Add(time.Duration(client.maxAge) * time.Second).Before(time.Now()) {
 			// if too old, replace this con by new con
 			client.lock.Lock()
 			(*con.socket).Close()
 			id := con.id
-			con = client.newThriftCon()
-			client.cons[id] = con
+			con = client.newThriftCon(adr)
+			con.id = id
+			client.cons[adr][id] = con
 			client.lock.Unlock()
 		}
 		con.inUsed = false
 	} else {
+		if !isApplicationError(err) {
+			client.balancer.markFailed(adr)
+		}
 
 		// remove connection from pool
 		con.hasError = true
 		client.lock.Lock()
 		(*con.socket).Close()
-		delete(client.cons, con.id)
+		delete(client.cons[adr], con.id)
 		client.lock.Unlock()
 	}
 
 	return result, err
 }
 
+// ConnIDHeader is the request header carrying the id of the Thrift connection used, sent in debug mode.
+const ConnIDHeader = "X-Conn-Id"
+
+// traceCon logs the connection used by a call with its age and stamps its id on the request
+// in the X-Conn-Id header, when debug is enabled. This helps correlating failures with connections.
+// Connections outside the pool (when it's full, or in single connection mode) have no id and are reported as "temporary" or "single".
+//
+// Parameters:
+//   - r: The Thrift request about to be sent
+//   - con: The connection used for the call
+func (client *ThriftClient[T]) traceCon(r *thriftapi.APIRequest, con *ThriftCon) {
+	if !client.debug {
+		return
+	}
+
+	connID := con.id
+	if connID == "" {
+		connID = "temporary"
+		if client.singleConnection {
+			connID = "single"
+		}
+	}
+	fmt.Println(" +++ Thrift call " + r.Method + " " + r.Path + " to " + con.adr + " on connection " + connID +
+		", age " + time.Since(con.createdTime).Round(time.Millisecond).String())
+
+	// copy the headers, they belong to the caller's request
+	headers := make(map[string]string, len(r.Headers)+1)
+	for key, value := range r.Headers {
+		headers[key] = value
+	}
+	headers[ConnIDHeader] = connID
+	r.Headers = headers
+}
+
+// callSingle makes a Thrift API call through the single long-lived connection.
+// Calls are serialized, and the connection is re-established if it's closed or the previous call failed.
+//
+// Parameters:
+//   - r: The Thrift request to send
+//
+// Returns:
+//   - A pointer to a thriftapi.APIResponse containing the response
+//   - An error if the call fails
+func (client *ThriftClient[T]) callSingle(r *thriftapi.APIRequest) (*thriftapi.APIResponse, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if client.singleCon == nil || !(*client.singleCon.socket).IsOpen() {
+		client.singleCon = client.newThriftCon(client.balancer.pick())
+	}
+
+	client.traceCon(r, client.singleCon)
+	result, err := client.singleCon.Client.Call(context.Background(), r)
+	if err != nil {
+		// drop the connection, the next call reconnects
+		if !isApplicationError(err) {
+			client.balancer.markFailed(client.singleCon.adr)
+		}
+		(*client.singleCon.socket).Close()
+		client.singleCon = nil
+	}
+	return result, err
+}
+
 // MakeRequest implements the APIClient interface method for making API requests.
 // It handles retries and error handling for Thrift service calls.
 //
@@ -272,44 +584,246 @@ func (client *ThriftClient[T]) call(req sdk.APIRequest, useNewCon bool) (*thrift
 // Returns:
 //   - A pointer to a common.APIResponse containing the response
 func (client *ThriftClient[T]) MakeRequest(req sdk.APIRequest) *common.APIResponse[T] {
-	now := time.Now()
-	canRetry := client.maxRetry
-	result, err := client.call(req, false)
+	return makeThriftRequest[T](client, req)
+}
 
-	// free retry immediately if connection is not open or last connection was failed
+// MakeRequestInto makes the API request like MakeRequest, decoding the response data into the target
+// instead of the client's type T, so one client can call endpoints returning different shapes.
+// The target must be a pointer: to a slice to receive every data item, or to an object to receive the first one.
+//
+// Parameters:
+//   - req: The API request to process
+//   - target: A pointer receiving the response data
+//
+// Returns:
+//   - A pointer to a common.APIResponse with the response status and message, without data
+func (client *ThriftClient[T]) MakeRequestInto(req sdk.APIRequest, target interface{}) *common.APIResponse[any] {
+	return decodeInto(makeThriftRequest[json.RawMessage](client, req), target, client.marshaler)
+}
+
+// Do makes a request with the method given as a string, e.g. chosen at runtime or a custom method
+// like PURGE, building it from the options. It's a shortcut for MakeRequest with an OutboundAPIRequest.
+//
+// Parameters:
+//   - method: The request method, any valid HTTP method token
+//   - path: The path of the API endpoint
+//   - opts: The query parameters, headers and body of the request
+//
+// Returns:
+//   - A pointer to a common.APIResponse containing the response, or an INVALID one if the request can't be built
+func (client *ThriftClient[T]) Do(method string, path string, opts RequestOptions) *common.APIResponse[T] {
+	req, err := buildRequest(method, path, opts)
 	if err != nil {
+		return invalidRequestResponse[T](err)
+	}
+	return client.MakeRequest(req)
+}
 
-		errMsg := strings.ToLower(err.Error())
-		if (strings.Contains(errMsg, "connection not open") || strings.Contains(errMsg, "eof") ||
-			strings.Contains(errMsg, "connection timed out") || strings.Contains(errMsg, "i/o timeout") ||
-			strings.HasPrefix(errMsg, "overload") || strings.Contains(errMsg, "broken pipe")) && time.Now().Before(now.Add(10*time.Millisecond)) {
-			result, err = client.call(req, true)
-		}
+// DefaultThriftRequestMapper maps a request to the Thrift request sent by the Thrift client, copying its path,
+// params, headers and method, and its content unless the method has no body (GET, DELETE).
+// A custom RequestMapper can call it and adjust the result.
+func DefaultThriftRequestMapper(req sdk.APIRequest) *thriftapi.APIRequest {
+	r := &thriftapi.APIRequest{
+		Path:    req.GetPath(),
+		Params:  req.GetParams(),
+		Headers: req.GetHeaders(),
+		Method:  req.GetMethod().Value,
+	}
+	if sendsBody(r.Method) {
+		r.Content = req.GetContentText()
+	}
+	return r
+}
+
+// sendsBody reports whether the Thrift client sends the content of requests with the method.
+// GET and DELETE requests have no body; QUERY requests, although safe like GET, carry the query in their body.
+func sendsBody(method string) bool {
+	return method != "GET" && method != "DELETE"
+}
+
+// isStaleConnError reports whether the error comes from the connection rather than the server,
+// e.g. a pooled connection closed by the server or a firewall while idle.
+// An overloaded pool isn't a stale connection: a new connection can't be opened right away either.
+func isStaleConnError(err error) bool {
+	errMsg := strings.ToLower(err.Error())
+	return strings.Contains(errMsg, "connection not open") || strings.Contains(errMsg, "eof") ||
+		strings.Contains(errMsg, "connection timed out") || strings.Contains(errMsg, "i/o timeout") ||
+		strings.Contains(errMsg, "broken pipe")
+}
+
+// makeThriftRequest implements MakeRequest, decoding the response data items as R.
+func makeThriftRequest[R any, T any](client *ThriftClient[T], req sdk.APIRequest) *common.APIResponse[R] {
+	canRetry := client.maxRetry
+	result, err := client.call(req, false)
+
+	// the first connection failure gets one free retry right away on a new connection,
+	// since it's usually a stale pooled connection rather than an unavailable server
+	if err != nil && isStaleConnError(err) {
+		result, err = client.call(req, true)
 	}
 
-	// retry if failed
-	for err != nil && canRetry > 0 {
-		time.Sleep(client.waitToRetry)
+	// retry if failed, application exceptions are returned by the server so retrying won't help,
+	// or if the response carries a retryable error code
+	wait := client.waitToRetry
+	for canRetry > 0 && ((err != nil && !isApplicationError(err)) || (err == nil && client.retryOnErrorCodes[result.GetErrorCode()])) {
+		time.Sleep(wait)
+		if client.retryBackoffFactor > 1 {
+			wait = time.Duration(float64(wait) * client.retryBackoffFactor)
+		}
 		canRetry--
 		result, err = client.call(req, true)
 	}
 
 	if err != nil {
-		return &common.APIResponse[T]{
+		return fromThriftError[R](err)
+	}
+	if err := decodeThriftContent(result, client.maxContentSize); err != nil {
+		var coded *common.Error
+		if errors.As(err, &coded) {
+			return &common.APIResponse[R]{
+				Status:    common.APIStatus.Error,
+				Message:   coded.Message,
+				ErrorCode: coded.ErrorCode,
+			}
+		}
+		return &common.APIResponse[R]{
 			Status:  common.APIStatus.Error,
-			Message: "Endpoint error: " + err.Error(),
+			Message: "Response Data Error: " + err.Error(),
 		}
 	}
 
 	// parse result
-	resp := &common.APIResponse[T]{
-		Status:    result.GetStatus().String(),
+	resp := &common.APIResponse[R]{
+		Status:    fromThriftStatus(result.GetStatus()),
 		Message:   result.GetMessage(),
 		Headers:   result.GetHeaders(),
 		Total:     result.GetTotal(),
 		ErrorCode: result.GetErrorCode(),
-		Data:      []T{},
+		Data:      []R{},
+	}
+
+	// raw content isn't JSON, it's returned as-is when R is []byte or string
+	if result.GetHeaders()[common.RawContentHeader] == "true" {
+		if raw, ok := any([]byte(result.GetContent())).(R); ok {
+			resp.Data = []R{raw}
+		} else if raw, ok := any(result.GetContent()).(R); ok {
+			resp.Data = []R{raw}
+		}
+		return resp
+	}
+
+	marshaler := client.marshaler
+	if marshaler == nil {
+		marshaler = common.StdMarshaler
+	}
+	// object responses carry the object itself rather than an array
+	content := bytes.TrimSpace([]byte(result.GetContent()))
+	if len(content) > 0 && content[0] != '[' && !bytes.Equal(content, []byte("null")) {
+		var item R
+		if marshaler.Unmarshal(content, &item) == nil {
+			resp.Data = []R{item}
+			resp.SingleObject = true
+		}
+		return resp
 	}
-	json.Unmarshal([]byte(result.GetContent()), &resp.Data)
+	marshaler.Unmarshal(content, &resp.Data)
 	return resp
 }
+
+// DefaultMaxDecodedContentSize is the maximum size in bytes of the content of compressed Thrift responses
+// once decoded, when MaxResponseBodySize isn't set.
+const DefaultMaxDecodedContentSize = 32 << 20
+
+// decodeThriftContent decompresses the content of a response flagged with a Content-Encoding header,
+// failing with a RESPONSE_TOO_LARGE error when it exceeds limit bytes once decoded.
+func decodeThriftContent(result *thriftapi.APIResponse, limit int64) error {
+	encoding := result.GetHeaders()["Content-Encoding"]
+	if encoding == "" {
+		return nil
+	}
+	decode := findDecoder(encoding)
+	if decode == nil {
+		return errors.New("unsupported content encoding " + encoding)
+	}
+	reader, err := decode([]byte(result.GetContent()))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	// read one more byte than allowed to detect oversized content
+	content, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) > limit {
+		return common.NewError("RESPONSE_TOO_LARGE", "response content exceeds the maximum size of "+strconv.FormatInt(limit, 10)+" bytes once decoded")
+	}
+	result.Content = string(content)
+	delete(result.Headers, "Content-Encoding")
+	return nil
+}
+
+// fromThriftStatus converts a Thrift response status to an API status.
+// Status values unknown to this client, e.g. sent by a newer server, are reported as errors.
+func fromThriftStatus(status thriftapi.Status) string {
+	if _, err := thriftapi.StatusFromString(status.String()); err != nil {
+		return common.APIStatus.Error
+	}
+	return status.String()
+}
+
+// applicationError describes how a Thrift application exception type is reported to callers.
+type applicationError struct {
+	status    string
+	errorCode string
+}
+
+// applicationErrors maps Thrift application exception types to response statuses and error codes.
+var applicationErrors = map[int32]applicationError{
+	thrift.UNKNOWN_METHOD:                 {common.APIStatus.NotFound, "UNKNOWN_METHOD"},
+	thrift.INVALID_MESSAGE_TYPE_EXCEPTION: {common.APIStatus.Error, "INVALID_MESSAGE_TYPE"},
+	thrift.WRONG_METHOD_NAME:              {common.APIStatus.Error, "WRONG_METHOD_NAME"},
+	thrift.BAD_SEQUENCE_ID:                {common.APIStatus.Error, "BAD_SEQUENCE_ID"},
+	thrift.MISSING_RESULT:                 {common.APIStatus.Error, "MISSING_RESULT"},
+	thrift.INTERNAL_ERROR:                 {common.APIStatus.Error, "INTERNAL_ERROR"},
+	thrift.PROTOCOL_ERROR:                 {common.APIStatus.Invalid, "PROTOCOL_ERROR"},
+	thrift.INVALID_TRANSFORM:              {common.APIStatus.Invalid, "INVALID_TRANSFORM"},
+	thrift.INVALID_PROTOCOL:               {common.APIStatus.Invalid, "INVALID_PROTOCOL"},
+	thrift.UNSUPPORTED_CLIENT_TYPE:        {common.APIStatus.Invalid, "UNSUPPORTED_CLIENT_TYPE"},
+}
+
+// isApplicationError reports whether err is a Thrift application exception sent by the server,
+// as opposed to a transport or network failure.
+func isApplicationError(err error) bool {
+	var appErr thrift.TApplicationException
+	return errors.As(err, &appErr)
+}
+
+// fromThriftError converts a failed Thrift call into an APIResponse.
+// Application exceptions are mapped to specific statuses and error codes,
+// other errors are reported as endpoint errors.
+//
+// Parameters:
+//   - err: The error returned by the Thrift call
+//
+// Returns:
+//   - A pointer to a common.APIResponse describing the failure
+func fromThriftError[T any](err error) *common.APIResponse[T] {
+	var appErr thrift.TApplicationException
+	if errors.As(err, &appErr) {
+		mapped, ok := applicationErrors[appErr.TypeId()]
+		if !ok {
+			mapped = applicationError{common.APIStatus.Error, "UNKNOWN_APPLICATION_EXCEPTION"}
+		}
+		return &common.APIResponse[T]{
+			Status:    mapped.status,
+			Message:   "Endpoint application error: " + err.Error(),
+			ErrorCode: mapped.errorCode,
+		}
+	}
+
+	return &common.APIResponse[T]{
+		Status:  common.APIStatus.Error,
+		Message: "Endpoint error: " + err.Error(),
+	}
+}
@@ -50,3 +50,43 @@ func ParseError(err error) *Error {
 	}
 	return NewError(parts[0], parts[1])
 }
+
+// NotFoundError creates an Error with the NOT_FOUND code, sent with the NotFound status.
+func NotFoundError(message string) *Error {
+	return NewError(APIStatus.NotFound, message)
+}
+
+// InvalidError creates an Error with the INVALID code, sent with the Invalid status.
+func InvalidError(message string) *Error {
+	return NewError(APIStatus.Invalid, message)
+}
+
+// ForbiddenError creates an Error with the FORBIDDEN code, sent with the Forbidden status.
+func ForbiddenError(message string) *Error {
+	return NewError(APIStatus.Forbidden, message)
+}
+
+// UnauthorizedError creates an Error with the UNAUTHORIZED code, sent with the Unauthorized status.
+func UnauthorizedError(message string) *Error {
+	return NewError(APIStatus.Unauthorized, message)
+}
+
+// ExistedError creates an Error with the EXISTED code, sent with the Existed status.
+func ExistedError(message string) *Error {
+	return NewError(APIStatus.Existed, message)
+}
+
+// PreconditionFailedError creates an Error with the PRECONDITION_FAILED code, sent with the PreconditionFailed status.
+func PreconditionFailedError(message string) *Error {
+	return NewError(APIStatus.PreconditionFailed, message)
+}
+
+// TimeoutError creates an Error with the TIMEOUT code, sent with the Timeout status.
+func TimeoutError(message string) *Error {
+	return NewError(APIStatus.Timeout, message)
+}
+
+// InternalError creates an Error with the INTERNAL_SERVER_ERROR code, sent with the Error status.
+func InternalError(message string) *Error {
+	return NewError("INTERNAL_SERVER_ERROR", message)
+}
@@ -0,0 +1,31 @@
+package common
+
+import "encoding/json"
+
+// Marshaler encodes and decodes JSON payloads. Servers and clients use encoding/json by default;
+// a faster library like jsoniter or sonic can be plugged in without forking, e.g.
+//
+//	type sonicMarshaler struct{}
+//
+//	func (sonicMarshaler) Marshal(v interface{}) ([]byte, error)      { return sonic.Marshal(v) }
+//	func (sonicMarshaler) Unmarshal(data []byte, v interface{}) error { return sonic.Unmarshal(data, v) }
+type Marshaler interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StdMarshaler is the Marshaler backed by encoding/json.
+var StdMarshaler Marshaler = stdMarshaler{}
+
+// stdMarshaler implements Marshaler with encoding/json.
+type stdMarshaler struct{}
+
+// Marshal implements Marshaler.
+func (stdMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal implements Marshaler.
+func (stdMarshaler) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
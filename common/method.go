@@ -17,6 +17,8 @@ type MethodEnum struct {
 	PATCH   *MethodValue // HTTP PATCH method
 	DELETE  *MethodValue // HTTP DELETE method
 	OPTIONS *MethodValue // HTTP OPTIONS method
+	TRACE   *MethodValue // HTTP TRACE method
+	CONNECT *MethodValue // HTTP CONNECT method
 }
 
 // APIMethod is a published enum containing predefined HTTP method values.
@@ -29,4 +31,43 @@ var APIMethod = MethodEnum{
 	PATCH:   &MethodValue{Value: "PATCH"},
 	DELETE:  &MethodValue{Value: "DELETE"},
 	OPTIONS: &MethodValue{Value: "OPTIONS"},
+	TRACE:   &MethodValue{Value: "TRACE"},
+	CONNECT: &MethodValue{Value: "CONNECT"},
+}
+
+// Equals reports whether two method values represent the same method.
+// Methods are compared by their string value, so enum pointers and freshly
+// constructed values for the same method are equal.
+func (method *MethodValue) Equals(other *MethodValue) bool {
+	if method == nil || other == nil {
+		return method == other
+	}
+	return method.Value == other.Value
+}
+
+// MethodFromString returns the canonical APIMethod enum value for a known method string.
+// For unknown methods, a new MethodValue holding the given string is returned.
+func MethodFromString(s string) *MethodValue {
+	switch s {
+	case APIMethod.GET.Value:
+		return APIMethod.GET
+	case APIMethod.QUERY.Value:
+		return APIMethod.QUERY
+	case APIMethod.POST.Value:
+		return APIMethod.POST
+	case APIMethod.PUT.Value:
+		return APIMethod.PUT
+	case APIMethod.PATCH.Value:
+		return APIMethod.PATCH
+	case APIMethod.DELETE.Value:
+		return APIMethod.DELETE
+	case APIMethod.OPTIONS.Value:
+		return APIMethod.OPTIONS
+	case APIMethod.TRACE.Value:
+		return APIMethod.TRACE
+	case APIMethod.CONNECT.Value:
+		return APIMethod.CONNECT
+	}
+
+	return &MethodValue{Value: s}
 }
@@ -14,3 +14,19 @@ var Protocol = ProtocolEnum{
 	HTTP:   "HTTP",
 	THRIFT: "THRIFT",
 }
+
+// ThriftTransportEnum defines a structure containing supported Thrift transport layers.
+// Client and server must use the same transport to be able to communicate.
+type ThriftTransportEnum struct {
+	FRAMED   string // Framed transport on top of a buffered transport (default)
+	BUFFERED string // Buffered transport without framing
+	PLAIN    string // Raw socket transport without buffering or framing
+}
+
+// ThriftTransport is a published enum containing predefined Thrift transport values.
+// An empty value means the default FRAMED transport.
+var ThriftTransport = ThriftTransportEnum{
+	FRAMED:   "FRAMED",
+	BUFFERED: "BUFFERED",
+	PLAIN:    "PLAIN",
+}
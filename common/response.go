@@ -2,11 +2,29 @@
 package common
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"reflect"
 	"strings"
 )
 
+// RawContentHeader is the response header flagging raw (non-JSON) content sent with RespondRaw.
+// Clients must not JSON-decode responses carrying it.
+const RawContentHeader = "X-Raw-Content"
+
+// TotalCountHeader is the response header carrying the Total of responses, e.g. for HEAD count probes
+// whose body is dropped.
+const TotalCountHeader = "X-Total-Count"
+
+// NextCursorHeader is the response header carrying the cursor of the next page of a paginated result.
+// It's absent from the last page.
+const NextCursorHeader = "X-Next-Cursor"
+
+// CursorParam is the query parameter carrying the cursor of the requested page, as sent by the client
+// when iterating over pages.
+const CursorParam = "cursor"
+
 // APIResponse represents a standardized response object with JSON format.
 // It provides a consistent structure for all API responses, including success and error cases.
 // The generic type parameter T allows for type-safe data handling.
@@ -17,6 +35,65 @@ type APIResponse[T any] struct {
 	ErrorCode string            `json:"error_code,omitempty"` // Error code in case of failure
 	Total     int64             `json:"total,omitempty"`      // Total count of items (for pagination)
 	Headers   map[string]string `json:"headers,omitempty"`    // Response headers
+
+	// SingleObject makes responders serialize the only Data item as an object rather than a one-element array.
+	// It's set by NewObjectResponse.
+	SingleObject bool `json:"-"`
+}
+
+// apiResponseJSON is the JSON shape of an APIResponse, keeping the data raw so it can be an array or an object.
+type apiResponseJSON struct {
+	Status    string            `json:"status"`
+	Data      json.RawMessage   `json:"data,omitempty"`
+	Message   string            `json:"message"`
+	ErrorCode string            `json:"error_code,omitempty"`
+	Total     int64             `json:"total,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting both shapes of data: an array ("data": [...])
+// and a single object ("data": {...}), sent by NewObjectResponse or upstreams returning one object.
+// A single object is decoded as a one-element Data, with SingleObject set.
+func (resp *APIResponse[T]) UnmarshalJSON(content []byte) error {
+	return resp.unmarshal(content, StdMarshaler)
+}
+
+// UnmarshalResponse decodes a JSON response with the marshaler, encoding/json when nil. Both the envelope
+// and the data are decoded with it, accepting the two shapes of data like APIResponse.UnmarshalJSON.
+func UnmarshalResponse[T any](content []byte, marshaler Marshaler) (*APIResponse[T], error) {
+	if marshaler == nil {
+		marshaler = StdMarshaler
+	}
+	resp := &APIResponse[T]{}
+	return resp, resp.unmarshal(content, marshaler)
+}
+
+// unmarshal decodes the JSON response with the marshaler, see UnmarshalJSON.
+func (resp *APIResponse[T]) unmarshal(content []byte, marshaler Marshaler) error {
+	var decoded apiResponseJSON
+	if err := marshaler.Unmarshal(content, &decoded); err != nil {
+		return err
+	}
+	resp.Status = decoded.Status
+	resp.Message = decoded.Message
+	resp.ErrorCode = decoded.ErrorCode
+	resp.Total = decoded.Total
+	resp.Headers = decoded.Headers
+
+	data := bytes.TrimSpace(decoded.Data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if data[0] == '[' {
+		return marshaler.Unmarshal(data, &resp.Data)
+	}
+	var item T
+	if err := marshaler.Unmarshal(data, &item); err != nil {
+		return err
+	}
+	resp.Data = []T{item}
+	resp.SingleObject = true
+	return nil
 }
 
 // ToAnyResponse converts a typed APIResponse to a generic APIResponse with 'any' type.
@@ -33,6 +110,8 @@ func (resp *APIResponse[T]) ToAnyResponse() *APIResponse[any] {
 		ErrorCode: resp.ErrorCode,
 		Total:     resp.Total,
 		Headers:   resp.Headers,
+
+		SingleObject: resp.SingleObject,
 	}
 }
 
@@ -41,13 +120,14 @@ func (resp *APIResponse[T]) ToAnyResponse() *APIResponse[any] {
 // If the error is nil, it returns a success response.
 func FromError(err error) *APIResponse[any] {
 
+	// Handle custom Error type, returned by value or as a pointer by NewError and the status helpers
+	var pe *Error
+	if errors.As(err, &pe) && pe != nil {
+		return NewErrorResponse(statusFromErrorCode(pe.ErrorCode), pe.ErrorCode, pe.Message)
+	}
 	var e Error
 	if errors.As(err, &e) {
-		// Handle custom Error type
-		if e.ErrorCode == "NOT_FOUND" {
-			return NewErrorResponse(APIStatus.NotFound, e.ErrorCode, e.Message)
-		}
-		return NewErrorResponse(APIStatus.NotFound, e.ErrorCode, e.Message)
+		return NewErrorResponse(statusFromErrorCode(e.ErrorCode), e.ErrorCode, e.Message)
 	}
 
 	if err != nil {
@@ -58,34 +138,69 @@ func FromError(err error) *APIResponse[any] {
 			return NewErrorResponse(APIStatus.Error, "INTERNAL_SERVER_ERROR", err.Error())
 		}
 		errorCode := msgParts[0]
-
-		// Map error codes to appropriate response statuses
-		if errorCode == "NOT_FOUND" {
-			return NewErrorResponse(APIStatus.NotFound, errorCode, msgParts[1])
-		}
-		if strings.HasPrefix(errorCode, "INVALID") {
-			return NewErrorResponse(APIStatus.Invalid, errorCode, msgParts[1])
-		}
-		if strings.HasPrefix(errorCode, "EXISTED") {
-			return NewErrorResponse(APIStatus.Existed, errorCode, msgParts[1])
-		}
-		if strings.HasPrefix(errorCode, "FORBIDDEN") {
-			return NewErrorResponse(APIStatus.Forbidden, errorCode, msgParts[1])
-		}
-		if strings.HasPrefix(errorCode, "UNAUTHORIZED") {
-			return NewErrorResponse(APIStatus.Unauthorized, errorCode, msgParts[1])
-		}
-		if strings.HasPrefix(errorCode, "REDIRECTED") {
-			return NewErrorResponse(APIStatus.Redirected, errorCode, msgParts[1])
-		}
-
-		// Default error response
-		return NewErrorResponse(APIStatus.Error, errorCode, msgParts[1])
+		return NewErrorResponse(statusFromErrorCode(errorCode), errorCode, msgParts[1])
 	}
 	// No error, return success response
 	return NewOkResponse(nil, "Success")
 }
 
+// statusFromErrorCode maps an error code to the matching response status.
+// Codes are matched by prefix (e.g. INVALID_EMAIL is Invalid), unknown codes map to Error.
+func statusFromErrorCode(errorCode string) string {
+	if errorCode == "NOT_FOUND" {
+		return APIStatus.NotFound
+	}
+	if strings.HasPrefix(errorCode, "INVALID") {
+		return APIStatus.Invalid
+	}
+	if strings.HasPrefix(errorCode, "EXISTED") {
+		return APIStatus.Existed
+	}
+	if strings.HasPrefix(errorCode, "FORBIDDEN") {
+		return APIStatus.Forbidden
+	}
+	if strings.HasPrefix(errorCode, "UNAUTHORIZED") {
+		return APIStatus.Unauthorized
+	}
+	if strings.HasPrefix(errorCode, "REDIRECTED") {
+		return APIStatus.Redirected
+	}
+	if strings.HasPrefix(errorCode, "PRECONDITION_FAILED") {
+		return APIStatus.PreconditionFailed
+	}
+	if strings.HasPrefix(errorCode, "TIMEOUT") {
+		return APIStatus.Timeout
+	}
+	return APIStatus.Error
+}
+
+// AsError converts a failed response into an Error carrying its error code and message,
+// so client code can write `if err := resp.AsError(); err != nil`.
+// It returns nil when the status is Ok, or PartialSuccess whose failures are reported per item.
+// When the response has no error code, the status is used instead. This is the inverse of FromError.
+func (resp *APIResponse[T]) AsError() error {
+	if resp == nil || resp.Status == APIStatus.Ok || resp.Status == APIStatus.PartialSuccess {
+		return nil
+	}
+	errorCode := resp.ErrorCode
+	if errorCode == "" {
+		errorCode = resp.Status
+	}
+	return Error{
+		ErrorCode: errorCode,
+		Message:   resp.Message,
+	}
+}
+
+// GetNextCursor returns the cursor of the next page sent in the NextCursorHeader header,
+// or an empty string on the last page.
+func (resp *APIResponse[T]) GetNextCursor() string {
+	if resp == nil {
+		return ""
+	}
+	return resp.Headers[NextCursorHeader]
+}
+
 // NewAPIResponse creates a new APIResponse with the specified parameters.
 // It handles both array and single-item data by ensuring the Data field is always an array.
 // If data is already a slice, it's used directly; otherwise, it's wrapped in a single-element array.
@@ -113,6 +228,45 @@ func NewAPIResponse(status string, data []any, message string, errorCode string,
 	}
 }
 
+// NewObjectResponse creates a new APIResponse keeping the shape of the data as provided.
+// Unlike NewAPIResponse, a single object is serialized as an object ("data": {...}) rather than
+// a one-element array, for APIs whose data is an object. Slices are still serialized as arrays.
+func NewObjectResponse(status string, data any, message string, errorCode string, total int64, headers map[string]string) *APIResponse[any] {
+	resp := &APIResponse[any]{
+		Status:    status,
+		Message:   message,
+		ErrorCode: errorCode,
+		Total:     total,
+		Headers:   headers,
+	}
+	if data == nil {
+		return resp
+	}
+
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Slice {
+		resp.Data = make([]any, value.Len())
+		for i := range resp.Data {
+			resp.Data[i] = value.Index(i).Interface()
+		}
+		return resp
+	}
+
+	resp.Data = []any{data}
+	resp.SingleObject = true
+	return resp
+}
+
+// NewCountResponse creates an OK response carrying only a total count, without data,
+// for count endpoints like HEAD /items. The total is sent in the body and the X-Total-Count header.
+func NewCountResponse(total int64) *APIResponse[any] {
+	return &APIResponse[any]{
+		Status: APIStatus.Ok,
+		Data:   []any{},
+		Total:  total,
+	}
+}
+
 // NewErrorResponse creates an error response with the specified status, error code, and message.
 // It returns an APIResponse with no data, focusing on the error information.
 func NewErrorResponse(status string, errorCode string, message string) *APIResponse[any] {
@@ -144,28 +298,93 @@ func NewOkResponse(data []any, message string) *APIResponse[any] {
 	}
 }
 
+// ItemResult is the outcome of one item of a batch operation, carried in the Data of multi-status responses.
+type ItemResult struct {
+	Status    string `json:"status"`               // Item status (e.g. "OK", "INVALID")
+	Data      any    `json:"data,omitempty"`       // Item result data
+	Message   string `json:"message,omitempty"`    // Human-readable message
+	ErrorCode string `json:"error_code,omitempty"` // Error code in case of failure
+}
+
+// NewItemResult creates the result of a successful batch item.
+func NewItemResult(data any) ItemResult {
+	return ItemResult{Status: APIStatus.Ok, Data: data}
+}
+
+// NewItemError creates the result of a failed batch item from its error, like FromError.
+func NewItemError(err error) ItemResult {
+	resp := FromError(err)
+	return ItemResult{Status: resp.Status, Message: resp.Message, ErrorCode: resp.ErrorCode}
+}
+
+// NewMultiStatusResponse creates the response of a batch operation, with the result of each item as Data
+// in the order of the items. The status is OK when every item succeeded, PARTIAL_SUCCESS (HTTP 207) when
+// some items succeeded and others failed: clients must then check the status of each item rather than treat
+// the response as failed. When every item failed, the batch failed: the status (and error code) is the one
+// shared by the items, or ERROR when they differ.
+func NewMultiStatusResponse(results []ItemResult, message string) *APIResponse[any] {
+	resp := &APIResponse[any]{
+		Status:  APIStatus.Ok,
+		Data:    make([]any, len(results)),
+		Message: message,
+		Total:   int64(len(results)),
+	}
+	succeeded, failed := 0, 0
+	for i, result := range results {
+		if result.Status == APIStatus.Ok {
+			succeeded++
+		} else {
+			failed++
+		}
+		resp.Data[i] = result
+	}
+
+	switch {
+	case failed == 0:
+	case succeeded > 0:
+		resp.Status = APIStatus.PartialSuccess
+	default:
+		resp.Status, resp.ErrorCode = results[0].Status, results[0].ErrorCode
+		for _, result := range results[1:] {
+			if result.Status != resp.Status {
+				resp.Status = APIStatus.Error
+			}
+			if result.ErrorCode != resp.ErrorCode {
+				resp.ErrorCode = ""
+			}
+		}
+	}
+	return resp
+}
+
 // StatusEnum defines a structure containing all possible API response status values.
 // These statuses are used to indicate the result of an API operation.
 type StatusEnum struct {
-	Ok           string // Successful operation
-	Error        string // General error
-	Invalid      string // Invalid input or request
-	NotFound     string // Requested resource not found
-	Forbidden    string // Access denied
-	Existed      string // Resource already exists
-	Unauthorized string // Authentication required
-	Redirected   string // Request redirected
+	Ok                 string // Successful operation
+	PartialSuccess     string // Batch operation with per-item statuses, some items failed (see NewMultiStatusResponse)
+	Error              string // General error
+	Invalid            string // Invalid input or request
+	NotFound           string // Requested resource not found
+	Forbidden          string // Access denied
+	Existed            string // Resource already exists
+	Unauthorized       string // Authentication required
+	Redirected         string // Request redirected
+	PreconditionFailed string // Conditional request (e.g. If-Match) not matching the current resource version
+	Timeout            string // Request not handled in time by the server
 }
 
 // APIStatus is a published enum containing predefined status values.
 // It provides a consistent way to set response statuses throughout the application.
 var APIStatus = &StatusEnum{
-	Ok:           "OK",
-	Error:        "ERROR",
-	Invalid:      "INVALID",
-	NotFound:     "NOT_FOUND",
-	Forbidden:    "FORBIDDEN",
-	Existed:      "EXISTED",
-	Unauthorized: "UNAUTHORIZED",
-	Redirected:   "REDIRECTED",
+	Ok:                 "OK",
+	PartialSuccess:     "PARTIAL_SUCCESS",
+	Error:              "ERROR",
+	Invalid:            "INVALID",
+	NotFound:           "NOT_FOUND",
+	Forbidden:          "FORBIDDEN",
+	Existed:            "EXISTED",
+	Unauthorized:       "UNAUTHORIZED",
+	Redirected:         "REDIRECTED",
+	PreconditionFailed: "PRECONDITION_FAILED",
+	Timeout:            "TIMEOUT",
 }
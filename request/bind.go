@@ -0,0 +1,106 @@
+package request
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// bindInput implements APIRequest.BindInput: it decodes the JSON body into dest, then sets the fields
+// tagged `query:"name"` from the query parameters and the fields tagged `param:"name"` from the path
+// parameters. Path and query parameters take precedence over the body. Fields of embedded structs are bound too.
+func bindInput(req APIRequest, dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.New("bind destination must be a non-nil pointer to a struct")
+	}
+
+	if req.GetContentText() != "" {
+		if err := req.ParseBody(dest); err != nil {
+			var e *common.Error
+			if errors.As(err, &e) {
+				return err
+			}
+			return common.NewError("INVALID_BODY", "request body can't be decoded: "+err.Error())
+		}
+	}
+	return bindFields(req, value.Elem())
+}
+
+// bindFields sets the tagged fields of the struct from the query and path parameters.
+func bindFields(req APIRequest, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := bindFields(req, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		raw := ""
+		if name := field.Tag.Get("query"); name != "" {
+			raw = req.GetParam(name)
+		}
+		if name := field.Tag.Get("param"); name != "" {
+			if value := req.GetVar(name); value != "" {
+				raw = value
+			}
+		}
+		if raw == "" {
+			continue
+		}
+		if err := setField(v.Field(i), raw); err != nil {
+			return common.NewError("INVALID_PARAM", "invalid value \""+raw+"\" for "+field.Name+": "+err.Error())
+		}
+	}
+	return nil
+}
+
+// setField parses the raw parameter value into the field, allocating pointer fields.
+func setField(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return errors.New("unsupported field type " + field.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package request
+
+import (
+	"net"
+	"strings"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// ForwardedForHeader is the header carrying the chain of client and proxy IPs of a proxied request.
+const ForwardedForHeader = "X-Forwarded-For"
+
+// TrustedProxiesAttribute is the request attribute holding the TrustedProxies applied by GetIP.
+const TrustedProxiesAttribute = "TrustedProxies"
+
+// TrustedProxies lists the networks of the proxies allowed to set the X-Forwarded-For header.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of CIDRs (e.g. "10.0.0.0/8") or single IPs into TrustedProxies.
+// It returns an INVALID_CIDR error for the first entry that can't be parsed.
+func ParseTrustedProxies(cidrs []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		// a single IP is a network of one address
+		if ip := net.ParseIP(cidr); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, common.NewError("INVALID_CIDR", "invalid trusted proxy \""+cidr+"\"")
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// contains reports whether the IP belongs to a trusted proxy network.
+func (proxies TrustedProxies) contains(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range proxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client from the X-Forwarded-For chain (client, proxy1, proxy2, ...),
+// or from the socket peer address when there is no valid entry. IPv4 and IPv6 addresses are supported,
+// with or without a port.
+//
+// Without trusted proxies, the left-most valid entry of the chain is used. With trusted proxies, the chain
+// is only honored when the peer is trusted; it's then walked from the right, skipping trusted proxies,
+// so entries spoofed by the client can't be returned. An empty remoteAddr (unknown peer) skips the peer check.
+func clientIP(remoteAddr string, forwarded string, trusted TrustedProxies) string {
+	peer := parseIP(remoteAddr)
+	if trusted == nil {
+		for _, entry := range strings.Split(forwarded, ",") {
+			if ip := parseIP(entry); ip != "" {
+				return ip
+			}
+		}
+		return peer
+	}
+
+	if remoteAddr != "" && !trusted.contains(peer) {
+		return peer
+	}
+	entries := strings.Split(forwarded, ",")
+	leftMost := ""
+	for i := len(entries) - 1; i >= 0; i-- {
+		ip := parseIP(entries[i])
+		if ip == "" {
+			continue
+		}
+		if !trusted.contains(ip) {
+			return ip
+		}
+		leftMost = ip
+	}
+	// every hop is a trusted proxy
+	if leftMost != "" {
+		return leftMost
+	}
+	return peer
+}
+
+// trustedProxies returns the TrustedProxies stored in the request attributes, or nil when none are set.
+func trustedProxies(req APIRequest) TrustedProxies {
+	proxies, _ := req.GetAttribute(TrustedProxiesAttribute).(TrustedProxies)
+	return proxies
+}
+
+// parseIP extracts the IP from an address like "10.0.0.1", "10.0.0.1:80", "::1" or "[::1]:80".
+// Returns an empty string when the address isn't a valid IP.
+func parseIP(address string) string {
+	address = strings.TrimSpace(address)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
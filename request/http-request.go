@@ -1,8 +1,13 @@
 package request
 
 import (
-	"encoding/json"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/labstack/echo"
@@ -15,6 +20,8 @@ type HTTPAPIRequest struct {
 	t       string       // Protocol type identifier
 	context echo.Context // The underlying Echo framework context
 	body    string       // Cached request body content
+	raw     []byte       // Cached request body bytes, as received
+	bodyErr error        // Error met while decoding the request body
 }
 
 // NewHTTPAPIRequest creates a new HTTP API request wrapper around an echo.Context.
@@ -32,36 +39,36 @@ func (req *HTTPAPIRequest) GetPath() string {
 }
 
 // GetMethod returns the HTTP method as a common.MethodValue.
-// It maps standard HTTP methods to the application's method enum values.
+// It maps standard HTTP methods to the application's method enum values via common.MethodFromString,
+// including the custom QUERY method used for dynamic routing.
 func (req *HTTPAPIRequest) GetMethod() *common.MethodValue {
-	var s = req.context.Request().Method
-	switch s {
-	case "GET":
-		return common.APIMethod.GET
-	case "POST":
-		return common.APIMethod.POST
-	case "PUT":
-		return common.APIMethod.PUT
-	case "PATCH":
-		return common.APIMethod.PATCH
-	case "OPTIONS":
-		return common.APIMethod.OPTIONS
-	case "DELETE":
-		return common.APIMethod.DELETE
-	}
-
-	return &common.MethodValue{Value: s}
+	return common.MethodFromString(req.context.Request().Method)
 }
 
-// GetVar retrieves a path parameter by name from the Echo context.
+// varsAttribute is the Echo context key of the path variables set with SetVar. They're kept in the
+// context rather than the wrapper, so the ones set in PreRequest are visible to the route handler.
+const varsAttribute = "request.vars"
+
+// GetVar retrieves a path parameter by name, the value set with SetVar taking precedence
+// over the one matched by the router.
 func (req *HTTPAPIRequest) GetVar(name string) string {
+	if vars, ok := req.context.Get(varsAttribute).(map[string]string); ok {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+	}
 	return req.context.Param(name)
 }
 
-// SetVar sets a path parameter value.
-// Note: This is a no-op in the HTTP implementation as Echo doesn't support setting path parameters.
+// SetVar sets a path parameter value, overriding the one matched by the router.
+// It's stored in the Echo context, shared by every wrapper of the request.
 func (req *HTTPAPIRequest) SetVar(name string, value string) {
-
+	vars, ok := req.context.Get(varsAttribute).(map[string]string)
+	if !ok {
+		vars = make(map[string]string)
+		req.context.Set(varsAttribute, vars)
+	}
+	vars[name] = value
 }
 
 // GetParam retrieves a query parameter by name from the request URL.
@@ -81,19 +88,40 @@ func (req *HTTPAPIRequest) GetParams() map[string]string {
 }
 
 // ParseBody unmarshals the request body into the provided interface.
-// It uses JSON unmarshaling to parse the request body content.
+// It uses JSON unmarshaling (the Marshaler set on the request, encoding/json by default) to parse the request body content, rejecting bodies
+// that exceed the JSONLimits set on the request with an INVALID_JSON error.
+// Bodies that can't be decompressed are rejected with an INVALID_CONTENT_ENCODING error, the ones exceeding
+// the maximum decoded size with an INVALID_BODY_SIZE error.
 func (req *HTTPAPIRequest) ParseBody(data interface{}) error {
+	content := req.GetContentText()
+	if req.bodyErr != nil {
+		return req.bodyErr
+	}
+	return parseJSON(content, data, jsonLimits(req), marshaler(req))
+}
 
-	return json.Unmarshal([]byte(req.GetContentText()), data)
+// BindInput populates the struct from the path and query parameters and the body. See APIRequest.BindInput.
+func (req *HTTPAPIRequest) BindInput(dest interface{}) error {
+	return bindInput(req, dest)
 }
 
 // GetContentText returns the raw request body as a string.
 // It lazily loads and caches the body content on first access.
+// Bodies sent with Content-Encoding gzip or deflate are decompressed transparently, up to the maximum
+// decoded size (DefaultMaxDecodedBodySize unless configured); larger bodies are rejected by ParseBody
+// with an INVALID_BODY_SIZE error and read as empty.
 func (req *HTTPAPIRequest) GetContentText() string {
 	if req.body == "" {
-		var bodyBytes []byte
-		if req.context.Request().Body != nil {
-			bodyBytes, _ = io.ReadAll(req.context.Request().Body)
+		bodyBytes := req.GetContentBytes()
+
+		encoding := req.context.Request().Header.Get(echo.HeaderContentEncoding)
+		if len(bodyBytes) > 0 && encoding != "" {
+			decoded, err := decodeBody(bodyBytes, encoding, maxDecodedBodySize(req))
+			if err != nil {
+				req.bodyErr = err
+			} else {
+				bodyBytes = decoded
+			}
 		}
 
 		req.body = string(bodyBytes)
@@ -102,6 +130,70 @@ func (req *HTTPAPIRequest) GetContentText() string {
 	return req.body
 }
 
+// GetContentBytes returns the request body verbatim, before Content-Encoding decoding.
+// The body is put back on the HTTP request once read, so the other wrappers of the request
+// (e.g. the PreRequest one and the handler one) can read it again.
+func (req *HTTPAPIRequest) GetContentBytes() []byte {
+	if req.raw == nil {
+		req.raw = []byte{}
+		if body := req.context.Request().Body; body != nil {
+			req.raw, _ = io.ReadAll(body)
+			req.context.Request().Body = io.NopCloser(bytes.NewReader(req.raw))
+		}
+	}
+	return req.raw
+}
+
+// DefaultMaxDecodedBodySize is the maximum size in bytes of a compressed request body once decoded,
+// when the server doesn't configure one. It keeps small compressed bodies from expanding without bound.
+const DefaultMaxDecodedBodySize = 32 << 20
+
+// MaxDecodedBodySizeAttribute is the request attribute holding the maximum decoded body size (an int64).
+const MaxDecodedBodySizeAttribute = "MaxDecodedBodySize"
+
+// maxDecodedBodySize returns the maximum decoded body size stored in the request attributes,
+// or DefaultMaxDecodedBodySize when none is set.
+func maxDecodedBodySize(req APIRequest) int64 {
+	if limit, ok := req.GetAttribute(MaxDecodedBodySizeAttribute).(int64); ok && limit > 0 {
+		return limit
+	}
+	return DefaultMaxDecodedBodySize
+}
+
+// decodeBody decompresses a request body according to its Content-Encoding, reading at most limit bytes.
+// Unknown encodings (e.g. identity) are returned as-is. Bodies that can't be decoded get an
+// INVALID_CONTENT_ENCODING error, bodies larger than limit once decoded an INVALID_BODY_SIZE error.
+func decodeBody(body []byte, encoding string, limit int64) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// deflate is meant to be zlib-wrapped, but some clients send raw deflate data
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body, nil
+	}
+	if err != nil {
+		return nil, common.NewError("INVALID_CONTENT_ENCODING", "request body can't be decoded as "+encoding+": "+err.Error())
+	}
+	defer reader.Close()
+
+	// one byte past the limit tells bodies of exactly limit bytes from larger ones
+	decoded, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, common.NewError("INVALID_CONTENT_ENCODING", "request body can't be decoded as "+encoding+": "+err.Error())
+	}
+	if int64(len(decoded)) > limit {
+		return nil, common.NewError("INVALID_BODY_SIZE", "request body exceeds "+strconv.FormatInt(limit, 10)+" bytes once decoded")
+	}
+	return decoded, nil
+}
+
 // GetHeader retrieves a specific HTTP header value by name.
 func (req *HTTPAPIRequest) GetHeader(name string) string {
 	return req.context.Request().Header.Get(name)
@@ -129,16 +221,30 @@ func (req *HTTPAPIRequest) SetAttribute(name string, value interface{}) {
 }
 
 // GetIP returns the client's IP address.
-// It first checks for X-Forwarded-For header (for proxied requests),
-// then falls back to the remote address from the request.
+// It first checks the X-Forwarded-For chain (for proxied requests), taking its left-most valid IP,
+// then falls back to the remote address of the request. IPv4 and IPv6 addresses are supported.
+// When the server has trusted proxies, the chain is only honored if the remote address is a trusted
+// proxy, and its right-most IP that isn't a trusted proxy is returned.
 func (req *HTTPAPIRequest) GetIP() string {
-	// for forwarded case
-	forwarded := req.GetHeader("X-Forwarded-For")
-	if forwarded == "" {
-		httpReq := req.context.Request()
-		return strings.Split(httpReq.RemoteAddr, ":")[0]
+	return clientIP(req.context.Request().RemoteAddr, req.GetHeader(ForwardedForHeader), trustedProxies(req))
+}
+
+// GetRequestID returns the request ID assigned by the server,
+// falling back to the incoming X-Request-Id header.
+func (req *HTTPAPIRequest) GetRequestID() string {
+	if id, ok := req.GetAttribute(RequestIDAttribute).(string); ok && id != "" {
+		return id
 	}
+	return req.GetHeader(RequestIDHeader)
+}
+
+// Context returns the context of the underlying HTTP request.
+// It's canceled when the client closes the connection.
+func (req *HTTPAPIRequest) Context() context.Context {
+	return req.context.Request().Context()
+}
 
-	splitted := strings.Split(forwarded, ",")
-	return splitted[0]
+// GetRaw returns the underlying echo.Context of the request.
+func (req *HTTPAPIRequest) GetRaw() interface{} {
+	return req.context
 }
@@ -3,9 +3,17 @@
 package request
 
 import (
+	"context"
+
 	"github.com/phnam/go-protocol-adapter/common"
 )
 
+// RequestIDHeader is the header carrying the request ID used to correlate logs across services.
+const RequestIDHeader = "X-Request-Id"
+
+// RequestIDAttribute is the attribute name under which servers store the request ID.
+const RequestIDAttribute = "X-Request-Id"
+
 // APIRequest defines the interface for all request types in the application.
 // It provides protocol-agnostic methods to access request data regardless of the underlying transport.
 type APIRequest interface {
@@ -33,6 +41,16 @@ type APIRequest interface {
 	// GetContentText returns the raw request body as a string
 	GetContentText() string
 
+	// GetContentBytes returns the request body verbatim, as received before any Content-Encoding decoding,
+	// e.g. to verify a signature over it. Reading it doesn't keep the body from being read again.
+	GetContentBytes() []byte
+
+	// BindInput populates a struct from the request in one call: the JSON body is decoded into it, then the
+	// fields tagged `query:"page"` are set from the query parameters and the fields tagged `param:"id"` from
+	// the path parameters, which take precedence over the body. Parameters that can't be converted to the
+	// field type fail with an INVALID_PARAM error, bodies that can't be decoded with an INVALID_BODY error.
+	BindInput(dest interface{}) error
+
 	// GetAttribute retrieves a context attribute by name
 	GetAttribute(string) interface{}
 
@@ -47,4 +65,14 @@ type APIRequest interface {
 
 	// GetIP returns the client's IP address
 	GetIP() string
+
+	// GetRequestID returns the ID used to correlate this request across services
+	GetRequestID() string
+
+	// Context returns the request context, which is canceled when the client disconnects
+	Context() context.Context
+
+	// GetRaw returns the underlying protocol object of the request:
+	// echo.Context for HTTP, *thriftapi.APIRequest for Thrift and the request itself for outbound requests
+	GetRaw() interface{}
 }
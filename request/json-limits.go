@@ -0,0 +1,80 @@
+package request
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// JSONLimitsAttribute is the request attribute holding the JSONLimits applied by ParseBody.
+const JSONLimitsAttribute = "JSONLimits"
+
+// MarshalerAttribute is the request attribute holding the common.Marshaler used by ParseBody.
+const MarshalerAttribute = "Marshaler"
+
+// JSONLimits caps the shape of JSON bodies accepted by ParseBody,
+// protecting public endpoints from deeply nested or huge payloads.
+type JSONLimits struct {
+	// MaxDepth is the maximum nesting depth of objects and arrays, unlimited when 0
+	MaxDepth int
+	// MaxTokens is the maximum number of JSON tokens (delimiters, keys and values), unlimited when 0
+	MaxTokens int
+}
+
+// parseJSON unmarshals content into data with the marshaler after checking it against the limits.
+// The content is scanned token by token, so oversized payloads are rejected before
+// any value is allocated. A nil limits unmarshals without checks.
+func parseJSON(content string, data interface{}, limits *JSONLimits, marshaler common.Marshaler) error {
+	if limits != nil && (limits.MaxDepth > 0 || limits.MaxTokens > 0) {
+		if err := limits.check(content); err != nil {
+			return err
+		}
+	}
+	return marshaler.Unmarshal([]byte(content), data)
+}
+
+// check scans the JSON content and returns an INVALID_JSON error when a limit is exceeded.
+// Syntax errors are left to the unmarshaling step.
+func (limits *JSONLimits) check(content string) error {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	depth := 0
+	tokens := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// end of the content, or a syntax error reported when unmarshaling
+			return nil
+		}
+
+		tokens++
+		if limits.MaxTokens > 0 && tokens > limits.MaxTokens {
+			return common.NewError("INVALID_JSON", "JSON body exceeds the maximum of "+strconv.Itoa(limits.MaxTokens)+" tokens")
+		}
+		if delim, ok := token.(json.Delim); ok {
+			if delim == '{' || delim == '[' {
+				depth++
+				if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+					return common.NewError("INVALID_JSON", "JSON body exceeds the maximum depth of "+strconv.Itoa(limits.MaxDepth))
+				}
+			} else {
+				depth--
+			}
+		}
+	}
+}
+
+// marshaler returns the Marshaler stored in the request attributes, or common.StdMarshaler when none is set.
+func marshaler(req APIRequest) common.Marshaler {
+	if m, ok := req.GetAttribute(MarshalerAttribute).(common.Marshaler); ok {
+		return m
+	}
+	return common.StdMarshaler
+}
+
+// jsonLimits returns the JSONLimits stored in the request attributes, or nil when none are set.
+func jsonLimits(req APIRequest) *JSONLimits {
+	limits, _ := req.GetAttribute(JSONLimitsAttribute).(*JSONLimits)
+	return limits
+}
@@ -0,0 +1,172 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// NetHTTPAPIRequest implements the APIRequest interface on a standard *http.Request,
+// for the handlers mounted on a net/http router.
+type NetHTTPAPIRequest struct {
+	t          string                 // Protocol type identifier
+	request    *http.Request          // The underlying HTTP request
+	attributes map[string]interface{} // Storage for request attributes
+	variables  map[string]string      // Storage for path variables
+	body       string                 // Cached request body content
+	raw        []byte                 // Cached request body bytes, as received
+	bodyErr    error                  // Error met while decoding the request body
+}
+
+// NewNetHTTPAPIRequest creates a new HTTP API request wrapper around an *http.Request.
+// It returns an implementation of the APIRequest interface.
+func NewNetHTTPAPIRequest(r *http.Request) APIRequest {
+	return &NetHTTPAPIRequest{
+		t:          "HTTP",
+		request:    r,
+		attributes: make(map[string]interface{}),
+		variables:  map[string]string{},
+	}
+}
+
+// GetPath returns the path of the request URL.
+func (req *NetHTTPAPIRequest) GetPath() string {
+	return req.request.URL.Path
+}
+
+// GetMethod returns the HTTP method as a common.MethodValue, see HTTPAPIRequest.GetMethod.
+func (req *NetHTTPAPIRequest) GetMethod() *common.MethodValue {
+	return common.MethodFromString(req.request.Method)
+}
+
+// GetVar retrieves a path variable by name, the value set with SetVar taking precedence
+// over the wildcard matched by http.ServeMux.
+func (req *NetHTTPAPIRequest) GetVar(name string) string {
+	if value, ok := req.variables[name]; ok {
+		return value
+	}
+	return req.request.PathValue(name)
+}
+
+// SetVar sets a path variable value, overriding the one matched by the router.
+func (req *NetHTTPAPIRequest) SetVar(name string, value string) {
+	req.variables[name] = value
+}
+
+// GetParam retrieves a query parameter by name from the request URL.
+func (req *NetHTTPAPIRequest) GetParam(name string) string {
+	return req.request.URL.Query().Get(name)
+}
+
+// GetParams returns all query parameters as a map of string keys and values.
+func (req *NetHTTPAPIRequest) GetParams() map[string]string {
+	var vals = req.request.URL.Query()
+	var m = make(map[string]string)
+	for key := range vals {
+		m[key] = vals.Get(key)
+	}
+	return m
+}
+
+// ParseBody unmarshals the request body into the provided interface, see HTTPAPIRequest.ParseBody.
+func (req *NetHTTPAPIRequest) ParseBody(data interface{}) error {
+	content := req.GetContentText()
+	if req.bodyErr != nil {
+		return req.bodyErr
+	}
+	return parseJSON(content, data, jsonLimits(req), marshaler(req))
+}
+
+// BindInput populates the struct from the path and query parameters and the body. See APIRequest.BindInput.
+func (req *NetHTTPAPIRequest) BindInput(dest interface{}) error {
+	return bindInput(req, dest)
+}
+
+// GetContentText returns the request body as a string, decompressing the bodies sent with
+// Content-Encoding gzip or deflate. It lazily loads and caches the body content on first access.
+func (req *NetHTTPAPIRequest) GetContentText() string {
+	if req.body == "" {
+		bodyBytes := req.GetContentBytes()
+
+		encoding := req.request.Header.Get(echo.HeaderContentEncoding)
+		if len(bodyBytes) > 0 && encoding != "" {
+			decoded, err := decodeBody(bodyBytes, encoding, maxDecodedBodySize(req))
+			if err != nil {
+				req.bodyErr = err
+			} else {
+				bodyBytes = decoded
+			}
+		}
+
+		req.body = string(bodyBytes)
+	}
+
+	return req.body
+}
+
+// GetContentBytes returns the request body verbatim, before Content-Encoding decoding.
+// The body is put back on the HTTP request once read, so it can be read again.
+func (req *NetHTTPAPIRequest) GetContentBytes() []byte {
+	if req.raw == nil {
+		req.raw = []byte{}
+		if body := req.request.Body; body != nil {
+			req.raw, _ = io.ReadAll(body)
+			req.request.Body = io.NopCloser(bytes.NewReader(req.raw))
+		}
+	}
+	return req.raw
+}
+
+// GetHeader retrieves a specific HTTP header value by name.
+func (req *NetHTTPAPIRequest) GetHeader(name string) string {
+	return req.request.Header.Get(name)
+}
+
+// GetHeaders returns all HTTP headers as a map of string keys and values.
+func (req *NetHTTPAPIRequest) GetHeaders() map[string]string {
+	var vals = req.request.Header
+	var m = make(map[string]string)
+	for key := range vals {
+		m[key] = vals.Get(key)
+	}
+	return m
+}
+
+// GetAttribute retrieves a context attribute by name from the internal attributes map.
+func (req *NetHTTPAPIRequest) GetAttribute(name string) interface{} {
+	return req.attributes[name]
+}
+
+// SetAttribute stores a context attribute in the internal attributes map.
+func (req *NetHTTPAPIRequest) SetAttribute(name string, value interface{}) {
+	req.attributes[name] = value
+}
+
+// GetIP returns the client's IP address, see HTTPAPIRequest.GetIP.
+func (req *NetHTTPAPIRequest) GetIP() string {
+	return clientIP(req.request.RemoteAddr, req.GetHeader(ForwardedForHeader), trustedProxies(req))
+}
+
+// GetRequestID returns the request ID set in the attributes,
+// falling back to the incoming X-Request-Id header.
+func (req *NetHTTPAPIRequest) GetRequestID() string {
+	if id, ok := req.attributes[RequestIDAttribute].(string); ok && id != "" {
+		return id
+	}
+	return req.GetHeader(RequestIDHeader)
+}
+
+// Context returns the context of the underlying HTTP request.
+// It's canceled when the client closes the connection.
+func (req *NetHTTPAPIRequest) Context() context.Context {
+	return req.request.Context()
+}
+
+// GetRaw returns the underlying *http.Request.
+func (req *NetHTTPAPIRequest) GetRaw() interface{} {
+	return req.request
+}
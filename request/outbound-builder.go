@@ -0,0 +1,111 @@
+package request
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// OutboundRequestBuilder builds an OutboundAPIRequest fluently:
+//
+//	req, err := request.NewRequest("GET", "/users").WithParam("limit", "10").WithHeader("X-Tenant", "acme").Build()
+//
+// Errors (invalid method, body that can't be encoded) are reported by Build.
+type OutboundRequestBuilder struct {
+	// req is the request being built
+	req *OutboundAPIRequest
+	// err is the first error met while building
+	err error
+}
+
+// NewRequest starts building an outbound request with the given method and path.
+func NewRequest(method string, path string) *OutboundRequestBuilder {
+	return &OutboundRequestBuilder{
+		req: &OutboundAPIRequest{
+			Method: method,
+			Path:   path,
+		},
+	}
+}
+
+// WithParam sets a query parameter.
+func (b *OutboundRequestBuilder) WithParam(name string, value string) *OutboundRequestBuilder {
+	if b.req.Params == nil {
+		b.req.Params = make(map[string]string)
+	}
+	b.req.Params[name] = value
+	return b
+}
+
+// WithMultiParam adds values to a repeated query parameter (e.g. ?id=1&id=2).
+func (b *OutboundRequestBuilder) WithMultiParam(name string, values ...string) *OutboundRequestBuilder {
+	if b.req.MultiParams == nil {
+		b.req.MultiParams = make(map[string][]string)
+	}
+	b.req.MultiParams[name] = append(b.req.MultiParams[name], values...)
+	return b
+}
+
+// WithHeader sets a request header.
+func (b *OutboundRequestBuilder) WithHeader(name string, value string) *OutboundRequestBuilder {
+	if b.req.Headers == nil {
+		b.req.Headers = make(map[string]string)
+	}
+	b.req.Headers[name] = value
+	return b
+}
+
+// WithIfMatch sets the If-Match header, so the upstream only applies a PUT/PATCH/DELETE when
+// the resource still has the given ETag. Unquoted ETags are quoted; "*" and weak (W/) ETags are kept as-is.
+// A mismatch is answered with 412, reported by the client as the PRECONDITION_FAILED status.
+func (b *OutboundRequestBuilder) WithIfMatch(etag string) *OutboundRequestBuilder {
+	if etag != "*" && !strings.HasPrefix(etag, "W/") && !strings.HasPrefix(etag, "\"") {
+		etag = "\"" + etag + "\""
+	}
+	return b.WithHeader("If-Match", etag)
+}
+
+// WithBody sets the raw request body content.
+func (b *OutboundRequestBuilder) WithBody(content string) *OutboundRequestBuilder {
+	b.req.Content = content
+	return b
+}
+
+// WithJSONBody sets the request body to the JSON encoding of the given value.
+func (b *OutboundRequestBuilder) WithJSONBody(body interface{}) *OutboundRequestBuilder {
+	content, err := json.Marshal(body)
+	if err != nil {
+		if b.err == nil {
+			b.err = common.NewError("INVALID_BODY", "request body can't be encoded as JSON: "+err.Error())
+		}
+		return b
+	}
+	b.req.Content = string(content)
+	return b
+}
+
+// Build returns the built request, or the first error met while building.
+// The method must be a valid HTTP method token, e.g. GET or a custom method like PURGE.
+func (b *OutboundRequestBuilder) Build() (*OutboundAPIRequest, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if !isValidMethod(b.req.Method) {
+		return nil, common.NewError("INVALID_METHOD", "invalid request method \""+b.req.Method+"\"")
+	}
+	return b.req, nil
+}
+
+// isValidMethod reports whether the method is a non-empty HTTP token (RFC 9110).
+func isValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, c := range method {
+		if c > 127 || (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') && !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return false
+		}
+	}
+	return true
+}
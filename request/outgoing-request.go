@@ -1,6 +1,7 @@
 package request
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/phnam/go-protocol-adapter/common"
@@ -14,6 +15,10 @@ type OutboundAPIRequest struct {
 	Params  map[string]string `json:"params,omitempty" bson:"params,omitempty"`   // Query parameters
 	Headers map[string]string `json:"headers,headers" bson:"headers,omitempty"`   // HTTP headers
 	Content string            `json:"content,omitempty" bson:"content,omitempty"` // Request body content
+
+	// MultiParams holds query parameters with repeated values (e.g. ?id=1&id=2), merged with Params.
+	// It's only sent by the HTTP client; GetParams keeps returning Params.
+	MultiParams map[string][]string `json:"multiParams,omitempty" bson:"multiParams,omitempty"`
 }
 
 // NewOutboundAPIRequest creates a new outbound API request with the specified parameters.
@@ -39,27 +44,9 @@ func (req *OutboundAPIRequest) GetIP() string {
 }
 
 // GetMethod returns the request method as a common.MethodValue.
-// It maps method strings to the application's method enum values.
+// It maps method strings to the application's method enum values via common.MethodFromString.
 func (req *OutboundAPIRequest) GetMethod() *common.MethodValue {
-	var s = req.Method
-	switch s {
-	case "GET":
-		return common.APIMethod.GET
-	case "POST":
-		return common.APIMethod.POST
-	case "PUT":
-		return common.APIMethod.PUT
-	case "PATCH":
-		return common.APIMethod.PATCH
-	case "OPTIONS":
-		return common.APIMethod.OPTIONS
-	case "QUERY":
-		return common.APIMethod.QUERY
-	case "DELETE":
-		return common.APIMethod.DELETE
-	}
-
-	return &common.MethodValue{Value: s}
+	return common.MethodFromString(req.Method)
 }
 
 // GetVar retrieves a path variable/parameter by name from the params map.
@@ -85,11 +72,21 @@ func (req *OutboundAPIRequest) ParseBody(data interface{}) error {
 	return nil
 }
 
+// BindInput populates the struct from the params and the content. See APIRequest.BindInput.
+func (req *OutboundAPIRequest) BindInput(dest interface{}) error {
+	return bindInput(req, dest)
+}
+
 // GetContentText returns the raw request body as a string.
 func (req *OutboundAPIRequest) GetContentText() string {
 	return req.Content
 }
 
+// GetContentBytes returns the request body verbatim.
+func (req *OutboundAPIRequest) GetContentBytes() []byte {
+	return []byte(req.Content)
+}
+
 // GetHeader retrieves a specific header value by name.
 func (req *OutboundAPIRequest) GetHeader(name string) string {
 	return req.Headers[name]
@@ -129,3 +126,18 @@ func (req *OutboundAPIRequest) SetAttr(name string, value interface{}) {
 func (req *OutboundAPIRequest) SetVar(name string, value string) {
 	// do nothing
 }
+
+// GetRequestID returns the X-Request-Id header of the outbound request.
+func (req *OutboundAPIRequest) GetRequestID() string {
+	return req.Headers[RequestIDHeader]
+}
+
+// Context returns a background context as outbound requests aren't bound to a connection.
+func (req *OutboundAPIRequest) Context() context.Context {
+	return context.Background()
+}
+
+// GetRaw returns the outbound request itself, as there's no underlying protocol object.
+func (req *OutboundAPIRequest) GetRaw() interface{} {
+	return req
+}
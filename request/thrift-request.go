@@ -1,8 +1,7 @@
 package request
 
 import (
-	"encoding/json"
-	"strings"
+	"context"
 
 	"github.com/phnam/go-protocol-adapter/common"
 	"github.com/phnam/go-protocol-adapter/thriftapi"
@@ -15,16 +14,24 @@ type APIThriftRequest struct {
 	context    *thriftapi.APIRequest  // The underlying Thrift request
 	attributes map[string]interface{} // Storage for request attributes
 	variables  map[string]string      // Storage for path variables
+	ctx        context.Context        // Context of the Thrift call
 }
 
 // NewThriftAPIRequest creates a new Thrift API request wrapper around a thriftapi.APIRequest.
 // It returns an implementation of the APIRequest interface.
 func NewThriftAPIRequest(e *thriftapi.APIRequest) APIRequest {
+	return NewThriftAPIRequestWithContext(context.Background(), e)
+}
+
+// NewThriftAPIRequestWithContext creates a new Thrift API request wrapper bound to the context of the Thrift call.
+// It returns an implementation of the APIRequest interface.
+func NewThriftAPIRequestWithContext(ctx context.Context, e *thriftapi.APIRequest) APIRequest {
 	return &APIThriftRequest{
 		t:          "THRIFT",
 		context:    e,
 		attributes: make(map[string]interface{}),
 		variables:  map[string]string{},
+		ctx:        ctx,
 	}
 }
 
@@ -33,40 +40,17 @@ func (req *APIThriftRequest) GetPath() string {
 	return req.context.GetPath()
 }
 
-// GetIP returns the client's IP address from the X-Forwarded-For header.
+// GetIP returns the client's IP address from the X-Forwarded-For header, taking its left-most valid IP.
+// When the server has trusted proxies, its right-most IP that isn't a trusted proxy is returned instead.
 // Returns an empty string if the header is not present.
 func (req *APIThriftRequest) GetIP() string {
-	forwarded := req.GetHeader("X-Forwarded-For")
-	if forwarded == "" {
-		return ""
-	}
-
-	splitted := strings.Split(forwarded, ",")
-	return splitted[0]
+	return clientIP("", req.GetHeader(ForwardedForHeader), trustedProxies(req))
 }
 
 // GetMethod returns the request method as a common.MethodValue.
-// It maps method strings to the application's method enum values.
+// It maps method strings to the application's method enum values via common.MethodFromString.
 func (req *APIThriftRequest) GetMethod() *common.MethodValue {
-	var s = req.context.GetMethod()
-	switch s {
-	case "GET":
-		return common.APIMethod.GET
-	case "POST":
-		return common.APIMethod.POST
-	case "PUT":
-		return common.APIMethod.PUT
-	case "PATCH":
-		return common.APIMethod.PATCH
-	case "OPTIONS":
-		return common.APIMethod.OPTIONS
-	case "QUERY":
-		return common.APIMethod.QUERY
-	case "DELETE":
-		return common.APIMethod.DELETE
-	}
-
-	return &common.MethodValue{Value: s}
+	return common.MethodFromString(req.context.GetMethod())
 }
 
 // GetParam retrieves a query parameter by name from the request.
@@ -85,9 +69,15 @@ func (req *APIThriftRequest) GetParams() map[string]string {
 }
 
 // ParseBody unmarshals the request body into the provided interface.
-// It uses JSON unmarshaling to parse the request content.
+// It uses JSON unmarshaling (the Marshaler set on the request, encoding/json by default) to parse the request content, rejecting bodies
+// that exceed the JSONLimits set on the request with an INVALID_JSON error.
 func (req *APIThriftRequest) ParseBody(data interface{}) error {
-	return json.Unmarshal([]byte(req.context.Content), &data)
+	return parseJSON(req.context.Content, &data, jsonLimits(req), marshaler(req))
+}
+
+// BindInput populates the struct from the path and query parameters and the content. See APIRequest.BindInput.
+func (req *APIThriftRequest) BindInput(dest interface{}) error {
+	return bindInput(req, dest)
 }
 
 // GetContentText returns the raw request body as a string.
@@ -95,6 +85,11 @@ func (req *APIThriftRequest) GetContentText() string {
 	return req.context.Content
 }
 
+// GetContentBytes returns the request body verbatim.
+func (req *APIThriftRequest) GetContentBytes() []byte {
+	return []byte(req.context.Content)
+}
+
 // GetHeader retrieves a specific header value by name.
 // Returns an empty string if the header doesn't exist or headers are nil.
 func (req *APIThriftRequest) GetHeader(name string) string {
@@ -141,3 +136,23 @@ func (req *APIThriftRequest) GetVar(name string) string {
 func (req *APIThriftRequest) SetVar(name string, value string) {
 	req.variables[name] = value
 }
+
+// GetRequestID returns the request ID assigned by the server,
+// falling back to the incoming X-Request-Id header.
+func (req *APIThriftRequest) GetRequestID() string {
+	if id, ok := req.attributes[RequestIDAttribute].(string); ok && id != "" {
+		return id
+	}
+	return req.GetHeader(RequestIDHeader)
+}
+
+// Context returns the context of the Thrift call.
+// It's canceled when the server detects that the client connection was closed.
+func (req *APIThriftRequest) Context() context.Context {
+	return req.ctx
+}
+
+// GetRaw returns the underlying *thriftapi.APIRequest received by the Thrift server.
+func (req *APIThriftRequest) GetRaw() interface{} {
+	return req.context
+}
@@ -0,0 +1,46 @@
+package responder
+
+import (
+	"strconv"
+	"time"
+)
+
+// CacheControlHeader is the header carrying the caching directives of a response.
+const CacheControlHeader = "Cache-Control"
+
+// noCacheDirectives keeps responses from being stored by clients and intermediaries.
+const noCacheDirectives = "no-store, no-cache, must-revalidate"
+
+// CacheControl returns the Cache-Control value allowing a response to be cached for maxAge,
+// by shared caches (CDNs, proxies) when public, only by the client otherwise.
+// A maxAge of 0 or less returns the no-cache directives.
+func CacheControl(maxAge time.Duration, public bool) string {
+	if maxAge <= 0 {
+		return noCacheDirectives
+	}
+	visibility := "private"
+	if public {
+		visibility = "public"
+	}
+	return visibility + ", max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+}
+
+// SetCacheControl sets the Cache-Control header of the response. See APIResponder.SetCacheControl.
+func (resp *HTTPAPIResponder) SetCacheControl(maxAge time.Duration, public bool) {
+	resp.SetHeader(CacheControlHeader, CacheControl(maxAge, public))
+}
+
+// NoCache sets the Cache-Control header so the response isn't cached.
+func (resp *HTTPAPIResponder) NoCache() {
+	resp.SetHeader(CacheControlHeader, noCacheDirectives)
+}
+
+// SetCacheControl sets the Cache-Control header of the response. See APIResponder.SetCacheControl.
+func (responder *ThriftAPIResponder) SetCacheControl(maxAge time.Duration, public bool) {
+	responder.SetHeader(CacheControlHeader, CacheControl(maxAge, public))
+}
+
+// NoCache sets the Cache-Control header so the response isn't cached.
+func (responder *ThriftAPIResponder) NoCache() {
+	responder.SetHeader(CacheControlHeader, noCacheDirectives)
+}
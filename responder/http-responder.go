@@ -2,10 +2,13 @@ package responder
 
 import (
 	"errors"
-	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"reflect"
-	"time"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo"
 	"github.com/phnam/go-protocol-adapter/common"
@@ -18,28 +21,52 @@ type HTTPAPIResponder struct {
 	t string
 	// context is the Echo context for the current request
 	context echo.Context
-	// start tracks when the request processing began for calculating execution time
-	start time.Time
+	// executionTimer tracks the request processing time for the X-Execution-Time header
+	executionTimer
+	// respondOnce rejects responding twice to the same request, it's shared by the responders of the request
+	*respondOnce
 	// hostname stores the server hostname to include in response headers
 	hostname string
 	// funcName stores the handler function name to include in response headers
 	funcName string
+	// naming renames the struct fields of the response data, Go field names are kept when nil
+	naming NamingStrategy
+	// envelope builds the JSON body of responses, the default shape is used when nil
+	envelope EnvelopeEncoder
+	// encoders are the additional response formats by media type, negotiated with the Accept header
+	encoders map[string]BodyEncoder
+	// marshaler encodes JSON bodies, Echo's encoding/json encoder is used when nil
+	marshaler common.Marshaler
+	// preResponse is called with the response before it's serialized
+	preResponse PreResponseHook
 	// resp stores the raw response object after it's been sent
 	resp interface{}
 }
 
 // NewHTTPAPIResponder creates a new HTTP API responder with the given Echo context, hostname, and function name.
 // It initializes a timer to track execution time and returns an implementation of the APIResponder interface.
+//
+// The responders created for the same Echo context (e.g. by a pre-request handler and the main handler)
+// share whether a response was sent, so only the first one of them can respond.
 func NewHTTPAPIResponder(c echo.Context, hostname string, funcName string) APIResponder {
+	once, _ := c.Get(respondOnceKey).(*respondOnce)
+	if once == nil {
+		once = &respondOnce{}
+		c.Set(respondOnceKey, once)
+	}
 	return &HTTPAPIResponder{
-		t:        "HTTP",
-		start:    time.Now(),
-		context:  c,
-		hostname: hostname,
-		funcName: funcName,
+		t:              "HTTP",
+		executionTimer: newExecutionTimer(),
+		context:        c,
+		respondOnce:    once,
+		hostname:       hostname,
+		funcName:       funcName,
 	}
 }
 
+// respondOnceKey is the Echo context key of the respondOnce shared by the responders of a request.
+const respondOnceKey = "responder.respondOnce"
+
 // Respond processes and sends the API response to the client over HTTP.
 // It validates the response, sets appropriate headers, maps API status to HTTP status codes,
 // and sends the response as JSON (or redirects for redirected status).
@@ -47,10 +74,14 @@ func NewHTTPAPIResponder(c echo.Context, hostname string, funcName string) APIRe
 // The method performs the following steps:
 // 1. Validates that the response is not nil and data is a slice
 // 2. Copies any headers from the response to the HTTP response
-// 3. Adds execution time, hostname, and function name headers
+// 3. Adds total count, execution time, hostname, and function name headers
 // 4. Maps the API status to the appropriate HTTP status code
 // 5. Sends the response with the correct content type
 //
+// Responses created with common.NewObjectResponse have their single data item sent as an object.
+// A Content-Type header set by the handler (in the response headers or with SetHeader) is kept, and
+// the body follows it: e.g. with "text/plain", a single string data item is sent as the plain body.
+//
 // Returns an error if the response cannot be processed or sent.
 func (resp *HTTPAPIResponder) Respond(response *common.APIResponse[any]) error {
 	var context = resp.context
@@ -63,6 +94,11 @@ func (resp *HTTPAPIResponder) Respond(response *common.APIResponse[any]) error {
 		return errors.New("data response must be a slice")
 	}
 
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	response = applyPreResponse(resp.preResponse, response)
+
 	if response.Headers != nil {
 		header := context.Response().Header()
 		for key, value := range response.Headers {
@@ -71,36 +107,131 @@ func (resp *HTTPAPIResponder) Respond(response *common.APIResponse[any]) error {
 		response.Headers = nil
 	}
 
-	var dif = float64(time.Since(resp.start).Nanoseconds()) / 1000000
-	context.Response().Header().Set("X-Execution-Time", fmt.Sprintf("%.4f ms", dif))
+	if response.Total > 0 {
+		context.Response().Header().Set(common.TotalCountHeader, strconv.FormatInt(response.Total, 10))
+	}
+
+	context.Response().Header().Set("X-Execution-Time", resp.stop())
 	context.Response().Header().Set("X-Hostname", resp.hostname)
 
 	if resp.funcName != "" {
 		context.Response().Header().Set("X-Function", resp.funcName)
 	}
 
-	switch response.Status {
+	resp.resp = response
+	body := responseBody(response, resp.naming, resp.envelope)
+	code := httpStatusCode(response.Status)
+	if code == http.StatusFound {
+		return context.Redirect(code, context.Response().Header().Get("Location"))
+	}
+	return resp.send(code, response, body)
+}
+
+// send writes the response body with the status code, in the format picked by encodeBody.
+func (resp *HTTPAPIResponder) send(code int, response *common.APIResponse[any], body interface{}) error {
+	contentType, data, err := encodeBody(resp.context.Response().Header().Get(echo.HeaderContentType),
+		resp.context.Request().Header.Get(echo.HeaderAccept), resp.encoders, resp.marshaler, response, body)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		return resp.context.JSON(code, body)
+	}
+	return resp.context.Blob(code, contentType, data)
+}
+
+// encodeBody returns the content type and the encoded body of a response. The format is the one of the
+// contentType set by the handler if any, otherwise the one preferred by the accept header among JSON and
+// the additional formats. JSON is used when no format is acceptable; an empty content type is returned
+// for JSON when no marshaler is set, for the responder to use its default JSON encoder.
+func encodeBody(contentType string, accept string, encoders map[string]BodyEncoder, marshaler common.Marshaler,
+	response *common.APIResponse[any], body interface{}) (string, []byte, error) {
+	if contentType != "" {
+		return encodeBodyAs(contentType, encoders, marshaler, response, body)
+	}
+
+	if len(encoders) > 0 {
+		// JSON comes first, so it wins ties like "*/*"
+		offers := make([]string, 0, len(encoders)+1)
+		offers = append(offers, echo.MIMEApplicationJSON)
+		for contentType := range encoders {
+			offers = append(offers, contentType)
+		}
+		sort.Strings(offers[1:])
+
+		contentType = NegotiateContentType(accept, offers)
+		if encoder := encoders[contentType]; encoder != nil {
+			data, err := encoder(body)
+			return contentType, data, err
+		}
+	}
+
+	if marshaler == nil {
+		return "", nil, nil
+	}
+	data, err := marshaler.Marshal(body)
+	return echo.MIMEApplicationJSONCharsetUTF8, data, err
+}
+
+// encodeBodyAs encodes the response body for the content type set by the handler, e.g. "text/plain".
+// The body is encoded with the additional format registered for its media type, or as JSON for JSON
+// media types. Other media types send the string or []byte data of the response as-is, and fall back
+// to JSON for any other data.
+func encodeBodyAs(contentType string, encoders map[string]BodyEncoder, marshaler common.Marshaler,
+	response *common.APIResponse[any], body interface{}) (string, []byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if encoder := encoders[mediaType]; encoder != nil {
+		data, err := encoder(body)
+		return contentType, data, err
+	}
+
+	if mediaType != echo.MIMEApplicationJSON && !strings.HasSuffix(mediaType, "+json") && len(response.Data) == 1 {
+		switch data := response.Data[0].(type) {
+		case string:
+			return contentType, []byte(data), nil
+		case []byte:
+			return contentType, data, nil
+		}
+	}
+
+	if marshaler == nil {
+		marshaler = common.StdMarshaler
+	}
+	data, err := marshaler.Marshal(body)
+	return contentType, data, err
+}
+
+// httpStatusCode returns the HTTP status code of an API status, 400 Bad Request for unknown statuses.
+// Redirected responses are sent with 302 Found.
+func httpStatusCode(status string) int {
+	switch status {
 	case common.APIStatus.Ok:
-		return context.JSON(http.StatusOK, response)
+		return http.StatusOK
+	case common.APIStatus.PartialSuccess:
+		return http.StatusMultiStatus
 	case common.APIStatus.Error:
-		return context.JSON(http.StatusInternalServerError, response)
+		return http.StatusInternalServerError
 	case common.APIStatus.Forbidden:
-		return context.JSON(http.StatusForbidden, response)
+		return http.StatusForbidden
 	case common.APIStatus.Invalid:
-		return context.JSON(http.StatusBadRequest, response)
+		return http.StatusBadRequest
 	case common.APIStatus.NotFound:
-		return context.JSON(http.StatusNotFound, response)
+		return http.StatusNotFound
 	case common.APIStatus.Unauthorized:
-		return context.JSON(http.StatusUnauthorized, response)
+		return http.StatusUnauthorized
 	case common.APIStatus.Existed:
-		return context.JSON(http.StatusConflict, response)
+		return http.StatusConflict
+	case common.APIStatus.PreconditionFailed:
+		return http.StatusPreconditionFailed
+	case common.APIStatus.Timeout:
+		return http.StatusGatewayTimeout
 	case common.APIStatus.Redirected:
-		return context.Redirect(http.StatusFound, context.Response().Header().Get("Location"))
+		return http.StatusFound
 	}
-
-	resp.resp = response
-
-	return context.JSON(http.StatusBadRequest, response)
+	return http.StatusBadRequest
 }
 
 // GetRawResponse returns the underlying raw response object.
@@ -114,3 +245,118 @@ func (resp *HTTPAPIResponder) GetRawResponse() interface{} {
 func (resp *HTTPAPIResponder) SetFuncName(name string) {
 	resp.funcName = name
 }
+
+// SetMarshaler sets the JSON marshaler of the response body.
+func (resp *HTTPAPIResponder) SetMarshaler(marshaler common.Marshaler) {
+	resp.marshaler = marshaler
+}
+
+// SetNextCursor sets the cursor of the next page in the X-Next-Cursor header.
+func (resp *HTTPAPIResponder) SetNextCursor(cursor string) {
+	resp.SetHeader(common.NextCursorHeader, cursor)
+}
+
+// SetPreResponse sets the hook called with the response before it's serialized.
+func (resp *HTTPAPIResponder) SetPreResponse(hook PreResponseHook) {
+	resp.preResponse = hook
+}
+
+// SetBodyEncoders sets the additional response formats, negotiated with the Accept header.
+func (resp *HTTPAPIResponder) SetBodyEncoders(encoders map[string]BodyEncoder) {
+	resp.encoders = encoders
+}
+
+// SetNamingStrategy sets the strategy naming the struct fields of the response data.
+func (resp *HTTPAPIResponder) SetNamingStrategy(naming NamingStrategy) {
+	resp.naming = naming
+}
+
+// SetEnvelopeEncoder sets the encoder building the JSON body of responses.
+func (resp *HTTPAPIResponder) SetEnvelopeEncoder(envelope EnvelopeEncoder) {
+	resp.envelope = envelope
+}
+
+// SetHeader sets a header on the underlying HTTP response.
+func (resp *HTTPAPIResponder) SetHeader(name string, value string) {
+	resp.context.Response().Header().Set(name, value)
+}
+
+// RespondStream sends a streaming response over HTTP, flushing after each write of the producer.
+// If contentType is empty, "text/event-stream" is used for Server-Sent Events.
+func (resp *HTTPAPIResponder) RespondStream(contentType string, producer func(w io.Writer) error) error {
+	if producer == nil {
+		return errors.New("producer cannot be nil")
+	}
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = "text/event-stream"
+	}
+
+	response := resp.context.Response()
+	header := response.Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Execution-Time", resp.stop())
+	header.Set("X-Hostname", resp.hostname)
+	if resp.funcName != "" {
+		header.Set("X-Function", resp.funcName)
+	}
+
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+	return producer(&flushWriter{writer: response})
+}
+
+// RespondNoContent sends an HTTP 204 No Content response, without body.
+func (resp *HTTPAPIResponder) RespondNoContent() error {
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+
+	header := resp.context.Response().Header()
+	header.Set("X-Execution-Time", resp.stop())
+	header.Set("X-Hostname", resp.hostname)
+	if resp.funcName != "" {
+		header.Set("X-Function", resp.funcName)
+	}
+	return resp.context.NoContent(http.StatusNoContent)
+}
+
+// RespondRaw sends the bytes as the HTTP response body with the given content type and status 200.
+// If contentType is empty, "application/octet-stream" is used.
+func (resp *HTTPAPIResponder) RespondRaw(contentType string, data []byte) error {
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	header := resp.context.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set(common.RawContentHeader, "true")
+	header.Set("X-Execution-Time", resp.stop())
+	header.Set("X-Hostname", resp.hostname)
+	if resp.funcName != "" {
+		header.Set("X-Function", resp.funcName)
+	}
+	return resp.context.Blob(http.StatusOK, contentType, data)
+}
+
+// flushWriter flushes the HTTP response after every write so streamed data reaches the client immediately.
+// Writers that can't flush are written to as-is.
+type flushWriter struct {
+	writer http.ResponseWriter
+}
+
+// Write writes the data to the response and flushes it.
+func (w *flushWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if flusher, ok := w.writer.(http.Flusher); ok && err == nil {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -2,7 +2,14 @@
 // It defines a common interface and protocol-specific implementations for HTTP and Thrift.
 package responder
 
-import "github.com/phnam/go-protocol-adapter/common"
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
 
 // APIResponder defines the interface for handling API responses.
 // It provides methods to format and send responses in a protocol-agnostic way,
@@ -10,7 +17,8 @@ import "github.com/phnam/go-protocol-adapter/common"
 type APIResponder interface {
 	// Respond processes the given APIResponse and sends it to the client.
 	// It handles protocol-specific formatting and transmission details.
-	// Returns an error if the response cannot be processed or sent.
+	// Returns an error if the response cannot be processed or sent, or an ALREADY_RESPONDED
+	// error if a response was already sent: only the first response of a request is kept.
 	Respond(*common.APIResponse[any]) error
 
 	// GetRawResponse returns the underlying raw response object.
@@ -20,4 +28,173 @@ type APIResponder interface {
 	// SetFuncName sets the function name that will be included in response headers.
 	// This is useful for debugging and tracing requests through the system.
 	SetFuncName(string)
+
+	// SetHeader sets a header that will be included in the response.
+	// Headers provided in the APIResponse passed to Respond take precedence.
+	SetHeader(string, string)
+
+	// GetExecutionTime returns the time spent processing the request.
+	// After Respond it's the value reported in the X-Execution-Time header.
+	GetExecutionTime() time.Duration
+
+	// RespondStream sends a streaming response (e.g. Server-Sent Events) with the given content type.
+	// The producer writes the body; every write is flushed to the client immediately.
+	// Returns an error if the protocol doesn't support streaming.
+	RespondStream(contentType string, producer func(w io.Writer) error) error
+
+	// RespondNoContent sends a successful response without content, e.g. after a DELETE:
+	// HTTP 204 No Content over HTTP, an OK response with empty content over Thrift.
+	RespondNoContent() error
+
+	// RespondRaw sends the given bytes as-is with the given content type, without the JSON envelope.
+	// This avoids base64-in-JSON overhead for binary content like generated files.
+	RespondRaw(contentType string, data []byte) error
+
+	// SetNamingStrategy sets the strategy naming the struct fields of the response data
+	// that don't have a name in their json tag, e.g. SnakeCase. Go field names are used when nil.
+	SetNamingStrategy(NamingStrategy)
+
+	// SetEnvelopeEncoder sets the encoder building the JSON envelope of responses instead of the default
+	// {status, data, message, ...} shape. Thrift responses keep their Thrift envelope, so it only applies to HTTP.
+	SetEnvelopeEncoder(EnvelopeEncoder)
+
+	// SetBodyEncoders sets additional response formats by media type, e.g. "application/xml".
+	// The format is picked from the request Accept header (with q-values) among JSON and these formats;
+	// JSON is used when none is acceptable. Thrift responses are always JSON, so it only applies to HTTP.
+	SetBodyEncoders(map[string]BodyEncoder)
+
+	// SetMarshaler sets the JSON marshaler of the response body, e.g. backed by jsoniter or sonic.
+	// encoding/json is used when nil.
+	SetMarshaler(common.Marshaler)
+
+	// SetNextCursor sets the cursor of the next page of a paginated result, sent in the X-Next-Cursor header.
+	// Clients pass it back in the "cursor" query parameter to get the next page; it isn't set on the last page.
+	// This keeps each response small, e.g. within the Thrift MaxMessageSize, for large result sets.
+	SetNextCursor(cursor string)
+
+	// SetCacheControl sets the Cache-Control header allowing the response to be cached for maxAge (rounded down
+	// to the second), by shared caches such as CDNs when public, only by the client otherwise.
+	// A maxAge of 0 or less is the same as NoCache. The client response cache honors it.
+	SetCacheControl(maxAge time.Duration, public bool)
+
+	// NoCache sets the Cache-Control header so the response isn't stored by clients or intermediaries.
+	NoCache()
+
+	// SetPreResponse sets a hook called by Respond before the response is serialized.
+	// The hook can mutate the response, e.g. add headers or adjust the status; when it returns
+	// an error, the response is replaced by the error response built from it.
+	SetPreResponse(PreResponseHook)
+}
+
+// PreResponseHook is called with every response before it's serialized, see APIResponder.SetPreResponse.
+type PreResponseHook func(response *common.APIResponse[any]) error
+
+// applyPreResponse runs the hook on the response, returning the error response built from
+// the hook error instead when it fails. The response is returned as-is when the hook is nil.
+func applyPreResponse(hook PreResponseHook, response *common.APIResponse[any]) *common.APIResponse[any] {
+	if hook == nil {
+		return response
+	}
+	if err := hook(response); err != nil {
+		return common.FromError(err)
+	}
+	return response
+}
+
+// EnvelopeEncoder builds the value serialized as the JSON body of a response, e.g.
+//
+//	func(response *common.APIResponse[any], data interface{}) interface{} {
+//		return map[string]interface{}{"success": response.Status == common.APIStatus.Ok, "result": data}
+//	}
+//
+// The data is the response data ready to be serialized, with the naming strategy applied
+// and the single item of object responses unwrapped.
+type EnvelopeEncoder func(response *common.APIResponse[any], data interface{}) interface{}
+
+// encodedResponse is the JSON shape of a response whose data is re-encoded,
+// either unwrapped to a single object or renamed with a naming strategy.
+type encodedResponse struct {
+	Status    string            `json:"status"`
+	Data      any               `json:"data,omitempty"`
+	Message   string            `json:"message"`
+	ErrorCode string            `json:"error_code,omitempty"`
+	Total     int64             `json:"total,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// responseBody returns the value to serialize for the response, unwrapping the data of
+// responses created with common.NewObjectResponse and applying the naming strategy if any.
+// When an envelope encoder is set, it builds the value instead.
+func responseBody(response *common.APIResponse[any], naming NamingStrategy, envelope EnvelopeEncoder) interface{} {
+	if envelope != nil {
+		return envelope(response, responseData(response, naming))
+	}
+	if naming == nil && (!response.SingleObject || len(response.Data) != 1) {
+		return response
+	}
+	return &encodedResponse{
+		Status:    response.Status,
+		Data:      responseData(response, naming),
+		Message:   response.Message,
+		ErrorCode: response.ErrorCode,
+		Total:     response.Total,
+		Headers:   response.Headers,
+	}
+}
+
+// responseData returns the data to serialize in the response, unwrapping the data of
+// responses created with common.NewObjectResponse and applying the naming strategy if any.
+func responseData(response *common.APIResponse[any], naming NamingStrategy) interface{} {
+	var data interface{} = response.Data
+	if response.SingleObject && len(response.Data) == 1 {
+		data = response.Data[0]
+	}
+	if naming != nil {
+		data = applyNaming(data, naming)
+	}
+	return data
+}
+
+// executionTimer measures the processing time of a request for the responders.
+type executionTimer struct {
+	// start tracks when the request processing began
+	start time.Time
+	// elapsed is the execution time recorded when the response was created
+	elapsed time.Duration
+}
+
+// newExecutionTimer creates a timer starting now.
+func newExecutionTimer() executionTimer {
+	return executionTimer{start: time.Now()}
+}
+
+// stop records the elapsed time and returns it formatted for the X-Execution-Time header.
+func (timer *executionTimer) stop() string {
+	timer.elapsed = time.Since(timer.start)
+	return fmt.Sprintf("%.4f ms", float64(timer.elapsed.Nanoseconds())/1000000)
+}
+
+// GetExecutionTime returns the recorded execution time, or the time elapsed so far
+// if the response hasn't been created yet.
+func (timer *executionTimer) GetExecutionTime() time.Duration {
+	if timer.elapsed == 0 {
+		return time.Since(timer.start)
+	}
+	return timer.elapsed
+}
+
+// respondOnce guards the responders against sending a second response for the same request.
+// It's safe for concurrent use, e.g. by a handler still running after the server answered with a timeout.
+type respondOnce struct {
+	// responded is true once a response has been sent
+	responded atomic.Bool
+}
+
+// markResponded records that a response is being sent, or returns an ALREADY_RESPONDED error
+// if one already was, so the first response isn't overwritten.
+func (once *respondOnce) markResponded() error {
+	if !once.responded.CompareAndSwap(false, true) {
+		return common.NewError("ALREADY_RESPONDED", "a response has already been sent for this request")
+	}
+	return nil
 }
@@ -0,0 +1,205 @@
+package responder
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy converts Go struct field names into JSON keys when encoding response data.
+// It only applies to fields without a name in their json tag, so existing tags keep precedence.
+type NamingStrategy func(fieldName string) string
+
+var (
+	// SnakeCase names fields like user_id
+	SnakeCase NamingStrategy = toSnakeCase
+	// CamelCase names fields like userName
+	CamelCase NamingStrategy = toCamelCase
+)
+
+// NamingOverride can be implemented by response data types to use their own naming strategy
+// instead of the one configured on the server. Returning nil keeps the Go field names.
+type NamingOverride interface {
+	JSONNaming() NamingStrategy
+}
+
+var (
+	jsonMarshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	namingOverrideType = reflect.TypeOf((*NamingOverride)(nil)).Elem()
+)
+
+// namedObject is a struct re-encoded with renamed keys, keeping the field order.
+type namedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (obj *namedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range obj.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(obj.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// applyNaming returns a copy of the data where struct fields are renamed with the strategy.
+// Values with their own JSON encoding (json.Marshaler, encoding.TextMarshaler) are kept as-is.
+func applyNaming(data interface{}, strategy NamingStrategy) interface{} {
+	return renameValue(reflect.ValueOf(data), strategy)
+}
+
+// renameValue walks the value, converting structs into namedObjects.
+func renameValue(v reflect.Value, strategy NamingStrategy) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.Type().Implements(namingOverrideType) && v.CanInterface() {
+		if v.Kind() != reflect.Pointer || !v.IsNil() {
+			strategy = v.Interface().(NamingOverride).JSONNaming()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return renameValue(v.Elem(), strategy)
+	case reflect.Struct:
+		obj := &namedObject{}
+		addFields(obj, v, strategy)
+		return obj
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v.Interface()
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = renameValue(iter.Value(), strategy)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+			// nil slices encode as null and byte slices as base64, like encoding/json
+			return v.Interface()
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = renameValue(v.Index(i), strategy)
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+// addFields adds the exported fields of the struct to the object, following encoding/json rules
+// for json tags (name, "-" and omitempty) and flattening embedded structs.
+func addFields(obj *namedObject, v reflect.Value, strategy NamingStrategy) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		value := v.Field(i)
+
+		// embedded structs without a tag name have their fields promoted
+		if field.Anonymous && name == "" {
+			embedded := value
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(obj, embedded, strategy)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && isEmptyValue(value) {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+			if strategy != nil {
+				name = strategy(name)
+			}
+		}
+		obj.keys = append(obj.keys, name)
+		obj.values = append(obj.values, renameValue(value, strategy))
+	}
+}
+
+// isEmptyValue reports whether the value is empty in the sense of the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return v.IsZero() && v.Kind() != reflect.Struct
+}
+
+// toSnakeCase converts a Go identifier to snake_case, keeping acronyms together (UserID -> user_id).
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// start a new word at a lower-to-upper change, or at the last capital of an acronym
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// toCamelCase converts a Go identifier to camelCase by lowering its leading capitals (UserID -> userID, HTTPServer -> httpServer).
+func toCamelCase(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		// keep the capital starting the next word
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
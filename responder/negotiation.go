@@ -0,0 +1,91 @@
+package responder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BodyEncoder serializes the response body into an additional response format, e.g. XML or MessagePack.
+// The body is the value that would be serialized as JSON, with the naming strategy and envelope applied.
+type BodyEncoder func(body interface{}) ([]byte, error)
+
+// mediaRange is an entry of an Accept header, e.g. "application/*;q=0.8".
+type mediaRange struct {
+	// mediaType and subType are the parts of the range, either can be "*"
+	mediaType, subType string
+	// q is the quality value of the range, from 0 to 1
+	q float64
+}
+
+// parseAccept parses the media ranges of an Accept header (RFC 9110), skipping invalid entries.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, entry := range strings.Split(accept, ",") {
+		params := strings.Split(entry, ";")
+		mediaType, subType, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || mediaType == "" || subType == "" {
+			continue
+		}
+		r := mediaRange{mediaType: mediaType, subType: subType, q: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					ok = false
+				}
+				r.q = q
+			}
+		}
+		if ok {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// specificity returns how specifically the range matches the media type: 3 for an exact match,
+// 2 for type/*, 1 for */*, or 0 when it doesn't match.
+func (r mediaRange) specificity(mediaType string, subType string) int {
+	switch {
+	case r.mediaType == "*" && r.subType == "*":
+		return 1
+	case r.mediaType != mediaType:
+		return 0
+	case r.subType == "*":
+		return 2
+	case r.subType == subType:
+		return 3
+	}
+	return 0
+}
+
+// NegotiateContentType returns the offered content type preferred by the Accept header, using the
+// quality value of the most specific matching media range. Ties keep the order of the offers, so the
+// first offer is returned when the header is empty. It returns an empty string when no offer is acceptable.
+//
+//	NegotiateContentType("application/xml;q=0.9, application/json", []string{"application/xml", "application/json"}) // "application/json"
+func NegotiateContentType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		mediaType, subType, _ := strings.Cut(strings.ToLower(offer), "/")
+		q, matched := 0.0, 0
+		for _, r := range ranges {
+			if s := r.specificity(mediaType, subType); s > matched {
+				q, matched = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,231 @@
+package responder
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+// NetHTTPAPIResponder implements the APIResponder interface on a standard http.ResponseWriter,
+// for the handlers mounted on a net/http router. It sends the same responses as HTTPAPIResponder.
+type NetHTTPAPIResponder struct {
+	// t identifies the protocol type as "HTTP"
+	t string
+	// writer is the response writer of the current request
+	writer http.ResponseWriter
+	// request is the current request, used for content negotiation
+	request *http.Request
+	// executionTimer tracks the request processing time for the X-Execution-Time header
+	executionTimer
+	// respondOnce rejects responding twice to the same request
+	respondOnce
+	// hostname stores the server hostname to include in response headers
+	hostname string
+	// funcName stores the handler function name to include in response headers
+	funcName string
+	// naming renames the struct fields of the response data, Go field names are kept when nil
+	naming NamingStrategy
+	// envelope builds the JSON body of responses, the default shape is used when nil
+	envelope EnvelopeEncoder
+	// encoders are the additional response formats by media type, negotiated with the Accept header
+	encoders map[string]BodyEncoder
+	// marshaler encodes JSON bodies, encoding/json is used when nil
+	marshaler common.Marshaler
+	// preResponse is called with the response before it's serialized
+	preResponse PreResponseHook
+	// resp stores the response after it's been sent
+	resp interface{}
+}
+
+// NewNetHTTPAPIResponder creates a new responder writing to the given http.ResponseWriter, with the hostname
+// and function name of the X-Hostname and X-Function headers. It returns an implementation of the APIResponder interface.
+func NewNetHTTPAPIResponder(w http.ResponseWriter, r *http.Request, hostname string, funcName string) APIResponder {
+	return &NetHTTPAPIResponder{
+		t:              "HTTP",
+		executionTimer: newExecutionTimer(),
+		writer:         w,
+		request:        r,
+		hostname:       hostname,
+		funcName:       funcName,
+	}
+}
+
+// Respond sends the API response to the client, see HTTPAPIResponder.Respond for the headers,
+// status codes and formats.
+func (resp *NetHTTPAPIResponder) Respond(response *common.APIResponse[any]) error {
+	if response == nil {
+		return errors.New("response cannot be nil")
+	}
+
+	if response.Data != nil && reflect.TypeOf(response.Data).Kind() != reflect.Slice {
+		return errors.New("data response must be a slice")
+	}
+
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	response = applyPreResponse(resp.preResponse, response)
+
+	header := resp.writer.Header()
+	for key, value := range response.Headers {
+		header.Set(key, value)
+	}
+	response.Headers = nil
+
+	if response.Total > 0 {
+		header.Set(common.TotalCountHeader, strconv.FormatInt(response.Total, 10))
+	}
+	resp.setTraceHeaders()
+
+	resp.resp = response
+	code := httpStatusCode(response.Status)
+	if code == http.StatusFound {
+		// the Location header is set by the handler
+		resp.writer.WriteHeader(code)
+		return nil
+	}
+
+	body := responseBody(response, resp.naming, resp.envelope)
+	contentType, data, err := encodeBody(header.Get(echo.HeaderContentType), resp.request.Header.Get(echo.HeaderAccept),
+		resp.encoders, resp.marshaler, response, body)
+	if err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = echo.MIMEApplicationJSONCharsetUTF8
+		data, err = common.StdMarshaler.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	return resp.write(code, contentType, data)
+}
+
+// write sends the body with the status code and content type.
+func (resp *NetHTTPAPIResponder) write(code int, contentType string, data []byte) error {
+	resp.writer.Header().Set(echo.HeaderContentType, contentType)
+	resp.writer.WriteHeader(code)
+	_, err := resp.writer.Write(data)
+	return err
+}
+
+// setTraceHeaders sets the X-Execution-Time, X-Hostname and X-Function headers, stopping the execution timer.
+func (resp *NetHTTPAPIResponder) setTraceHeaders() {
+	header := resp.writer.Header()
+	header.Set("X-Execution-Time", resp.stop())
+	header.Set("X-Hostname", resp.hostname)
+	if resp.funcName != "" {
+		header.Set("X-Function", resp.funcName)
+	}
+}
+
+// GetRawResponse returns the response after it has been sent.
+func (resp *NetHTTPAPIResponder) GetRawResponse() interface{} {
+	return resp.resp
+}
+
+// SetFuncName sets the function name that will be included in the X-Function response header.
+func (resp *NetHTTPAPIResponder) SetFuncName(name string) {
+	resp.funcName = name
+}
+
+// SetHeader sets a header on the underlying HTTP response.
+func (resp *NetHTTPAPIResponder) SetHeader(name string, value string) {
+	resp.writer.Header().Set(name, value)
+}
+
+// SetMarshaler sets the JSON marshaler of the response body.
+func (resp *NetHTTPAPIResponder) SetMarshaler(marshaler common.Marshaler) {
+	resp.marshaler = marshaler
+}
+
+// SetNextCursor sets the cursor of the next page in the X-Next-Cursor header.
+func (resp *NetHTTPAPIResponder) SetNextCursor(cursor string) {
+	resp.SetHeader(common.NextCursorHeader, cursor)
+}
+
+// SetCacheControl sets the Cache-Control header of the response. See APIResponder.SetCacheControl.
+func (resp *NetHTTPAPIResponder) SetCacheControl(maxAge time.Duration, public bool) {
+	resp.SetHeader(CacheControlHeader, CacheControl(maxAge, public))
+}
+
+// NoCache sets the Cache-Control header so the response isn't cached.
+func (resp *NetHTTPAPIResponder) NoCache() {
+	resp.SetHeader(CacheControlHeader, noCacheDirectives)
+}
+
+// SetPreResponse sets the hook called with the response before it's serialized.
+func (resp *NetHTTPAPIResponder) SetPreResponse(hook PreResponseHook) {
+	resp.preResponse = hook
+}
+
+// SetBodyEncoders sets the additional response formats, negotiated with the Accept header.
+func (resp *NetHTTPAPIResponder) SetBodyEncoders(encoders map[string]BodyEncoder) {
+	resp.encoders = encoders
+}
+
+// SetNamingStrategy sets the strategy naming the struct fields of the response data.
+func (resp *NetHTTPAPIResponder) SetNamingStrategy(naming NamingStrategy) {
+	resp.naming = naming
+}
+
+// SetEnvelopeEncoder sets the encoder building the JSON body of responses.
+func (resp *NetHTTPAPIResponder) SetEnvelopeEncoder(envelope EnvelopeEncoder) {
+	resp.envelope = envelope
+}
+
+// RespondStream sends a streaming response, flushing after each write of the producer when the
+// response writer supports it. If contentType is empty, "text/event-stream" is used for Server-Sent Events.
+func (resp *NetHTTPAPIResponder) RespondStream(contentType string, producer func(w io.Writer) error) error {
+	if producer == nil {
+		return errors.New("producer cannot be nil")
+	}
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = "text/event-stream"
+	}
+
+	header := resp.writer.Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	resp.setTraceHeaders()
+
+	resp.writer.WriteHeader(http.StatusOK)
+	if flusher, ok := resp.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return producer(&flushWriter{writer: resp.writer})
+}
+
+// RespondNoContent sends an HTTP 204 No Content response, without body.
+func (resp *NetHTTPAPIResponder) RespondNoContent() error {
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	resp.setTraceHeaders()
+	resp.writer.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// RespondRaw sends the bytes as the HTTP response body with the given content type and status 200.
+// If contentType is empty, "application/octet-stream" is used.
+func (resp *NetHTTPAPIResponder) RespondRaw(contentType string, data []byte) error {
+	if err := resp.markResponded(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+	resp.writer.Header().Set(common.RawContentHeader, "true")
+	resp.setTraceHeaders()
+	return resp.write(http.StatusOK, contentType, data)
+}
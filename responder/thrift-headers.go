@@ -0,0 +1,50 @@
+package responder
+
+import (
+	"strconv"
+
+	"github.com/phnam/go-protocol-adapter/thriftapi"
+)
+
+// DefaultMaxThriftHeaderSize is the default limit of the total size in bytes (names and values)
+// of the headers of a Thrift response.
+const DefaultMaxThriftHeaderSize = 64 * 1024
+
+// thriftAdapterHeaders are the headers set by the adapter, kept when the response headers are too large.
+var thriftAdapterHeaders = []string{"X-Execution-Time", "X-Hostname", "X-Function", "X-Request-Id"}
+
+// SetMaxHeaderSize sets the limit of the total size in bytes of the response headers,
+// DefaultMaxThriftHeaderSize when 0.
+func (responder *ThriftAPIResponder) SetMaxHeaderSize(size int) {
+	responder.maxHeaderSize = size
+}
+
+// limitHeaders replaces the response with a HEADERS_TOO_LARGE error when its headers exceed the maximum size,
+// so a handler setting huge headers gets a predictable error rather than a message too large for the transport.
+// Only the headers set by the adapter are kept.
+func (responder *ThriftAPIResponder) limitHeaders() {
+	limit := responder.maxHeaderSize
+	if limit <= 0 {
+		limit = DefaultMaxThriftHeaderSize
+	}
+	size := 0
+	for key, value := range responder.resp.Headers {
+		size += len(key) + len(value)
+	}
+	if size <= limit {
+		return
+	}
+
+	headers := make(map[string]string)
+	for _, name := range thriftAdapterHeaders {
+		if value, ok := responder.resp.Headers[name]; ok {
+			headers[name] = value
+		}
+	}
+	responder.resp = &thriftapi.APIResponse{
+		Status:    thriftapi.Status_ERROR,
+		ErrorCode: "HEADERS_TOO_LARGE",
+		Message:   "Response headers of " + strconv.Itoa(size) + " bytes exceed the limit of " + strconv.Itoa(limit) + " bytes",
+		Headers:   headers,
+	}
+}
@@ -1,11 +1,11 @@
 package responder
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"reflect"
-	"time"
+	"strconv"
+	"sync"
 
 	"github.com/phnam/go-protocol-adapter/common"
 	"github.com/phnam/go-protocol-adapter/thriftapi"
@@ -19,22 +19,36 @@ type ThriftAPIResponder struct {
 	t string
 	// resp holds the Thrift-specific response object
 	resp *thriftapi.APIResponse
-	// start tracks when the request processing began for calculating execution time
-	start time.Time
+	// executionTimer tracks the request processing time for the X-Execution-Time header
+	executionTimer
+	// respondOnce rejects responding twice to the same request
+	respondOnce
 	// hostname stores the server hostname to include in response headers
 	hostname string
 	// funcName stores the handler function name to include in response headers
 	funcName string
+	// naming renames the struct fields of the response data, Go field names are kept when nil
+	naming NamingStrategy
+	// marshaler encodes the response content, encoding/json is used when nil
+	marshaler common.Marshaler
+	// preResponse is called with the response before it's serialized
+	preResponse PreResponseHook
+	// headers stores the headers set via SetHeader until the response is created
+	headers map[string]string
+	// headersLock guards headers, set by a handler still running after the server answered with a timeout
+	headersLock sync.Mutex
+	// maxHeaderSize is the limit of the total size of the response headers, DefaultMaxThriftHeaderSize when 0
+	maxHeaderSize int
 }
 
 // NewThriftAPIResponder creates a new Thrift API responder with the given hostname and function name.
 // It initializes a timer to track execution time and returns an implementation of the APIResponder interface.
 func NewThriftAPIResponder(hostname string, funcName string) APIResponder {
 	return &ThriftAPIResponder{
-		t:        "THRIFT",
-		start:    time.Now(),
-		hostname: hostname,
-		funcName: funcName,
+		t:              "THRIFT",
+		executionTimer: newExecutionTimer(),
+		hostname:       hostname,
+		funcName:       funcName,
 	}
 }
 
@@ -53,7 +67,9 @@ func (responder *ThriftAPIResponder) GetRawResponse() interface{} {
 // 2. Creates a new Thrift APIResponse with the common response's fields
 // 3. Converts the common status to a Thrift status enum value
 // 4. Serializes the data to JSON and stores it as a string in the Content field
-// 5. Adds execution time, hostname, and function name headers
+// 5. Adds total count, execution time, hostname, and function name headers
+//
+// Responses created with common.NewObjectResponse have their single data item serialized as an object.
 //
 // Returns an error if the response cannot be processed.
 func (responder *ThriftAPIResponder) Respond(response *common.APIResponse[any]) error {
@@ -66,26 +82,38 @@ func (responder *ThriftAPIResponder) Respond(response *common.APIResponse[any])
 		return errors.New("data response must be a slice")
 	}
 
-	var dif = float64(time.Since(responder.start).Nanoseconds()) / 1000000
+	if err := responder.markResponded(); err != nil {
+		return err
+	}
+	response = applyPreResponse(responder.preResponse, response)
 
 	responder.resp = &thriftapi.APIResponse{
 		ErrorCode: response.ErrorCode,
 		Message:   response.Message,
 		Total:     response.Total,
-		Headers:   response.Headers,
+		Headers:   make(map[string]string),
 	}
 	responder.resp.Status, _ = thriftapi.StatusFromString(response.Status)
-	bytes, _ := json.Marshal(response.Data)
+	marshaler := responder.marshaler
+	if marshaler == nil {
+		marshaler = common.StdMarshaler
+	}
+	bytes, _ := marshaler.Marshal(responseData(response, responder.naming))
 	responder.resp.Content = string(bytes)
-	if responder.resp.Headers == nil {
-		responder.resp.Headers = make(map[string]string)
+	responder.copyHeaders(responder.resp.Headers)
+	if response.Total > 0 {
+		responder.resp.Headers[common.TotalCountHeader] = strconv.FormatInt(response.Total, 10)
+	}
+	for key, value := range response.Headers {
+		responder.resp.Headers[key] = value
 	}
-	responder.resp.Headers["X-Execution-Time"] = fmt.Sprintf("%.4f ms", dif)
+	responder.resp.Headers["X-Execution-Time"] = responder.stop()
 	responder.resp.Headers["X-Hostname"] = responder.hostname
 
 	if responder.funcName != "" {
 		responder.resp.Headers["X-Function"] = responder.funcName
 	}
+	responder.limitHeaders()
 
 	return nil
 }
@@ -95,3 +123,108 @@ func (responder *ThriftAPIResponder) Respond(response *common.APIResponse[any])
 func (responder *ThriftAPIResponder) SetFuncName(funcName string) {
 	responder.funcName = funcName
 }
+
+// SetNamingStrategy sets the strategy naming the struct fields of the response data.
+func (responder *ThriftAPIResponder) SetNamingStrategy(naming NamingStrategy) {
+	responder.naming = naming
+}
+
+// SetEnvelopeEncoder is ignored over Thrift, where responses keep their Thrift envelope.
+func (responder *ThriftAPIResponder) SetEnvelopeEncoder(envelope EnvelopeEncoder) {
+}
+
+// SetMarshaler sets the JSON marshaler of the response content.
+func (responder *ThriftAPIResponder) SetMarshaler(marshaler common.Marshaler) {
+	responder.marshaler = marshaler
+}
+
+// SetNextCursor sets the cursor of the next page in the X-Next-Cursor header.
+func (responder *ThriftAPIResponder) SetNextCursor(cursor string) {
+	responder.SetHeader(common.NextCursorHeader, cursor)
+}
+
+// SetPreResponse sets the hook called with the response before it's serialized.
+func (responder *ThriftAPIResponder) SetPreResponse(hook PreResponseHook) {
+	responder.preResponse = hook
+}
+
+// SetBodyEncoders is ignored over Thrift, where response content is always JSON.
+func (responder *ThriftAPIResponder) SetBodyEncoders(encoders map[string]BodyEncoder) {
+}
+
+// SetHeader stores a header that will be included in the Thrift response headers.
+// It's safe for concurrent use with Respond.
+func (responder *ThriftAPIResponder) SetHeader(name string, value string) {
+	responder.headersLock.Lock()
+	defer responder.headersLock.Unlock()
+	if responder.headers == nil {
+		responder.headers = make(map[string]string)
+	}
+	responder.headers[name] = value
+}
+
+// copyHeaders copies the headers set via SetHeader into the response headers.
+func (responder *ThriftAPIResponder) copyHeaders(headers map[string]string) {
+	responder.headersLock.Lock()
+	defer responder.headersLock.Unlock()
+	for key, value := range responder.headers {
+		headers[key] = value
+	}
+}
+
+// RespondStream is not supported over Thrift since every response is a single message.
+func (responder *ThriftAPIResponder) RespondStream(contentType string, producer func(w io.Writer) error) error {
+	return errors.New("streaming responses are not supported over Thrift")
+}
+
+// RespondNoContent creates an OK Thrift response with empty content.
+func (responder *ThriftAPIResponder) RespondNoContent() error {
+	if err := responder.markResponded(); err != nil {
+		return err
+	}
+
+	responder.resp = &thriftapi.APIResponse{
+		Status:  thriftapi.Status_OK,
+		Headers: make(map[string]string),
+	}
+	responder.copyHeaders(responder.resp.Headers)
+	responder.resp.Headers["X-Execution-Time"] = responder.stop()
+	responder.resp.Headers["X-Hostname"] = responder.hostname
+
+	if responder.funcName != "" {
+		responder.resp.Headers["X-Function"] = responder.funcName
+	}
+	responder.limitHeaders()
+
+	return nil
+}
+
+// RespondRaw places the bytes as-is into the Content of an OK Thrift response,
+// with Content-Type and X-Raw-Content headers so clients skip JSON decoding.
+// If contentType is empty, "application/octet-stream" is used.
+func (responder *ThriftAPIResponder) RespondRaw(contentType string, data []byte) error {
+	if err := responder.markResponded(); err != nil {
+		return err
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	responder.resp = &thriftapi.APIResponse{
+		Status:  thriftapi.Status_OK,
+		Content: string(data),
+		Headers: make(map[string]string),
+	}
+	responder.copyHeaders(responder.resp.Headers)
+	responder.resp.Headers["Content-Type"] = contentType
+	responder.resp.Headers[common.RawContentHeader] = "true"
+	responder.resp.Headers["X-Execution-Time"] = responder.stop()
+	responder.resp.Headers["X-Hostname"] = responder.hostname
+
+	if responder.funcName != "" {
+		responder.resp.Headers["X-Function"] = responder.funcName
+	}
+	responder.limitHeaders()
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// CORSConfig configures Cross-Origin Resource Sharing for the HTTP server.
+type CORSConfig struct {
+	// AllowOrigins lists the origins allowed to call the server, all origins ("*") when empty
+	AllowOrigins []string
+
+	// AllowHeaders lists the request headers allowed in preflight requests.
+	// When empty, the headers requested by the browser are allowed.
+	AllowHeaders []string
+
+	// ExposeHeaders lists the response headers browsers are allowed to read
+	ExposeHeaders []string
+
+	// AllowCredentials determines whether requests may include credentials (cookies, authorization headers)
+	AllowCredentials bool
+
+	// MaxAge is how long (in seconds) browsers may cache preflight results, not sent when 0
+	MaxAge int
+}
+
+// corsMiddleware adds CORS headers to responses when CORS is enabled in the server configuration.
+// Preflight OPTIONS requests to a registered path are answered automatically with 204,
+// allowing the methods registered for that path, unless an OPTIONS handler was registered for it.
+func (server *HTTPAPIServer) corsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if server.config == nil || server.config.CORS == nil {
+			return next(c)
+		}
+		cors := server.config.CORS
+		req := c.Request()
+		header := c.Response().Header()
+
+		// the CORS headers depend on the origin, including when it isn't allowed or missing,
+		// so caches mustn't serve a response to another origin
+		header.Add(echo.HeaderVary, echo.HeaderOrigin)
+
+		origin := req.Header.Get(echo.HeaderOrigin)
+		allowOrigin := cors.allowOrigin(origin)
+		if origin == "" || allowOrigin == "" {
+			return next(c)
+		}
+
+		header.Set(echo.HeaderAccessControlAllowOrigin, allowOrigin)
+		if cors.AllowCredentials {
+			header.Set(echo.HeaderAccessControlAllowCredentials, "true")
+		}
+
+		// Simple and actual requests only need the origin headers
+		if req.Method != http.MethodOptions || req.Header.Get(echo.HeaderAccessControlRequestMethod) == "" {
+			if len(cors.ExposeHeaders) > 0 {
+				header.Set(echo.HeaderAccessControlExposeHeaders, strings.Join(cors.ExposeHeaders, ","))
+			}
+			return next(c)
+		}
+
+		// Preflight request, let a registered OPTIONS handler answer it
+		path := req.URL.Path
+		if handler, _ := findRoute(http.MethodOptions, path, server.router); handler != nil {
+			return next(c)
+		}
+		methods := server.allowedMethods(path)
+		if len(methods) == 0 {
+			return next(c)
+		}
+
+		header.Add(echo.HeaderVary, echo.HeaderAccessControlRequestMethod)
+		header.Add(echo.HeaderVary, echo.HeaderAccessControlRequestHeaders)
+		header.Set(echo.HeaderAccessControlAllowMethods, strings.Join(append(methods, http.MethodOptions), ","))
+		if len(cors.AllowHeaders) > 0 {
+			header.Set(echo.HeaderAccessControlAllowHeaders, strings.Join(cors.AllowHeaders, ","))
+		} else if requested := req.Header.Get(echo.HeaderAccessControlRequestHeaders); requested != "" {
+			header.Set(echo.HeaderAccessControlAllowHeaders, requested)
+		}
+		if cors.MaxAge > 0 {
+			header.Set(echo.HeaderAccessControlMaxAge, strconv.Itoa(cors.MaxAge))
+		}
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin header for the given origin,
+// or an empty string if the origin isn't allowed.
+func (cors *CORSConfig) allowOrigin(origin string) string {
+	if len(cors.AllowOrigins) == 0 {
+		if cors.AllowCredentials {
+			// browsers reject credentials with a wildcard origin
+			return origin
+		}
+		return "*"
+	}
+	for _, allowed := range cors.AllowOrigins {
+		if allowed == "*" {
+			if cors.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// allowedMethods returns the sorted methods having a handler registered for the path. The method sets
+// of the route paths are recorded by SetHandler, the ones of the routes matching the path are merged.
+func (server *HTTPAPIServer) allowedMethods(path string) []string {
+	var methods []string
+	for route, routeMethods := range server.routeMethods {
+		if matchRoutePath(route, path) {
+			for _, method := range routeMethods {
+				methods = addMethod(methods, method)
+			}
+		}
+	}
+	return methods
+}
+
+// addMethod inserts the method in the sorted methods, unless it's already there.
+func addMethod(methods []string, method string) []string {
+	index := sort.SearchStrings(methods, method)
+	if index < len(methods) && methods[index] == method {
+		return methods
+	}
+	return slices.Insert(methods, index, method)
+}
+
+// matchRoutePath reports whether a route path, with :name parameters, matches the request path
+// the way findRoute does.
+func matchRoutePath(route string, path string) bool {
+	if route == path {
+		return true
+	}
+	routeParts := strings.Split(route, "/")
+	pathParts := strings.Split(path, "/")
+	if len(routeParts) != len(pathParts) {
+		return false
+	}
+	for i, part := range routeParts {
+		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// gzipMiddleware compresses HTTP responses according to the server configuration.
+// Compression is skipped when disabled in the config or when the client doesn't accept gzip.
+// When GzipMinLength is set, the response is buffered until it reaches that size,
+// so smaller responses are sent uncompressed. Already-compressed content (images, archives,
+// or responses with a Content-Encoding) is never compressed again.
+func (server *HTTPAPIServer) gzipMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		config := server.config
+		if config != nil && config.GzipEnabled != nil && !*config.GzipEnabled {
+			return next(c)
+		}
+
+		res := c.Response()
+		res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		if !strings.Contains(c.Request().Header.Get(echo.HeaderAcceptEncoding), "gzip") {
+			return next(c)
+		}
+
+		minLength := 0
+		if config != nil {
+			minLength = config.GzipMinLength
+		}
+
+		rw := res.Writer
+		gw := &gzipResponseWriter{ResponseWriter: rw, minLength: minLength}
+		res.Writer = gw
+		defer func() {
+			gw.close()
+			res.Writer = rw
+		}()
+		return next(c)
+	}
+}
+
+// compressedContentTypes lists content type prefixes of formats that are already compressed,
+// so gzipping them again only wastes CPU.
+var compressedContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+}
+
+// isCompressed reports whether the response headers describe content that is already compressed,
+// either through a Content-Encoding or an already-compressed content type.
+func isCompressed(header http.Header) bool {
+	if header.Get(echo.HeaderContentEncoding) != "" {
+		return true
+	}
+	contentType := header.Get(echo.HeaderContentType)
+	if strings.HasPrefix(contentType, "image/svg") {
+		return false
+	}
+	for _, prefix := range compressedContentTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the response until minLength bytes have been written,
+// then switches to gzip compression. Responses that never reach the threshold
+// are written uncompressed when the writer is closed.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	// minLength is the minimum response size that triggers compression
+	minLength int
+	// buf holds the response body until the threshold is reached
+	buf bytes.Buffer
+	// code is the status code captured from WriteHeader
+	code int
+	// gz is the gzip writer, set once compression has started
+	gz *gzip.Writer
+	// passthrough is set when the response was flushed uncompressed before reaching the threshold
+	passthrough bool
+}
+
+// WriteHeader records the status code; it's sent once the encoding is decided.
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.passthrough || w.gz != nil {
+		return
+	}
+	w.code = code
+}
+
+// Write buffers data until the threshold is reached, then compresses everything written.
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	if w.buf.Len()+len(b) < w.minLength {
+		return w.buf.Write(b)
+	}
+
+	// Threshold reached, start compressing unless the content is already compressed
+	header := w.Header()
+	if header.Get(echo.HeaderContentType) == "" {
+		header.Set(echo.HeaderContentType, http.DetectContentType(append(w.buf.Bytes(), b...)))
+	}
+	if isCompressed(header) {
+		w.writeBuffered()
+		return w.ResponseWriter.Write(b)
+	}
+	header.Set(echo.HeaderContentEncoding, "gzip")
+	header.Del(echo.HeaderContentLength)
+	w.ResponseWriter.WriteHeader(w.statusCode())
+
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	if _, err := w.gz.Write(w.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	w.buf.Reset()
+	return w.gz.Write(b)
+}
+
+// Flush sends any buffered data to the client.
+// If compression hasn't started yet, the response continues uncompressed.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	} else {
+		w.writeBuffered()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements the http.Hijacker interface.
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// close finishes the response, either closing the gzip stream or writing the
+// buffered body uncompressed.
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+		return
+	}
+	if w.code == 0 && w.buf.Len() == 0 {
+		// nothing has been written, leave the response untouched
+		return
+	}
+	w.writeBuffered()
+}
+
+// writeBuffered writes the status code and buffered body without compression.
+func (w *gzipResponseWriter) writeBuffered() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.statusCode())
+	w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+// statusCode returns the captured status code, defaulting to 200.
+func (w *gzipResponseWriter) statusCode() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+
+	adapter "github.com/phnam/go-protocol-adapter"
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	responderPackage "github.com/phnam/go-protocol-adapter/responder"
+)
+
+// AsHTTPHandler wraps a Handler into a standard http.HandlerFunc, so it can be mounted on a net/http router
+// (http.ServeMux, chi, ...) without running a server:
+//
+//	mux.Handle("GET /items/{id}", server.AsHTTPHandler(common.APIMethod.GET, getItem))
+//
+// Requests with another method are answered with 405, unless method is nil. The request and responder are
+// backed by the *http.Request and http.ResponseWriter (see request.NewNetHTTPAPIRequest and
+// responder.NewNetHTTPAPIResponder), no Echo context is involved. The path parameters of http.ServeMux
+// patterns are available with GetVar. A handler returning an error without responding gets the error
+// response built by FromError.
+//
+// The handler runs with the default settings of the HTTP server: no PreRequest, PreResponse or response format.
+func AsHTTPHandler(method *common.MethodValue, fn Handler) http.HandlerFunc {
+	hostname, _ := os.Hostname()
+	funcName := adapter.GetFunctionName(fn)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if method != nil && r.Method != method.Value {
+			w.Header().Set("Allow", method.Value)
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(common.NewErrorResponse(common.APIStatus.Invalid, "METHOD_NOT_ALLOWED",
+				"Method "+r.Method+" isn't allowed for "+r.URL.Path))
+			return
+		}
+
+		req := request.NewNetHTTPAPIRequest(r)
+		responder := responderPackage.NewNetHTTPAPIResponder(w, r, hostname, funcName)
+
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Println("panic: ", rec, string(debug.Stack()))
+				responder.Respond(common.NewErrorResponse(common.APIStatus.Error, "PANIC", "Please try again later."))
+			}
+		}()
+		if err := fn(req, responder); err != nil {
+			// ignored when the handler already responded
+			responder.Respond(common.FromError(err))
+		}
+	}
+}
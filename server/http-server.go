@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,9 +10,9 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/labstack/echo"
-	"github.com/labstack/echo/middleware"
 	adapter "github.com/phnam/go-protocol-adapter"
 	"github.com/phnam/go-protocol-adapter/common"
 	"github.com/phnam/go-protocol-adapter/request"
@@ -43,6 +44,22 @@ type HTTPAPIServer struct {
 	debug bool
 	// router maps route patterns to handler functions
 	router map[string]Handler
+	// routeMethods holds the sorted methods registered for each route path, answering CORS preflights
+	routeMethods map[string][]string
+	// typedRoutes holds the types of the routes registered with SetHandlerTyped
+	typedRoutes typedRoutes
+	// inFlight counts the requests currently being handled
+	inFlight atomic.Int64
+	// notFoundHandler is the optional handler executed when no route matches
+	notFoundHandler Handler
+	// panicHandler is the optional function building the response to a panic
+	panicHandler PanicHandler
+	// preResponse is the optional function executed with every response before it's serialized
+	preResponse PreResponseHandler
+	// securityHeaders are the headers set with SetSecurityHeaders, sent with every response
+	securityHeaders map[string]string
+	// trustedProxies are the parsed TrustedProxies of the configuration
+	trustedProxies request.TrustedProxies
 }
 
 // NewHTTPAPIServer creates a new HTTP API server instance.
@@ -57,18 +74,95 @@ func NewHTTPAPIServer() Server {
 		ID:       idCounter,
 		hostname: hostname,
 		router:   map[string]Handler{},
+
+		routeMethods: map[string][]string{},
+		typedRoutes:  typedRoutes{},
 	}
-	// Enable Gzip compression for responses
-	server.Echo.Use(middleware.Gzip())
+	// Track in-flight requests so Stop can wait for them to drain
+	server.Echo.Use(server.trackInFlight)
+
+	// Assign a request ID to every request and echo it in the response
+	server.Echo.Use(server.requestIDMiddleware)
+
+	// Add the security headers configured with SetSecurityHeaders
+	server.Echo.Use(server.securityHeadersMiddleware)
+
+	// Add CORS headers and answer preflight requests when CORS is configured
+	server.Echo.Use(server.corsMiddleware)
+
+	// Enable Gzip compression for responses, tuned by GzipEnabled/GzipMinLength
+	server.Echo.Use(server.gzipMiddleware)
+
+	// Dispatch the requests Echo can't route (custom methods, fallback handler) within the middleware chain
+	server.Echo.Use(server.dispatchUnrouted)
 
 	// Configure custom error handler for routes not found
-	server.Echo.HTTPErrorHandler = func(err error, c echo.Context) {
-		if c.Response().Committed {
-			return
+	server.Echo.HTTPErrorHandler = server.handleHTTPError
+	return &server
+}
+
+// SetNotFoundHandler registers a handler that is executed when no route matches the request.
+// The handler receives the full request and responder, so it can build any response.
+// When unset, a standard NOT_FOUND APIResponse is returned.
+func (server *HTTPAPIServer) SetNotFoundHandler(fn Handler) {
+	server.notFoundHandler = fn
+}
+
+// SetFallbackHandler registers a catch-all handler executed when no route matches the request.
+// See Server.SetFallbackHandler, it's the same handler as SetNotFoundHandler.
+// Requests to a path registered for other methods still get the NOT_FOUND response.
+func (server *HTTPAPIServer) SetFallbackHandler(fn Handler) {
+	server.notFoundHandler = fn
+}
+
+// dispatchUnrouted is an Echo middleware handling the requests the Echo router couldn't route.
+// Requests with methods the Echo router can't handle (e.g. QUERY) are matched against the internal router,
+// and requests matching no route go to the not-found handler when one is registered.
+// Being part of the middleware chain, these requests are counted in-flight and compressed like the others.
+func (server *HTTPAPIServer) dispatchUnrouted(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if err == nil || c.Response().Committed {
+			return err
+		}
+		if err != echo.ErrNotFound && err != echo.ErrMethodNotAllowed {
+			return err
+		}
+
+		// Echo can't route custom methods like QUERY, so try to match them dynamically
+		handler, varMap := findRoute(c.Request().Method, c.Request().URL.Path, server.router)
+		if handler != nil {
+			if varMap != nil {
+				setPathParams(c, varMap)
+			}
+		} else if err == echo.ErrNotFound && server.notFoundHandler != nil {
+			handler = server.notFoundHandler
+		} else {
+			return err
 		}
-		c.String(http.StatusNotFound, `{"status":"NOT_FOUND","error_code":"NOT_FOUND","message":"[SDK] Route not found for "`+c.Request().Method+` `+c.Request().URL.Path+`"}`)
+
+		wrapper := &HandlerWrapper{
+			handler: handler,
+			server:  server,
+		}
+		return wrapper.processCore(c)
+	}
+}
+
+// handleHTTPError is the Echo error handler, answering the requests that failed before reaching a handler.
+// Unmatched routes, including paths registered for other methods, get a NOT_FOUND APIResponse like over Thrift.
+// Other errors, e.g. returned by Echo middlewares added to the server, are answered by the Echo default handler.
+func (server *HTTPAPIServer) handleHTTPError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	switch err {
+	case echo.ErrNotFound, echo.ErrMethodNotAllowed:
+		c.JSON(http.StatusNotFound, notFoundResponse(c.Request().Method, c.Request().URL.Path))
+	default:
+		server.Echo.DefaultHTTPErrorHandler(err, c)
 	}
-	return &server
 }
 
 // SetHandler registers a handler function for a specific HTTP method and path.
@@ -82,6 +176,7 @@ func NewHTTPAPIServer() Server {
 //
 // The method maps the handler to the appropriate Echo framework route and also
 // stores it in the internal router map for dynamic route matching.
+// Any method string is accepted, including custom ones created with common.MethodFromString.
 func (server *HTTPAPIServer) SetHandler(method *common.MethodValue, path string, fn Handler) error {
 	var wrapper = &HandlerWrapper{
 		handler: fn,
@@ -97,24 +192,67 @@ func (server *HTTPAPIServer) SetHandler(method *common.MethodValue, path string,
 		server.Echo.PUT(path, wrapper.processCore)
 	case common.APIMethod.DELETE.Value:
 		server.Echo.DELETE(path, wrapper.processCore)
-	case common.APIMethod.QUERY.Value:
+	default:
+		// Other methods (PATCH, TRACE, CONNECT, QUERY or custom ones) are added as-is.
+		// Methods the Echo router doesn't support are dispatched through the internal router by dispatchUnrouted.
 		server.Echo.Add(method.Value, path, wrapper.processCore)
 	}
 	server.router[method.Value+path] = fn
+	server.routeMethods[path] = addMethod(server.routeMethods[path], method.Value)
+	server.typedRoutes.clear(method.Value, path)
+
+	return nil
+}
+
+// setRouteTypes records the types of a route registered with SetHandlerTyped.
+func (server *HTTPAPIServer) setRouteTypes(method string, path string, route typedRoute) {
+	server.typedRoutes.set(method, path, route)
+}
 
+// SetHandlerMulti registers the same handler function for each of the given methods on a path.
+// It stops at the first method that fails to register and returns its error.
+func (server *HTTPAPIServer) SetHandlerMulti(methods []*common.MethodValue, path string, fn Handler) error {
+	for _, method := range methods {
+		if err := server.SetHandler(method, path, fn); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// SetRawHandler registers a handler whose returned object is sent as the bare response body.
+// See Server.SetRawHandler.
+func (server *HTTPAPIServer) SetRawHandler(method *common.MethodValue, path string, fn RawHandler) error {
+	return server.SetHandler(method, path, rawHandler(fn))
+}
+
+// Routes returns the routes registered with SetHandler, sorted by path and method.
+func (server *HTTPAPIServer) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(server.router))
+	for key, handler := range server.router {
+		// router keys are the method directly followed by the path
+		index := strings.Index(key, "/")
+		if index < 0 {
+			index = len(key)
+		}
+		routes = append(routes, RouteInfo{
+			Method:   key[:index],
+			Path:     key[index:],
+			FuncName: adapter.GetFunctionName(handler),
+		})
+	}
+	return sortRoutes(server.typedRoutes.apply(routes))
+}
+
 // PreRequest registers a handler function that will be executed before every request.
 // This can be used for authentication, logging, or other cross-cutting concerns.
 //
 // The method adds the handler as Echo middleware, which wraps all subsequent handlers.
 // The pre-request handler receives the request and can perform validation or modifications
-// before the main handler is called. If the pre-request handler returns an error,
+// before the main handler is called. If the pre-request handler returns an error or responds,
 // the main handler will not be called.
 //
-// The method also includes special handling for QUERY requests, attempting to find
-// a matching route dynamically if the standard routing fails.
+// Requests the Echo router can't route (e.g. QUERY) are dispatched after the pre-request handler succeeded.
 func (server *HTTPAPIServer) PreRequest(fn Handler) error {
 	server.Echo.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -125,6 +263,8 @@ func (server *HTTPAPIServer) PreRequest(fn Handler) error {
 
 			req := request.NewHTTPAPIRequest(c)
 			responder := responderPackage.NewHTTPAPIResponder(c, server.GetHostname(), funcName)
+			applyResponseFormat(responder, server.config)
+			applyPreResponse(responder, req, server.preResponse)
 			if server.debug {
 				fmt.Println("Before PreHandlerWrapper.processCore: ", req.GetMethod(), req.GetMethod().Value, funcName)
 			}
@@ -135,10 +275,7 @@ func (server *HTTPAPIServer) PreRequest(fn Handler) error {
 					fmt.Println("Exit PreHandlerWrapper.processCore: ", req.GetMethod(), req.GetPath())
 				}
 				if r := recover(); r != nil {
-					if responder != nil {
-						responder.Respond(common.NewErrorResponse("ERROR", "PANIC", "Please try again later."))
-					}
-					log.Println("panic: ", r, string(debug.Stack()))
+					server.respondPanic(r, req, responder)
 				}
 			}()
 
@@ -150,40 +287,51 @@ func (server *HTTPAPIServer) PreRequest(fn Handler) error {
 				fmt.Println("Next handler", next != nil)
 			}
 
-			// If pre-request handler succeeds, continue to the main handler
-			if err == nil {
-				err = next(c)
+			// The pre-request handler failed or responded: the main handler isn't called
+			if err != nil || c.Response().Committed {
+				return nil
 			}
 
+			// Continue to the main handler; routing errors go back to dispatchUnrouted
+			err = next(c)
+
 			if server.debug {
 				fmt.Println("After PreHandlerWrapper.MAIN: ", req.GetMethod().Value, err)
 			}
-
-			// Special handling for QUERY requests - try to find a matching route dynamically
-			if err != nil && !c.Response().Committed && req.GetMethod().Value == "QUERY" {
-				handler, varMap := findRoute(req.GetMethod().Value, req.GetPath(), server.router)
-				if handler != nil {
-					// Apply URL parameters from the matched route
-					if varMap != nil {
-						for key, value := range varMap {
-							req.SetVar(key, value)
-						}
-					}
-					if server.config == nil || !server.config.HideFuncName {
-						responder.SetFuncName(adapter.GetFunctionName(handler))
-					}
-					handler(req, responder)
-				} else {
-					responder.Respond(common.NewErrorResponse("NOT_FOUND", "NOT_FOUND", "Route not found"))
-				}
-			}
-
-			return nil
+			return err
 		}
 	})
 	return nil
 }
 
+// SetPanicHandler sets the function building the response when a handler panics.
+// See Server.SetPanicHandler.
+func (server *HTTPAPIServer) SetPanicHandler(fn PanicHandler) {
+	server.panicHandler = fn
+}
+
+// PreResponse registers a function executed with every response before it's serialized.
+// See Server.PreResponse.
+func (server *HTTPAPIServer) PreResponse(fn PreResponseHandler) error {
+	server.preResponse = fn
+	return nil
+}
+
+// respondPanic responds to a panic recovered while handling the request,
+// with the response of the panic handler or the default PANIC error.
+func (server *HTTPAPIServer) respondPanic(recovered interface{}, req request.APIRequest, responder responderPackage.APIResponder) {
+	var response *common.APIResponse[any]
+	if server.panicHandler != nil {
+		response = server.panicHandler(recovered, req)
+	} else {
+		log.Println("panic: ", recovered, string(debug.Stack()))
+	}
+	if response == nil {
+		response = common.NewErrorResponse("ERROR", "PANIC", "Please try again later.")
+	}
+	responder.Respond(response)
+}
+
 // Expose sets the port number that the server will listen on for HTTP connections.
 func (server *HTTPAPIServer) Expose(port int) {
 	server.Port = port
@@ -202,12 +350,21 @@ func (server *HTTPAPIServer) ExposeSSL(port int) {
 //
 // The WaitGroup parameter allows the caller to wait for the server to exit.
 // The method calls wg.Done() when the server exits, regardless of whether it
-// exited due to an error or normal shutdown.
+// exited due to an error or normal shutdown. The WaitGroup may be nil when the caller doesn't wait.
 func (server *HTTPAPIServer) Start(wg *sync.WaitGroup) {
 	var ps = strconv.Itoa(server.Port)
 	fmt.Println("  [ HTTP Server " + strconv.Itoa(server.ID) + " ] Try to listen at " + ps)
 	server.Echo.HideBanner = true
 
+	// Apply the connection timeouts, protecting against clients holding connections open
+	if server.config != nil {
+		for _, httpServer := range []*http.Server{server.Echo.Server, server.Echo.TLSServer} {
+			httpServer.ReadTimeout = server.config.ReadTimeout
+			httpServer.WriteTimeout = server.config.WriteTimeout
+			httpServer.IdleTimeout = server.config.IdleTimeout
+		}
+	}
+
 	// Start HTTPS server in a separate goroutine if SSL is enabled
 	if server.RunSSL {
 		go func() {
@@ -223,7 +380,50 @@ func (server *HTTPAPIServer) Start(wg *sync.WaitGroup) {
 	if err != nil {
 		fmt.Println("Fail to start " + err.Error())
 	}
-	wg.Done()
+	if wg != nil {
+		wg.Done()
+	}
+}
+
+// Stop gracefully shuts down the HTTP (and HTTPS) listeners and waits until every
+// in-flight request has finished or the context expires.
+// If the deadline is hit, the remaining in-flight count is logged and the context error is returned.
+func (server *HTTPAPIServer) Stop(ctx context.Context) error {
+	err := server.Echo.Shutdown(ctx)
+	drainErr := waitForDrain(ctx, "HTTP Server "+strconv.Itoa(server.ID), &server.inFlight)
+	if drainErr != nil {
+		return drainErr
+	}
+	return err
+}
+
+// InFlight returns the number of requests currently being handled by the server.
+func (server *HTTPAPIServer) InFlight() int64 {
+	return server.inFlight.Load()
+}
+
+// trackInFlight is an Echo middleware that increments the in-flight counter
+// for the duration of the request.
+func (server *HTTPAPIServer) trackInFlight(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		server.inFlight.Add(1)
+		defer server.inFlight.Add(-1)
+		return next(c)
+	}
+}
+
+// requestIDMiddleware is an Echo middleware that assigns a request ID to every request
+// and sets it on the X-Request-Id response header.
+// It also attaches the configured JSON body limits and trusted proxies to the request.
+func (server *HTTPAPIServer) requestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := request.NewHTTPAPIRequest(c)
+		id := assignRequestID(req)
+		applyJSONLimits(req, server.config)
+		applyTrustedProxies(req, server.trustedProxies)
+		c.Response().Header().Set(request.RequestIDHeader, id)
+		return next(c)
+	}
 }
 
 // GetHostname returns the hostname of the server.
@@ -242,6 +442,10 @@ func (server *HTTPAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // This method is called by NewServer after creating the server instance.
 func (server *HTTPAPIServer) SetConfig(config *ServerConfig) {
 	server.config = config
+	server.trustedProxies = parseTrustedProxies(config)
+	if config.Hostname != "" {
+		server.hostname = config.Hostname
+	}
 }
 
 // HandlerWrapper wraps a handler function with common functionality like error handling.
@@ -272,9 +476,19 @@ func (hw *HandlerWrapper) processCore(c echo.Context) error {
 		funcName = adapter.GetFunctionName(hw.handler)
 	}
 
+	// The request context is canceled at the handler timeout, so handlers can stop early
+	timeout := handlerTimeout(hw.server.config)
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+	if timeout > 0 {
+		c.SetRequest(c.Request().WithContext(ctx))
+	}
+
 	// Create request and responder objects
 	req := request.NewHTTPAPIRequest(c)
 	responder := responderPackage.NewHTTPAPIResponder(c, hw.server.GetHostname(), funcName)
+	applyResponseFormat(responder, hw.server.config)
+	applyPreResponse(responder, req, hw.server.preResponse)
 
 	if hw.server.debug {
 		fmt.Println("Before MAIN.processCore: ", req.GetMethod(), req.GetMethod().Value, funcName)
@@ -283,15 +497,12 @@ func (hw *HandlerWrapper) processCore(c echo.Context) error {
 	// Set up panic recovery to ensure we always return a proper response
 	defer func() {
 		if r := recover(); r != nil {
-			if responder != nil {
-				responder.Respond(common.NewErrorResponse("ERROR", "PANIC", "Please try again later."))
-			}
-			log.Println("panic: ", r, string(debug.Stack()))
+			hw.server.respondPanic(r, req, responder)
 		}
 	}()
 
 	// Execute the handler
-	hw.handler(req, responder)
+	callWithTimeout(timeout, cancel, hw.handler, req, responder, c.Response().Flush, nil)
 
 	if hw.server.debug {
 		fmt.Println("After MAIN.processCore: ", req.GetMethod(), req.GetMethod().Value, funcName)
@@ -356,9 +567,14 @@ func findRoute(method string, path string, handlerMap map[string]Handler) (Handl
 		score := 0
 		firstVar := 0
 
+		// Routes with a different number of segments can't match
+		if len(parts) != len(targetParts) {
+			continue
+		}
+
 		// Compare each path segment
 		for i, part := range parts {
-			if part[0] == ':' {
+			if strings.HasPrefix(part, ":") {
 				// This is a path parameter
 				varMap[part[1:]] = targetParts[i]
 				if firstVar == 0 {
@@ -392,3 +608,25 @@ func findRoute(method string, path string, handlerMap map[string]Handler) (Handl
 
 	return nil, nil
 }
+
+// setPathParams stores path parameters in the Echo context so they can be read with GetVar.
+// Echo expects the context's parameter value slice to keep its preallocated length,
+// so values are written into that slice whenever it's large enough.
+func setPathParams(c echo.Context, varMap map[string]string) {
+	names := make([]string, 0, len(varMap))
+	for name := range varMap {
+		names = append(names, name)
+	}
+
+	c.SetParamNames()
+	values := c.ParamValues()
+	if cap(values) < len(names) {
+		values = make([]string, len(names))
+		c.SetParamValues(values...)
+	}
+	values = values[:len(names)]
+	for i, name := range names {
+		values[i] = varMap[name]
+	}
+	c.SetParamNames(names...)
+}
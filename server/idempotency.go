@@ -0,0 +1,185 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/responder"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the key identifying a request sent several times,
+	// e.g. when a client retries a POST
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader flags the responses replayed for a repeated idempotency key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// DefaultIdempotencyTTL is how long NewMemoryIdempotencyStore keeps responses when no TTL is given.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// IdempotencyStore stores the responses of the requests sent with an idempotency key, see Idempotent.
+// Implementations must be safe for concurrent use, e.g. backed by a shared cache for several instances.
+type IdempotencyStore interface {
+	// Get returns the response stored for the key, nil when there is none or it expired
+	Get(key string) *common.APIResponse[any]
+	// Set stores the response of the key
+	Set(key string, response *common.APIResponse[any])
+}
+
+// Idempotent wraps a handler so requests repeating an Idempotency-Key header get the stored response of the
+// first one, with the Idempotent-Replayed header, rather than running the handler again. This makes POST
+// requests safe to retry. Requests without the header run the handler as usual.
+//
+// Keys are scoped by method and path. The replay has the status, data and headers of the first response,
+// including the headers set with SetHeader, SetNextCursor or SetCacheControl; the data items themselves are
+// shared with the first response. Error (5xx) responses aren't stored so the request can be retried,
+// and neither are raw or streamed responses. A duplicate arriving while the first request is still handled
+// gets an EXISTED error with the IDEMPOTENCY_KEY_IN_USE code.
+func Idempotent(store IdempotencyStore, fn Handler) Handler {
+	var inFlight sync.Map
+	return func(req request.APIRequest, res responder.APIResponder) error {
+		key := req.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			return fn(req, res)
+		}
+		key = req.GetMethod().Value + " " + req.GetPath() + " " + key
+
+		if response := store.Get(key); response != nil {
+			return replay(res, response)
+		}
+		if _, busy := inFlight.LoadOrStore(key, true); busy {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.Existed, "IDEMPOTENCY_KEY_IN_USE",
+				"A request with the same idempotency key is being handled"))
+		}
+		defer inFlight.Delete(key)
+		// the first request may have completed between the lookup above and claiming the key
+		if response := store.Get(key); response != nil {
+			return replay(res, response)
+		}
+
+		recorder := &recordingResponder{APIResponder: res}
+		err := fn(req, recorder)
+		if recorder.response != nil && recorder.response.Status != common.APIStatus.Error && recorder.response.Status != common.APIStatus.Timeout {
+			store.Set(key, recorder.response)
+		}
+		return err
+	}
+}
+
+// replay sends a copy of the stored response, flagged with the Idempotent-Replayed header.
+// The stored response is left untouched for the next replays.
+func replay(res responder.APIResponder, stored *common.APIResponse[any]) error {
+	res.SetHeader(IdempotentReplayedHeader, "true")
+	return res.Respond(copyResponse(stored, nil))
+}
+
+// copyResponse returns a copy of the response with its own data slice and headers, adding the headers
+// set on the responder; like for the responders, the response headers take precedence.
+func copyResponse(response *common.APIResponse[any], headers map[string]string) *common.APIResponse[any] {
+	copied := *response
+	copied.Data = append([]any(nil), response.Data...)
+	copied.Headers = make(map[string]string, len(headers)+len(response.Headers))
+	for key, value := range headers {
+		copied.Headers[key] = value
+	}
+	for key, value := range response.Headers {
+		copied.Headers[key] = value
+	}
+	return &copied
+}
+
+// recordingResponder keeps the response sent through the wrapped responder, with the headers set on it.
+type recordingResponder struct {
+	responder.APIResponder
+	// headers are the headers set on the responder, sent with the response
+	headers map[string]string
+	// response is a copy of the response sent with Respond, with the headers; nil until then
+	response *common.APIResponse[any]
+}
+
+// Respond sends the response, keeping a copy when it's sent. The copy is made first as responders
+// may modify the response, e.g. moving its headers to the HTTP response.
+func (r *recordingResponder) Respond(response *common.APIResponse[any]) error {
+	var recorded *common.APIResponse[any]
+	if response != nil {
+		recorded = copyResponse(response, r.headers)
+	}
+	err := r.APIResponder.Respond(response)
+	if err == nil {
+		r.response = recorded
+	}
+	return err
+}
+
+// SetHeader sets the header on the responder, recording it.
+func (r *recordingResponder) SetHeader(name string, value string) {
+	if r.headers == nil {
+		r.headers = make(map[string]string)
+	}
+	r.headers[name] = value
+	r.APIResponder.SetHeader(name, value)
+}
+
+// SetNextCursor sets the X-Next-Cursor header, recording it.
+func (r *recordingResponder) SetNextCursor(cursor string) {
+	r.SetHeader(common.NextCursorHeader, cursor)
+}
+
+// SetCacheControl sets the Cache-Control header, recording it.
+func (r *recordingResponder) SetCacheControl(maxAge time.Duration, public bool) {
+	r.SetHeader(responder.CacheControlHeader, responder.CacheControl(maxAge, public))
+}
+
+// NoCache sets the Cache-Control header so the response isn't cached, recording it.
+func (r *recordingResponder) NoCache() {
+	r.SetCacheControl(0, false)
+}
+
+// memoryIdempotencyStore is an in-memory IdempotencyStore expiring responses after a TTL.
+type memoryIdempotencyStore struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	entries map[string]idempotencyEntry
+}
+
+// idempotencyEntry is a stored response with its expiration time.
+type idempotencyEntry struct {
+	response  *common.APIResponse[any]
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore creates an IdempotencyStore keeping responses in memory for the TTL,
+// DefaultIdempotencyTTL when 0. Responses aren't shared across instances.
+func NewMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return &memoryIdempotencyStore{ttl: ttl, entries: make(map[string]idempotencyEntry)}
+}
+
+// Get implements IdempotencyStore.
+func (s *memoryIdempotencyStore) Get(key string) *common.APIResponse[any] {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return entry.response
+}
+
+// Set implements IdempotencyStore, evicting the expired entries.
+func (s *memoryIdempotencyStore) Set(key string, response *common.APIResponse[any]) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	for k, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = idempotencyEntry{response: response, expiresAt: now.Add(s.ttl)}
+}
@@ -3,10 +3,20 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	sdk "github.com/phnam/go-protocol-adapter"
 	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/responder"
 )
 
 // idCounter is used to generate unique IDs for server instances
@@ -22,11 +32,114 @@ type ServerConfig struct {
 	// HideFuncName determines whether function names should be included in response headers
 	HideFuncName bool
 
+	// Hostname overrides the OS hostname reported in the X-Hostname response header
+	Hostname string
+
 	// BufferSize specifies the buffer size in bytes for Thrift server transport
 	BufferSize int
 
 	// MessageSize specifies the maximum message size in bytes for Thrift server
 	MessageSize int32
+
+	// MaxThriftHeaderSize is the limit of the total size in bytes (names and values) of the headers of
+	// Thrift responses, responder.DefaultMaxThriftHeaderSize when 0. Responses with larger headers are
+	// replaced by a HEADERS_TOO_LARGE error keeping only the adapter headers.
+	MaxThriftHeaderSize int
+
+	// ThriftTransport specifies the Thrift transport layer (common.ThriftTransport), FRAMED by default.
+	// Clients must be configured with the same transport.
+	ThriftTransport string
+
+	// UnixSocket is the path of a Unix socket the Thrift server listens on instead of the exposed port,
+	// for low-overhead calls from clients on the same host (addressed as "unix://" + path).
+	// A socket file left at the path by a previous run is removed; any other existing file makes Start fail.
+	UnixSocket string
+
+	// ThriftMethodName is the Thrift method serving the API ("call" by default).
+	// Services sharing one transport use different names, clients must be configured with the same name.
+	ThriftMethodName string
+
+	// GzipEnabled determines whether HTTP responses, and the content of Thrift responses, are gzip-compressed
+	// for clients accepting it (default true when nil)
+	GzipEnabled *bool
+
+	// GzipMinLength is the minimum response size in bytes to compress; smaller responses are sent as-is
+	GzipMinLength int
+
+	// MaxJSONDepth caps the nesting depth of JSON bodies parsed with ParseBody, unlimited when 0
+	MaxJSONDepth int
+
+	// MaxJSONTokens caps the number of tokens of JSON bodies parsed with ParseBody, unlimited when 0
+	MaxJSONTokens int
+
+	// MaxDecodedBodySize caps the size in bytes of HTTP request bodies sent with Content-Encoding gzip or deflate
+	// once decompressed, request.DefaultMaxDecodedBodySize when 0. Larger bodies are rejected by ParseBody
+	// with an INVALID_BODY_SIZE error, without being buffered.
+	MaxDecodedBodySize int64
+
+	// ReadTimeout is the maximum duration for reading an entire HTTP request, including the body (no limit when 0)
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum duration before timing out writes of an HTTP response (no limit when 0)
+	WriteTimeout time.Duration
+
+	// IdleTimeout is the maximum time to wait for the next request on a keep-alive HTTP connection.
+	// ReadTimeout is used when 0.
+	IdleTimeout time.Duration
+
+	// HandlerTimeout is the maximum duration of a route handler (no limit when 0). Past it, a TIMEOUT error is sent
+	// (HTTP 504) and the request context is canceled; handlers should stop when req.Context() is done.
+	HandlerTimeout time.Duration
+
+	// CORS enables Cross-Origin Resource Sharing for the HTTP server when set.
+	// Preflight requests to registered paths are answered automatically.
+	CORS *CORSConfig
+
+	// ResponseNaming names the struct fields of response data that don't have a name in their json tag,
+	// e.g. responder.SnakeCase. Go field names are used when nil. Types can override it with responder.NamingOverride.
+	ResponseNaming responder.NamingStrategy
+
+	// ResponseEnvelope builds the JSON body of HTTP responses instead of the default {status, data, message, ...}
+	// shape, e.g. to keep an existing public contract. Thrift responses keep their Thrift envelope.
+	ResponseEnvelope responder.EnvelopeEncoder
+
+	// ResponseEncoders adds HTTP response formats by media type, e.g. "application/xml", besides JSON.
+	// The format is negotiated with the request Accept header, honoring q-values; JSON is used when none is acceptable.
+	ResponseEncoders map[string]responder.BodyEncoder
+
+	// Marshaler encodes JSON responses and decodes JSON request bodies (ParseBody), e.g. backed by jsoniter
+	// or sonic for large payloads. encoding/json is used when nil.
+	Marshaler common.Marshaler
+
+	// TrustedProxies lists the CIDRs (or single IPs) of the proxies allowed to set X-Forwarded-For, e.g. "10.0.0.0/8".
+	// When set, GetIP only honors the header if the peer is a trusted proxy, preventing clients from spoofing their IP.
+	// Invalid entries are logged and ignored.
+	TrustedProxies []string
+}
+
+// RouteInfo describes a route registered on a server.
+type RouteInfo struct {
+	// Method is the HTTP method (or equivalent operation type) of the route
+	Method string
+	// Path is the path pattern of the route, including path parameters (e.g. "/users/:id")
+	Path string
+	// FuncName is the name of the handler function
+	FuncName string
+	// RequestType is the type the request body is decoded into, for routes registered with SetHandlerTyped
+	RequestType reflect.Type
+	// ResponseType is the type of the response data, for routes registered with SetHandlerTyped
+	ResponseType reflect.Type
+}
+
+// sortRoutes orders routes by path, then by method, so listings are stable.
+func sortRoutes(routes []RouteInfo) []RouteInfo {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
 }
 
 // Server defines the common interface for all protocol server implementations.
@@ -34,19 +147,35 @@ type ServerConfig struct {
 type Server interface {
 	// PreRequest registers a handler function that will be executed before every request.
 	// This can be used for authentication, logging, or other cross-cutting concerns.
+	// The attributes (SetAttribute) and path variables (SetVar) it sets on the request are visible
+	// to the route handler with both protocols; nothing else set on the request crosses over.
 	PreRequest(Handler) error
 
+	// PreResponse registers a function executed with every response sent by the handlers, before it's
+	// serialized. It can mutate the response, e.g. add headers or adjust the status. When it returns an
+	// error, the error response built from it is sent instead.
+	PreResponse(PreResponseHandler) error
+
 	// SetHandler registers a handler function for a specific HTTP method and path.
 	// The method parameter specifies the HTTP method (GET, POST, etc.)
 	// The path parameter specifies the URL path to match
 	// The fn parameter is the handler function to execute when the route is matched
 	SetHandler(*common.MethodValue, string, Handler) error
 
+	// SetHandlerMulti registers the same handler function for several methods on a path,
+	// e.g. POST and PUT, or GET and HEAD
+	SetHandlerMulti([]*common.MethodValue, string, Handler) error
+
+	// SetRawHandler registers a handler whose returned object is serialized directly as the
+	// response body, without the {status, data, ...} envelope, e.g. for third-party integrations.
+	// A returned error is sent as a regular error response, with the status code mapped from its error code.
+	SetRawHandler(*common.MethodValue, string, RawHandler) error
+
 	// Expose sets the port number that the server will listen on
 	Expose(int)
 
 	// Start begins listening for incoming requests on the configured port.
-	// It takes a WaitGroup parameter to allow the caller to wait for the server to exit.
+	// It takes a WaitGroup parameter to allow the caller to wait for the server to exit, or nil.
 	Start(*sync.WaitGroup)
 
 	// GetHostname returns the hostname of the server
@@ -57,6 +186,135 @@ type Server interface {
 
 	// SetConfig applies the provided configuration to the server
 	SetConfig(*ServerConfig)
+
+	// Stop stops accepting new requests and waits for in-flight requests to drain.
+	// It returns the context error if the context expires before the drain completes.
+	Stop(context.Context) error
+
+	// InFlight returns the number of requests currently being handled
+	InFlight() int64
+
+	// Routes returns the registered routes with their method, path pattern and handler name
+	Routes() []RouteInfo
+
+	// SetPanicHandler sets the function building the response when a handler panics,
+	// e.g. to include a trace ID, and logging the panic with the application logger.
+	// When unset or when it returns nil, a generic error response is sent; without a handler
+	// the panic and its stack are logged to stdout. debug.Stack() called from the handler
+	// includes the panicking frames.
+	SetPanicHandler(PanicHandler)
+
+	// SetFallbackHandler registers a catch-all handler executed when no route matches the request,
+	// e.g. to proxy unknown paths or build a custom 404. It receives the full request and responder.
+	// When unset, a NOT_FOUND response is sent.
+	SetFallbackHandler(Handler)
+
+	// SetSecurityHeaders sends hardening headers (X-Content-Type-Options, X-Frame-Options,
+	// Strict-Transport-Security, Referrer-Policy) with every HTTP response, with defaults for the
+	// unset fields of the config. Thrift servers ignore it.
+	SetSecurityHeaders(SecurityHeadersConfig)
+}
+
+// notFoundResponse is the response sent by both servers when no route matches the request
+// and no fallback handler is registered.
+func notFoundResponse(method string, path string) *common.APIResponse[any] {
+	return common.NewErrorResponse(common.APIStatus.NotFound, "NOT_FOUND", "[SDK] Route not found for "+method+" "+path)
+}
+
+// PanicHandler builds the response sent when a handler panics, from the recovered value and the request.
+type PanicHandler = func(recovered interface{}, req request.APIRequest) *common.APIResponse[any]
+
+// PreResponseHandler is called with the request and its response before the response is serialized,
+// see Server.PreResponse.
+type PreResponseHandler = func(req request.APIRequest, resp *common.APIResponse[any]) error
+
+// RawHandler handles a request registered with SetRawHandler, returning the object sent as the response body.
+type RawHandler = func(req request.APIRequest) (interface{}, error)
+
+// rawHandler adapts a RawHandler to a Handler, encoding successful responses without the envelope.
+func rawHandler(fn RawHandler) Handler {
+	return func(req request.APIRequest, res responder.APIResponder) error {
+		obj, err := fn(req)
+		if err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		res.SetEnvelopeEncoder(func(response *common.APIResponse[any], data interface{}) interface{} {
+			return data
+		})
+		return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, obj, "", "", 0, nil))
+	}
+}
+
+// callWithTimeout runs the handler, answering with a TIMEOUT error when it hasn't returned within the timeout,
+// then canceling the request context with cancel. Canceling only once the timeout response is sent keeps handlers
+// reacting to the context from responding first. A panic of the handler is re-raised in the calling goroutine,
+// so the usual recovery applies. When flush is set (HTTP), it's called to send the timeout response right away,
+// then the handler is awaited since Echo recycles the request context once the route returns. Otherwise (Thrift)
+// the handler is left running and counted in inFlight until it returns, so Stop waits for it.
+func callWithTimeout(timeout time.Duration, cancel context.CancelFunc, fn Handler, req request.APIRequest, res responder.APIResponder,
+	flush func(), inFlight *atomic.Int64) error {
+	if timeout <= 0 {
+		return fn(req, res)
+	}
+
+	type result struct {
+		err       error
+		recovered interface{}
+		panicked  bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		panicked := true
+		defer func() {
+			if panicked {
+				done <- result{recovered: recover(), panicked: true}
+			}
+		}()
+		err := fn(req, res)
+		panicked = false
+		done <- result{err: err}
+	}()
+	finish := func(r result) error {
+		if r.panicked {
+			panic(r.recovered)
+		}
+		return r.err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return finish(r)
+	case <-timer.C:
+	}
+
+	// the handler may have responded just in time, its response is kept then
+	timeoutResp := common.NewErrorResponse(common.APIStatus.Timeout, "TIMEOUT", "Request not handled within "+timeout.String())
+	err := res.Respond(timeoutResp)
+	cancel()
+	if err != nil {
+		return finish(<-done)
+	}
+	if flush != nil {
+		flush()
+		finish(<-done)
+		return nil
+	}
+	inFlight.Add(1)
+	go func() {
+		<-done
+		inFlight.Add(-1)
+	}()
+	return nil
+}
+
+// handlerTimeout returns the configured handler timeout, 0 when there is none.
+func handlerTimeout(config *ServerConfig) time.Duration {
+	if config == nil {
+		return 0
+	}
+	return config.HandlerTimeout
 }
 
 // NewServer creates a new server instance based on the provided configuration.
@@ -77,3 +335,108 @@ func NewServer(config ServerConfig) Server {
 
 	return server
 }
+
+// drainPollInterval is how often waitForDrain re-checks the in-flight counter
+var drainPollInterval = 10 * time.Millisecond
+
+// waitForDrain blocks until the in-flight counter reaches zero or the context expires.
+// If the context expires first, the remaining in-flight count is logged and the
+// context error is returned.
+func waitForDrain(ctx context.Context, name string, inFlight *atomic.Int64) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			fmt.Println("  [ " + name + " ] Shutdown deadline reached with " + strconv.FormatInt(inFlight.Load(), 10) + " request(s) in flight")
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// assignRequestID reads the incoming X-Request-Id header, generating a new ID when absent,
+// and stores it as a request attribute so handlers can read it via GetRequestID.
+func assignRequestID(req request.APIRequest) string {
+	id := req.GetHeader(request.RequestIDHeader)
+	if id == "" {
+		id = sdk.NewUUID()
+	}
+	req.SetAttribute(request.RequestIDAttribute, id)
+	return id
+}
+
+// applyResponseFormat sets the configured naming strategy, envelope encoder, body encoders and marshaler on the responder.
+func applyResponseFormat(res responder.APIResponder, config *ServerConfig) {
+	if config == nil {
+		return
+	}
+	if config.ResponseNaming != nil {
+		res.SetNamingStrategy(config.ResponseNaming)
+	}
+	if config.ResponseEnvelope != nil {
+		res.SetEnvelopeEncoder(config.ResponseEnvelope)
+	}
+	if config.ResponseEncoders != nil {
+		res.SetBodyEncoders(config.ResponseEncoders)
+	}
+	if config.Marshaler != nil {
+		res.SetMarshaler(config.Marshaler)
+	}
+}
+
+// applyPreResponse binds the PreResponse handler to the request and sets it on the responder.
+func applyPreResponse(res responder.APIResponder, req request.APIRequest, fn PreResponseHandler) {
+	if fn == nil {
+		return
+	}
+	res.SetPreResponse(func(resp *common.APIResponse[any]) error {
+		return fn(req, resp)
+	})
+}
+
+// parseTrustedProxies parses the configured trusted proxies, logging and ignoring invalid entries.
+// Returns nil when none are configured.
+func parseTrustedProxies(config *ServerConfig) request.TrustedProxies {
+	if config == nil || len(config.TrustedProxies) == 0 {
+		return nil
+	}
+	proxies := request.TrustedProxies{}
+	for _, cidr := range config.TrustedProxies {
+		parsed, err := request.ParseTrustedProxies([]string{cidr})
+		if err != nil {
+			fmt.Println("  [ Config ] Ignoring trusted proxy: " + err.Error())
+			continue
+		}
+		proxies = append(proxies, parsed...)
+	}
+	return proxies
+}
+
+// applyTrustedProxies stores the trusted proxies as a request attribute, so GetIP only honors
+// X-Forwarded-For headers set by them.
+func applyTrustedProxies(req request.APIRequest, proxies request.TrustedProxies) {
+	if proxies != nil {
+		req.SetAttribute(request.TrustedProxiesAttribute, proxies)
+	}
+}
+
+// applyJSONLimits stores the configured body limits and marshaler as request attributes,
+// so ParseBody rejects oversized payloads and decodes bodies with the marshaler.
+func applyJSONLimits(req request.APIRequest, config *ServerConfig) {
+	if config != nil && config.Marshaler != nil {
+		req.SetAttribute(request.MarshalerAttribute, config.Marshaler)
+	}
+	if config != nil && config.MaxDecodedBodySize > 0 {
+		req.SetAttribute(request.MaxDecodedBodySizeAttribute, config.MaxDecodedBodySize)
+	}
+	if config == nil || (config.MaxJSONDepth <= 0 && config.MaxJSONTokens <= 0) {
+		return
+	}
+	req.SetAttribute(request.JSONLimitsAttribute, &request.JSONLimits{
+		MaxDepth:  config.MaxJSONDepth,
+		MaxTokens: config.MaxJSONTokens,
+	})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ShutdownGracePeriod is how long RunWithGracefulShutdown waits for in-flight requests
+// to drain after receiving a termination signal.
+var ShutdownGracePeriod = 30 * time.Second
+
+// RunWithGracefulShutdown starts the servers and blocks until the process receives SIGINT or SIGTERM.
+// Each server is then stopped, so it stops accepting new requests and drains the in-flight ones
+// within ShutdownGracePeriod.
+//
+// It returns the first error reported by a server Stop, e.g. when the grace period expires
+// before every request has finished.
+func RunWithGracefulShutdown(servers ...Server) error {
+	// listen for signals before starting, so an early signal isn't missed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go server.Start(&wg)
+	}
+
+	sig := <-signals
+	fmt.Println("  [ Shutdown ] Received " + sig.String() + ", stopping " + fmt.Sprint(len(servers)) + " server(s)")
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownGracePeriod)
+	defer cancel()
+
+	// stop the servers concurrently, so they share the grace period
+	errs := make([]error, len(servers))
+	var stopWg sync.WaitGroup
+	for i, server := range servers {
+		stopWg.Add(1)
+		go func(i int, server Server) {
+			defer stopWg.Done()
+			errs[i] = server.Stop(ctx)
+		}(i, server)
+	}
+	stopWg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/thriftapi"
+)
+
+// compressThriftContent gzips the Content of the response when the client sent "Accept-Encoding: gzip",
+// flagging it with the "Content-Encoding: gzip" header, like the HTTP gzip middleware.
+// Compression is skipped when disabled with GzipEnabled, for content shorter than GzipMinLength,
+// and when it doesn't make the content smaller.
+func compressThriftContent(resp *thriftapi.APIResponse, req request.APIRequest, config *ServerConfig) {
+	if resp == nil || resp.Content == "" || !strings.Contains(req.GetHeader("Accept-Encoding"), "gzip") {
+		return
+	}
+	if config != nil && (config.GzipEnabled != nil && !*config.GzipEnabled || len(resp.Content) < config.GzipMinLength) {
+		return
+	}
+	if resp.Headers["Content-Encoding"] != "" {
+		return
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(resp.Content))
+	if gw.Close() != nil || buf.Len() >= len(resp.Content) {
+		return
+	}
+	resp.Content = buf.String()
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers["Content-Encoding"] = "gzip"
+}
@@ -2,14 +2,17 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/apache/thrift/lib/go/thrift"
 	sdk "github.com/phnam/go-protocol-adapter"
@@ -34,6 +37,14 @@ type ThriftServer struct {
 	hostname string
 	// config holds the server configuration
 	config *ServerConfig
+	// inFlight counts the requests currently being handled
+	inFlight atomic.Int64
+	// processorFunctions holds additional Thrift methods served alongside "call"
+	processorFunctions map[string]thrift.TProcessorFunction
+	// trustedProxies are the parsed TrustedProxies of the configuration
+	trustedProxies requestPackage.TrustedProxies
+	// typedRoutes holds the types of the routes registered with SetHandlerTyped
+	typedRoutes typedRoutes
 }
 
 // NewThriftServer creates a new Thrift API server instance.
@@ -44,9 +55,11 @@ func NewThriftServer() Server {
 	hostname, _ := os.Hostname()
 
 	server := &ThriftServer{
-		ID:       idCounter,
-		port:     8080, // default port
-		hostname: hostname,
+		ID:                 idCounter,
+		port:               8080, // default port
+		hostname:           hostname,
+		processorFunctions: make(map[string]thrift.TProcessorFunction),
+		typedRoutes:        typedRoutes{},
 		config: &ServerConfig{
 			// Default buffer size for transport (24KB)
 			BufferSize: 1024 * 24,
@@ -75,9 +88,102 @@ func NewThriftServer() Server {
 func (server *ThriftServer) SetHandler(method *common.MethodValue, path string, fn Handler) error {
 	fullPath := string(method.Value) + "://" + path
 	server.thriftHandler.Handlers[fullPath] = fn
+	server.typedRoutes.clear(method.Value, path)
 	return nil
 }
 
+// setRouteTypes records the types of a route registered with SetHandlerTyped.
+func (server *ThriftServer) setRouteTypes(method string, path string, route typedRoute) {
+	server.typedRoutes.set(method, path, route)
+}
+
+// SetHandlerMulti registers the same handler function for each of the given methods on a path.
+// It stops at the first method that fails to register and returns its error.
+func (server *ThriftServer) SetHandlerMulti(methods []*common.MethodValue, path string, fn Handler) error {
+	for _, method := range methods {
+		if err := server.SetHandler(method, path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRawHandler registers a handler whose returned object is sent as the response data.
+// Thrift responses keep their Thrift envelope. See Server.SetRawHandler.
+func (server *ThriftServer) SetRawHandler(method *common.MethodValue, path string, fn RawHandler) error {
+	return server.SetHandler(method, path, rawHandler(fn))
+}
+
+// Routes returns the routes registered with SetHandler, sorted by path and method.
+func (server *ThriftServer) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(server.thriftHandler.Handlers))
+	for key, handler := range server.thriftHandler.Handlers {
+		// handler keys have the format "METHOD://path"
+		methodPath := strings.SplitN(key, "://", 2)
+		if len(methodPath) != 2 {
+			continue
+		}
+		routes = append(routes, RouteInfo{
+			Method:   methodPath[0],
+			Path:     methodPath[1],
+			FuncName: sdk.GetFunctionName(handler),
+		})
+	}
+	return sortRoutes(server.typedRoutes.apply(routes))
+}
+
+// AddProcessorFunction registers an additional Thrift method served alongside the generated "call" method.
+// This allows exposing true RPC methods with their own argument and result structs.
+// The function is responsible for reading the arguments and writing the reply message.
+// It must be called before Start; registering "call" replaces the default API dispatch.
+//
+// Parameters:
+// - name: The Thrift method name
+// - fn: The processor function handling the method
+func (server *ThriftServer) AddProcessorFunction(name string, fn thrift.TProcessorFunction) {
+	server.processorFunctions[name] = fn
+}
+
+// Processor builds the Thrift processor handling incoming requests, serving the API under
+// the configured ThriftMethodName along with the functions added with AddProcessorFunction.
+// Start uses it; it can also be used to serve several adapter services on one transport,
+// by adding the functions of each processor's ProcessorMap to a single processor.
+func (server *ThriftServer) Processor() thrift.TProcessor {
+	proc := thriftapi.NewAPIServiceProcessor(server.thriftHandler)
+	if name := server.config.ThriftMethodName; name != "" && name != "call" {
+		fn := proc.ProcessorMap()["call"]
+		delete(proc.ProcessorMap(), "call")
+		proc.AddToProcessorMap(name, &renamedProcessorFunction{fn: fn, name: name})
+	}
+	for name, fn := range server.processorFunctions {
+		proc.AddToProcessorMap(name, fn)
+	}
+	return proc
+}
+
+// renamedProcessorFunction serves a processor function under another method name,
+// naming its replies accordingly so clients accept them.
+type renamedProcessorFunction struct {
+	fn   thrift.TProcessorFunction
+	name string
+}
+
+// Process implements thrift.TProcessorFunction.
+func (f *renamedProcessorFunction) Process(ctx context.Context, seqID int32, in, out thrift.TProtocol) (bool, thrift.TException) {
+	return f.fn.Process(ctx, seqID, in, &renamedProtocol{TProtocol: out, name: f.name})
+}
+
+// renamedProtocol writes messages under a fixed method name.
+type renamedProtocol struct {
+	thrift.TProtocol
+	name string
+}
+
+// WriteMessageBegin writes the message header with the method name of the protocol.
+func (p *renamedProtocol) WriteMessageBegin(ctx context.Context, name string, typeID thrift.TMessageType, seqID int32) error {
+	return p.TProtocol.WriteMessageBegin(ctx, p.name, typeID, seqID)
+}
+
 // PreRequest registers a handler function that will be executed before every request.
 // This can be used for authentication, logging, or other cross-cutting concerns.
 //
@@ -89,6 +195,35 @@ func (server *ThriftServer) PreRequest(fn Handler) error {
 	return nil
 }
 
+// PreResponse registers a function executed with every response before it's serialized.
+// See Server.PreResponse.
+func (server *ThriftServer) PreResponse(fn PreResponseHandler) error {
+	server.thriftHandler.preResponse = fn
+	return nil
+}
+
+// SetPanicHandler sets the function building the response when a handler panics.
+// See Server.SetPanicHandler.
+func (server *ThriftServer) SetPanicHandler(fn PanicHandler) {
+	server.thriftHandler.panicHandler = fn
+}
+
+// SetFallbackHandler registers a catch-all handler executed when no route matches the request.
+// See Server.SetFallbackHandler.
+func (server *ThriftServer) SetFallbackHandler(fn Handler) {
+	server.thriftHandler.fallbackHandler = fn
+}
+
+// SetNotFoundHandler registers a handler that is executed when no route matches the request,
+// like with the HTTP server. It's the same handler as SetFallbackHandler.
+func (server *ThriftServer) SetNotFoundHandler(fn Handler) {
+	server.thriftHandler.fallbackHandler = fn
+}
+
+// SetSecurityHeaders is ignored by the Thrift server, whose responses have no HTTP headers.
+func (server *ThriftServer) SetSecurityHeaders(config SecurityHeadersConfig) {
+}
+
 // Expose sets the port number that the server will listen on.
 // This method must be called before Start() to configure the server's listening port.
 func (server *ThriftServer) Expose(port int) {
@@ -101,31 +236,29 @@ func (server *ThriftServer) Expose(port int) {
 //
 // The server uses:
 // - TServerSocket for the transport layer
-// - TFramedTransport with buffering for framing
+// - TFramedTransport with buffering for framing (or the transport set in ThriftTransport)
 // - TBinaryProtocol for serialization
 //
 // The WaitGroup parameter allows the caller to wait for the server to exit.
 // The method calls wg.Done() when the server exits, regardless of whether it
-// exited due to an error or normal shutdown.
+// exited due to an error or normal shutdown. The WaitGroup may be nil when the caller doesn't wait.
+// It panics when the server can't listen, e.g. when UnixSocket is an existing file that isn't a socket.
 func (server *ThriftServer) Start(wg *sync.WaitGroup) {
 	var ps = strconv.Itoa(server.port)
+	if server.config.UnixSocket != "" {
+		ps = server.config.UnixSocket
+	}
 	fmt.Println("  [ Thrift Server " + strconv.Itoa(server.ID) + " ] Try to listen at " + ps)
 
-	// Create a TCP socket transport
-	var transport thrift.TServerTransport
-	transport, _ = thrift.NewTServerSocket("0.0.0.0:" + ps)
-
-	// Create a processor that will handle incoming requests
-	proc := thriftapi.NewAPIServiceProcessor(server.thriftHandler)
+	// Create a TCP socket transport, or a Unix socket one when configured
+	transport, err := server.serverTransport(ps)
+	if err != nil {
+		panic(err)
+	}
 
 	// Create the server with the configured transport, protocol, and processor
-	server.rootServer = thrift.NewTSimpleServer4(proc, transport,
-		// Use framed transport with buffering for better performance
-		thrift.NewTFramedTransportFactoryConf(
-			thrift.NewTBufferedTransportFactory(server.config.BufferSize),
-			&thrift.TConfiguration{
-				MaxFrameSize: server.config.MessageSize,
-			}),
+	server.rootServer = thrift.NewTSimpleServer4(server.Processor(), transport,
+		server.transportFactory(),
 		// Use binary protocol for serialization
 		thrift.NewTBinaryProtocolFactoryConf(
 			&thrift.TConfiguration{
@@ -133,11 +266,83 @@ func (server *ThriftServer) Start(wg *sync.WaitGroup) {
 			}))
 
 	// Start the server (blocks until server exits)
-	err := server.rootServer.Serve()
+	err = server.rootServer.Serve()
 	if err != nil {
 		panic(err)
 	}
-	wg.Done()
+	if wg != nil {
+		wg.Done()
+	}
+}
+
+// serverTransport creates the server socket listening on the TCP port, or on the Unix socket when configured.
+// The socket listens right away so its errors are reported here; Serve doesn't listen again.
+func (server *ThriftServer) serverTransport(port string) (thrift.TServerTransport, error) {
+	var socket *thrift.TServerSocket
+	if server.config.UnixSocket == "" {
+		var err error
+		if socket, err = thrift.NewTServerSocket("0.0.0.0:" + port); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := removeStaleSocket(server.config.UnixSocket); err != nil {
+			return nil, err
+		}
+		socket = thrift.NewTServerSocketFromAddrTimeout(&net.UnixAddr{Name: server.config.UnixSocket, Net: "unix"}, 0)
+	}
+	if err := socket.Listen(); err != nil {
+		return nil, err
+	}
+	return socket, nil
+}
+
+// removeStaleSocket removes the socket file left at the path by a previous run, which would make the listen fail.
+// A path that isn't a socket (e.g. a misconfigured data file) is left untouched and reported as an error.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.New("unix socket path " + path + " exists and isn't a socket")
+	}
+	return os.Remove(path)
+}
+
+// Stop stops accepting new connections and waits until every in-flight request
+// has finished or the context expires.
+// Idle client connections are not waited for; only requests being processed count.
+// If the deadline is hit, the remaining in-flight count is logged and the context error is returned.
+func (server *ThriftServer) Stop(ctx context.Context) error {
+	if server.rootServer != nil {
+		// TSimpleServer.Stop blocks until all client connections close, so run it in the background
+		go server.rootServer.Stop()
+	}
+	return waitForDrain(ctx, "Thrift Server "+strconv.Itoa(server.ID), &server.inFlight)
+}
+
+// InFlight returns the number of requests currently being handled by the server.
+func (server *ThriftServer) InFlight() int64 {
+	return server.inFlight.Load()
+}
+
+// transportFactory builds the transport factory matching the configured ThriftTransport.
+// Framed transport with buffering is used by default for better performance.
+func (server *ThriftServer) transportFactory() thrift.TTransportFactory {
+	switch server.config.ThriftTransport {
+	case common.ThriftTransport.BUFFERED:
+		return thrift.NewTBufferedTransportFactory(server.config.BufferSize)
+	case common.ThriftTransport.PLAIN:
+		return thrift.NewTTransportFactory()
+	}
+	return thrift.NewTFramedTransportFactoryConf(
+		thrift.NewTBufferedTransportFactory(server.config.BufferSize),
+		&thrift.TConfiguration{
+			MaxFrameSize: server.config.MessageSize,
+		})
 }
 
 // GetHostname returns the hostname of the server.
@@ -157,6 +362,11 @@ func (server *ThriftServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // It updates the server's configuration with the provided values.
 func (server *ThriftServer) SetConfig(config *ServerConfig) {
 	server.config = config
+	server.trustedProxies = parseTrustedProxies(config)
+	if config.Hostname != "" {
+		server.hostname = config.Hostname
+		server.thriftHandler.hostname = config.Hostname
+	}
 }
 
 // ThriftHandler implements the Thrift service interface for handling API requests.
@@ -167,6 +377,12 @@ type ThriftHandler struct {
 	Handlers map[string]Handler
 	// preHandler is the optional handler function executed before every request
 	preHandler Handler
+	// panicHandler is the optional function building the response to a panic
+	panicHandler PanicHandler
+	// fallbackHandler is the optional handler executed when no route matches
+	fallbackHandler Handler
+	// preResponse is the optional function executed with every response before it's serialized
+	preResponse PreResponseHandler
 	// hostname stores the server's hostname for inclusion in response headers
 	hostname string
 	// server is a reference to the parent Thrift server
@@ -183,26 +399,58 @@ type ThriftHandler struct {
 // 4. Attempts to find and execute the appropriate handler for the request path
 // 5. Returns the response in Thrift format
 //
-// If no matching handler is found, it returns a NOT_FOUND error response.
+// If no matching handler is found, the not-found (fallback) handler answers when registered,
+// otherwise the same NOT_FOUND error response as HTTP is returned.
 func (th *ThriftHandler) Call(ctx context.Context, request *thriftapi.APIRequest) (r *thriftapi.APIResponse, err error) {
+	th.server.inFlight.Add(1)
+	defer th.server.inFlight.Add(-1)
+
+	// The call context is canceled at the handler timeout, so handlers can stop early
+	timeout := handlerTimeout(th.server.config)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Create request and responder objects, the same responder is used by the pre-request
+	// handler and the matched handler so headers set by either one are kept
+	var req = requestPackage.NewThriftAPIRequestWithContext(ctx, request)
+	var requestID = assignRequestID(req)
+	applyJSONLimits(req, th.server.config)
+	applyTrustedProxies(req, th.server.trustedProxies)
+	var responder = responderPackage.NewThriftAPIResponder(th.hostname, "ThriftHandler.Call")
+	applyResponseFormat(responder, th.server.config)
+	if th.server.config != nil {
+		responder.(*responderPackage.ThriftAPIResponder).SetMaxHeaderSize(th.server.config.MaxThriftHeaderSize)
+	}
+	applyPreResponse(responder, req, th.preResponse)
+	responder.SetHeader(requestPackage.RequestIDHeader, requestID)
+	var resp *thriftapi.APIResponse
+
+	// Compress the content of the response, whatever returned it, when the client accepts gzip
+	defer func() {
+		compressThriftContent(r, req, th.server.config)
+	}()
+
 	// Set up panic recovery to ensure we always return a proper response
 	defer func() {
 		if rec := recover(); rec != nil {
+			if th.panicHandler != nil {
+				if response := th.panicHandler(rec, req); response != nil {
+					responder.Respond(response)
+					r = responder.GetRawResponse().(*thriftapi.APIResponse)
+					return
+				}
+			} else {
+				log.Println("panic: ", rec, string(debug.Stack()))
+			}
+
 			r = &thriftapi.APIResponse{
 				Status:    thriftapi.Status_ERROR,
 				Message:   "There is an error, please try again later.",
 				ErrorCode: "INTERNAL_SERVICE_ERROR",
 			}
-
-			log.Println("panic: ", rec, string(debug.Stack()))
 		}
 	}()
 
-	// Create request and responder objects
-	var req = requestPackage.NewThriftAPIRequest(request)
-	var responder = responderPackage.NewThriftAPIResponder(th.hostname, "ThriftHandler.Call")
-	var resp *thriftapi.APIResponse
-
 	// Process pre-request handler if configured
 	if th.preHandler != nil {
 		// Set function name in responder for tracing/debugging
@@ -224,6 +472,9 @@ func (th *ThriftHandler) Call(ctx context.Context, request *thriftapi.APIRequest
 			resp = &thriftapi.APIResponse{
 				Status:  thriftapi.Status_ERROR,
 				Message: "PreRequest error: " + err.Error(),
+				Headers: map[string]string{
+					requestPackage.RequestIDHeader: requestID,
+				},
 			}
 		}
 
@@ -247,10 +498,10 @@ func (th *ThriftHandler) Call(ctx context.Context, request *thriftapi.APIRequest
 		if th.server.config == nil || !th.server.config.HideFuncName {
 			funcName = sdk.GetFunctionName(processFunc)
 		}
-		responder = responderPackage.NewThriftAPIResponder(th.hostname, funcName)
+		responder.SetFuncName(funcName)
 
 		// Execute the handler
-		err = processFunc(req, responder)
+		err = callWithTimeout(timeout, cancel, processFunc, req, responder, nil, &th.server.inFlight)
 
 		// Get and return the response
 		resp = nil
@@ -321,9 +572,11 @@ func (th *ThriftHandler) Call(ctx context.Context, request *thriftapi.APIRequest
 
 		// If we found a matching handler with pattern matching
 		if selectedHandler != nil {
-			// Apply URL parameters from the matched route
+			// Apply URL parameters from the matched route, keeping the ones set in PreRequest like with HTTP
 			for key, value := range varMap {
-				req.SetVar(key, value)
+				if req.GetVar(key) == "" {
+					req.SetVar(key, value)
+				}
 			}
 
 			// Set function name in responder for tracing/debugging
@@ -331,10 +584,10 @@ func (th *ThriftHandler) Call(ctx context.Context, request *thriftapi.APIRequest
 			if th.server.config == nil || !th.server.config.HideFuncName {
 				funcName = sdk.GetFunctionName(selectedHandler)
 			}
-			responder = responderPackage.NewThriftAPIResponder(th.hostname, funcName)
+			responder.SetFuncName(funcName)
 
 			// Execute the selected handler
-			err = selectedHandler(req, responder)
+			err = callWithTimeout(timeout, cancel, selectedHandler, req, responder, nil, &th.server.inFlight)
 
 			// Get and return the response
 			resp = nil
@@ -346,14 +599,26 @@ func (th *ThriftHandler) Call(ctx context.Context, request *thriftapi.APIRequest
 		}
 	}
 
-	// No matching handler found, return a NOT_FOUND error response
-	return &thriftapi.APIResponse{
-		Status:    thriftapi.Status_NOT_FOUND,
-		Message:   "API Method/Path " + method.Value + " " + path + " isn't found",
-		ErrorCode: "API_NOT_FOUND",
-		Headers: map[string]string{
-			"X-Hostname": th.hostname,
-			"X-Function": "ThriftHandler.Call",
-		},
-	}, nil
+	// No matching handler found, let the fallback handler answer when registered
+	if th.fallbackHandler != nil {
+		funcName := ""
+		if th.server.config == nil || !th.server.config.HideFuncName {
+			funcName = sdk.GetFunctionName(th.fallbackHandler)
+		}
+		responder.SetFuncName(funcName)
+
+		err = callWithTimeout(timeout, cancel, th.fallbackHandler, req, responder, nil, &th.server.inFlight)
+
+		resp = nil
+		tmp := responder.GetRawResponse()
+		if tmp != nil {
+			resp = tmp.(*thriftapi.APIResponse)
+		}
+		return resp, err
+	}
+
+	// Otherwise return the same NOT_FOUND error response as HTTP
+	responder.SetFuncName("ThriftHandler.Call")
+	responder.Respond(notFoundResponse(method.Value, path))
+	return responder.GetRawResponse().(*thriftapi.APIResponse), nil
 }
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/responder"
+)
+
+// typedRoute holds the request and response types of a route registered with SetHandlerTyped.
+type typedRoute struct {
+	// requestType is the type the request body is decoded into
+	requestType reflect.Type
+	// responseType is the type of the response data
+	responseType reflect.Type
+}
+
+// typedRoutes maps routes ("METHOD path") to the types of their typed handler.
+type typedRoutes map[string]typedRoute
+
+// typedRouter is implemented by the servers to remember the types of the routes registered with SetHandlerTyped.
+type typedRouter interface {
+	setRouteTypes(method string, path string, route typedRoute)
+}
+
+// set records the types of the route.
+func (routes typedRoutes) set(method string, path string, route typedRoute) {
+	routes[method+" "+path] = route
+}
+
+// clear forgets the types of the route, e.g. when it's replaced by an untyped handler.
+func (routes typedRoutes) clear(method string, path string) {
+	delete(routes, method+" "+path)
+}
+
+// apply fills the request and response types of the typed routes in the listing.
+func (routes typedRoutes) apply(infos []RouteInfo) []RouteInfo {
+	for i, info := range infos {
+		if route, ok := routes[info.Method+" "+info.Path]; ok {
+			infos[i].RequestType = route.requestType
+			infos[i].ResponseType = route.responseType
+		}
+	}
+	return infos
+}
+
+// SetHandlerTyped registers a handler working with Go types instead of the raw request and responder:
+//
+//	server.SetHandlerTyped(srv, common.APIMethod.POST, "/users", func(input CreateUser) (*User, error) {
+//		return users.Create(input)
+//	})
+//
+// The JSON request body, when there is one, is decoded into Req; a body that can't be decoded is answered
+// with an INVALID_BODY error. The returned Resp is sent as the data of an OK response in the usual envelope:
+// the items of a slice, or any other value as a single item. A returned error is sent as an error response, with the status mapped from its
+// error code. The Req and Resp types are reported by Routes, e.g. to generate an API schema.
+func SetHandlerTyped[Req any, Resp any](server Server, method *common.MethodValue, path string, fn func(req Req) (Resp, error)) error {
+	err := server.SetHandler(method, path, func(req request.APIRequest, res responder.APIResponder) error {
+		var input Req
+		if req.GetContentText() != "" {
+			if err := req.ParseBody(&input); err != nil {
+				var e *common.Error
+				if !errors.As(err, &e) {
+					err = common.NewError("INVALID_BODY", "request body can't be decoded: "+err.Error())
+				}
+				return res.Respond(common.FromError(err))
+			}
+		}
+
+		output, err := fn(input)
+		if err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		// keep the usual data array, even for a single object
+		response := common.NewObjectResponse(common.APIStatus.Ok, output, "", "", 0, nil)
+		response.SingleObject = false
+		return res.Respond(response)
+	})
+	if err != nil {
+		return err
+	}
+
+	if router, ok := server.(typedRouter); ok {
+		router.setRouteTypes(method.Value, path, typedRoute{
+			requestType:  reflect.TypeOf((*Req)(nil)).Elem(),
+			responseType: reflect.TypeOf((*Resp)(nil)).Elem(),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,1370 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/phnam/go-protocol-adapter/client"
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/responder"
+	"github.com/phnam/go-protocol-adapter/server"
+)
+
+// newTestHTTPServer creates an HTTP adapter server served by httptest, closed when the test finishes.
+func newTestHTTPServer(t *testing.T, setup func(srv server.Server)) *httptest.Server {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	setup(srv)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestHTTPClientAcceptHeader(t *testing.T) {
+	ts := newTestHTTPServer(t, func(srv server.Server) {
+		srv.SetHandler(common.APIMethod.GET, "/accept", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse([]any{req.GetHeader("Accept")}, "ok"))
+		})
+	})
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/accept"})
+	if len(resp.Data) != 1 || resp.Data[0] != "application/json" {
+		t.Error("Accept should default to JSON")
+	}
+
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{
+		Method:  "GET",
+		Path:    "/accept",
+		Headers: map[string]string{"Accept": "application/xml"},
+	})
+	if len(resp.Data) != 1 || resp.Data[0] != "application/xml" {
+		t.Error("Caller provided Accept header should reach the server")
+	}
+}
+
+func TestHTTPClientAbsoluteURL(t *testing.T) {
+	handler := func(name string) func(srv server.Server) {
+		return func(srv server.Server) {
+			srv.SetHandler(common.APIMethod.GET, "/name", func(req request.APIRequest, res responder.APIResponder) error {
+				return res.Respond(common.NewOkResponse([]any{name}, "ok"))
+			})
+		}
+	}
+	base := newTestHTTPServer(t, handler("base"))
+	other := newTestHTTPServer(t, handler("other"))
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:  base.URL,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: other.URL + "/name"})
+	if len(resp.Data) != 1 || resp.Data[0] != "other" {
+		t.Error("Absolute URL should bypass the base URL")
+	}
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/name"})
+	if len(resp.Data) != 1 || resp.Data[0] != "base" {
+		t.Error("Relative path should use the base URL")
+	}
+}
+
+func TestHTTPClientRetryExhausted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	var entries []*client.RequestLogEntry
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:     ts.URL,
+		Timeout:     time.Second,
+		MaxRetry:    2,
+		WaitToRetry: time.Millisecond,
+		Protocol:    common.Protocol.HTTP,
+		OnRetryExhausted: func(entry *client.RequestLogEntry) {
+			entries = append(entries, entry)
+		},
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Error {
+		t.Error("Request should fail, got " + resp.Status)
+	}
+	if len(entries) != 1 {
+		t.Fatal("Callback should fire exactly once, fired " + strconv.Itoa(len(entries)))
+	}
+	if entries[0].Status != "FAILED" || len(entries[0].Results) != 3 {
+		t.Error("Log entry should contain all 3 attempts, got " + strconv.Itoa(len(entries[0].Results)))
+	}
+}
+
+func TestHTTPClientMaxElapsedTime(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:        ts.URL,
+		Timeout:        time.Second,
+		MaxRetry:       10,
+		WaitToRetry:    100 * time.Millisecond,
+		MaxElapsedTime: 250 * time.Millisecond,
+		Protocol:       common.Protocol.HTTP,
+	})
+
+	start := time.Now()
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	elapsed := time.Since(start)
+	if resp.Status != common.APIStatus.Error {
+		t.Error("Request should fail, got " + resp.Status)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Error("Retries should stop within the budget, took " + elapsed.String())
+	}
+	if count := attempts.Load(); count < 2 || count >= 11 {
+		t.Error("Retries should be cut short by the budget, got " + strconv.Itoa(int(count)) + " attempts")
+	}
+	if !strings.Contains(resp.Message, "HTTP status 500") {
+		t.Error("Error should report the last failure, got " + resp.Message)
+	}
+
+	// an attempt in progress is cut at the end of the budget rather than at its timeout
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer slow.Close()
+	cli = client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:        slow.URL,
+		Timeout:        2 * time.Second,
+		MaxRetry:       1,
+		WaitToRetry:    time.Millisecond,
+		MaxElapsedTime: 200 * time.Millisecond,
+		Protocol:       common.Protocol.HTTP,
+	})
+	start = time.Now()
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if elapsed := time.Since(start); resp.Status != common.APIStatus.Error || elapsed > 500*time.Millisecond {
+		t.Error("Slow attempt should be cut by the budget, got " + resp.Status + " after " + elapsed.String())
+	}
+}
+
+func TestHTTPClientMultiParams(t *testing.T) {
+	var query map[string][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{
+		Method:      "GET",
+		Path:        "/items",
+		Params:      map[string]string{"limit": "10", "id": "1"},
+		MultiParams: map[string][]string{"id": {"2", "3"}},
+	})
+	if resp.Status != common.APIStatus.Ok {
+		t.Fatal("Request failed: " + resp.Message)
+	}
+	if strings.Join(query["id"], ",") != "1,2,3" {
+		t.Error("Repeated keys should reach the server, got id=" + strings.Join(query["id"], ","))
+	}
+	if strings.Join(query["limit"], ",") != "10" {
+		t.Error("Single params should be kept, got limit=" + strings.Join(query["limit"], ","))
+	}
+}
+
+func TestHTTPClientRetryError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable"))
+	}))
+	defer ts.Close()
+
+	cli := client.NewRESTClient[any](ts.URL, "test", time.Second, 2, time.Millisecond)
+	_, err := cli.MakeHTTPRequest(client.HTTPMethods.Get, nil, nil, nil, "/")
+
+	var retryErr *client.RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatal("Error should be a RetryError")
+	}
+	if retryErr.Error() != "fail to call endpoint API "+ts.URL+"/" {
+		t.Error("Wrong error message: " + retryErr.Error())
+	}
+	if len(retryErr.Attempts) != 3 {
+		t.Fatal("Error should carry 3 attempts, got " + strconv.Itoa(len(retryErr.Attempts)))
+	}
+	for _, attempt := range retryErr.Attempts {
+		if attempt.RespCode != http.StatusServiceUnavailable || attempt.RespBody == nil || *attempt.RespBody != "unavailable" {
+			t.Error("Attempt should carry the response status and body")
+		}
+	}
+}
+
+func TestHTTPClientCompressionBypass(t *testing.T) {
+	var encoding string
+	var received []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		gr, err := gzip.NewReader(r.Body)
+		if err == nil {
+			received, _ = io.ReadAll(gr)
+		}
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:             ts.URL,
+		Timeout:             time.Second,
+		Protocol:            common.Protocol.HTTP,
+		CompressRequestBody: true,
+	}).(*client.RestClient[any])
+
+	// plain body is compressed by the client
+	cli.MakeHTTPRequest(client.HTTPMethods.Post, nil, nil, map[string]string{"name": "plain"}, "/")
+	if encoding != "gzip" || strings.TrimSpace(string(received)) != `{"name":"plain"}` {
+		t.Error("Body should be compressed once, got: " + string(received))
+	}
+
+	// pre-gzipped body is sent as-is
+	var gzBody bytes.Buffer
+	gw := gzip.NewWriter(&gzBody)
+	gw.Write([]byte(`{"name":"pre-gzipped"}`))
+	gw.Close()
+	received = nil
+	cli.MakeHTTPRequest(client.HTTPMethods.Post, map[string]string{"Content-Encoding": "gzip"}, nil, client.RawBody(gzBody.Bytes()), "/")
+	if encoding != "gzip" || string(received) != `{"name":"pre-gzipped"}` {
+		t.Error("Pre-gzipped body shouldn't be compressed again, got: " + string(received))
+	}
+}
+
+func TestHTTPClientRequestSigning(t *testing.T) {
+	key := []byte("secret")
+	var valid bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		expected := client.ComputeHMACSignature(key, r.Method, r.URL.RequestURI(), r.Header.Get("X-Timestamp"), body)
+		valid = r.Header.Get("X-Timestamp") != "" && r.Header.Get("X-Signature") == expected
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:             ts.URL,
+		Timeout:             time.Second,
+		Protocol:            common.Protocol.HTTP,
+		CompressRequestBody: true,
+		RequestSigner:       client.NewHMACSigner(key),
+	})
+
+	// the signature covers the final (compressed) body and the query string
+	cli.MakeRequest(&request.OutboundAPIRequest{
+		Method:  "POST",
+		Path:    "/sign",
+		Params:  map[string]string{"q": "1"},
+		Content: `{"name":"signed"}`,
+	})
+	if !valid {
+		t.Error("POST signature doesn't match the server-side computation")
+	}
+
+	valid = false
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/sign"})
+	if !valid {
+		t.Error("GET signature doesn't match the server-side computation")
+	}
+}
+
+func TestHTTPClientSigningStreamedBody(t *testing.T) {
+	key := []byte("secret")
+	var calls int
+	var valid bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.Copy(io.Discard, r.Body)
+		expected := client.ComputeUnsignedHMACSignature(key, r.Method, r.URL.RequestURI(), r.Header.Get("X-Timestamp"))
+		valid = r.Header.Get(client.ContentHashHeader) == client.UnsignedPayload && r.Header.Get("X-Signature") == expected
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	signer := client.NewHMACSigner(key)
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       ts.URL,
+		Timeout:       time.Second,
+		Protocol:      common.Protocol.HTTP,
+		RequestSigner: signer,
+	}).(*client.RestClient[any])
+
+	// streams aren't buffered to be signed: rejected by default
+	stream := func() io.Reader { return io.LimitReader(patternReader{}, 1<<20) }
+	if _, err := cli.MakeHTTPRequest(client.HTTPMethods.Post, nil, nil, stream(), "/upload"); err == nil || !strings.Contains(err.Error(), "UNSIGNABLE_STREAM") {
+		t.Errorf("Signing a streamed body should fail, got %v", err)
+	}
+	if calls != 0 {
+		t.Error("Unsigned request shouldn't be sent")
+	}
+
+	// or signed without their payload when opted in
+	signer.UnsignedStreams = true
+	if _, err := cli.MakeHTTPRequest(client.HTTPMethods.Post, nil, nil, stream(), "/upload"); err != nil || !valid {
+		t.Errorf("Streamed body should be sent with an UNSIGNED-PAYLOAD signature, got %v", err)
+	}
+}
+
+func TestHTTPClientResponseCache(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/nostore" {
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(`{"status":"OK","data":["` + r.URL.Query().Get("q") + `"]}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:       ts.URL,
+		Timeout:       time.Second,
+		Protocol:      common.Protocol.HTTP,
+		ResponseCache: &client.ResponseCacheConfig{MaxSize: 10, DefaultTTL: time.Minute},
+	}).(*client.RestClient[string])
+
+	get := func(path string, q string) *common.APIResponse[string] {
+		return cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: path, Params: map[string]string{"q": q}})
+	}
+
+	get("/cached", "a")
+	resp := get("/cached", "a")
+	if calls != 1 || len(resp.Data) != 1 || resp.Data[0] != "a" {
+		t.Error("Second identical GET should be served from the cache, calls: " + strconv.Itoa(calls))
+	}
+
+	// different params and no-store responses go to the network
+	get("/cached", "b")
+	get("/nostore", "a")
+	get("/nostore", "a")
+	if calls != 4 {
+		t.Error("Expected 4 network calls, got " + strconv.Itoa(calls))
+	}
+
+	// unsafe methods are never cached
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/cached", Content: `{}`})
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/cached", Content: `{}`})
+	if calls != 6 {
+		t.Error("POST requests shouldn't be cached")
+	}
+
+	// requests with credentials neither use nor fill the cache, their response belongs to the caller
+	for _, headers := range []map[string]string{{"Authorization": "Bearer alice"}, {"authorization": "Bearer bob"}, {"Cookie": "session=1"}} {
+		cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/private", Headers: headers})
+	}
+	if calls != 9 {
+		t.Error("Requests with credentials shouldn't be cached, calls: " + strconv.Itoa(calls))
+	}
+
+	hits, misses := cli.CacheStats()
+	if hits != 1 || misses != 4 {
+		t.Error("Wrong cache stats: " + strconv.FormatInt(hits, 10) + " hits, " + strconv.FormatInt(misses, 10) + " misses")
+	}
+}
+
+func TestClientAddressesLoadBalancing(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		addresses := []string{}
+		for _, name := range []string{"first", "second"} {
+			srv := server.NewServer(server.ServerConfig{
+				Protocol: protocol,
+			})
+			srv.SetHandler(common.APIMethod.GET, "/name", func(req request.APIRequest, res responder.APIResponder) error {
+				return res.Respond(common.NewOkResponse([]any{name}, "ok"))
+			})
+			addresses = append(addresses, startServer(t, srv))
+		}
+
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Addresses:     addresses,
+			Timeout:       time.Second,
+			MaxConnection: 2,
+			Protocol:      protocol,
+		})
+		hits := map[string]int{}
+		for i := 0; i < 4; i++ {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/name"})
+			if len(resp.Data) == 1 {
+				hits[resp.Data[0]]++
+			}
+		}
+		if hits["first"] != 2 || hits["second"] != 2 {
+			t.Error(protocol + " traffic wasn't spread across both backends: " + strconv.Itoa(hits["first"]) + "/" + strconv.Itoa(hits["second"]))
+		}
+
+		// a dead backend is skipped once it failed
+		dead := "localhost:" + strconv.Itoa(freePort(t))
+		cli = client.NewAPIClient[string](&client.APIClientConfiguration{
+			Addresses:     []string{dead, addresses[0]},
+			Timeout:       time.Second,
+			MaxRetry:      1,
+			MaxConnection: 2,
+			Protocol:      protocol,
+		})
+		for i := 0; i < 4; i++ {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/name"})
+			if resp.Status != common.APIStatus.Ok {
+				t.Error(protocol + " call " + strconv.Itoa(i) + " wasn't routed to the live backend: " + resp.Message)
+			}
+		}
+	}
+}
+
+func TestHTTPClientMaxResponseBodySize(t *testing.T) {
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		chunk := bytes.Repeat([]byte("x"), 1024)
+		for i := 0; i < 64; i++ {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:             ts.URL,
+		Timeout:             time.Second,
+		MaxRetry:            2,
+		WaitToRetry:         time.Millisecond,
+		MaxResponseBodySize: 4096,
+		Protocol:            common.Protocol.HTTP,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Error || resp.ErrorCode != "RESPONSE_TOO_LARGE" {
+		t.Error("Oversized response should fail with RESPONSE_TOO_LARGE, got " + resp.Status + "/" + resp.ErrorCode)
+	}
+	if attempts.Load() != 1 {
+		t.Error("Oversized response shouldn't be retried, got " + strconv.Itoa(int(attempts.Load())) + " attempts")
+	}
+}
+
+func TestHTTPClientErrorStatusMessage(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("access denied for this key\n"))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Forbidden {
+		t.Error("Status should be " + common.APIStatus.Forbidden + ", got " + resp.Status)
+	}
+	if resp.Message != "403 Forbidden: access denied for this key" {
+		t.Error("Message should describe the HTTP error, got " + resp.Message)
+	}
+}
+
+func TestClientRetryOnErrorCodes(t *testing.T) {
+	// HTTP upstream answering 429 OVERLOAD, which isn't retried on its status code alone
+	var httpCalls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if httpCalls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":"ERROR","error_code":"OVERLOAD","message":"busy"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"OK","message":"done"}`))
+	}))
+	defer ts.Close()
+
+	// Thrift server answering OVERLOAD first
+	var thriftCalls atomic.Int32
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		if thriftCalls.Add(1) == 1 {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.Error, "OVERLOAD", "busy"))
+		}
+		return res.Respond(common.NewOkResponse(nil, "done"))
+	})
+
+	for protocol, address := range map[string]string{common.Protocol.HTTP: ts.URL, common.Protocol.THRIFT: startServer(t, srv)} {
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:           address,
+			Timeout:           time.Second,
+			MaxRetry:          2,
+			WaitToRetry:       time.Millisecond,
+			MaxConnection:     1,
+			RetryOnErrorCodes: []string{"OVERLOAD"},
+			Protocol:          protocol,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		if resp.Status != common.APIStatus.Ok || resp.Message != "done" {
+			t.Error(protocol + " OVERLOAD response should be retried, got " + resp.Status + " " + resp.Message)
+		}
+	}
+	if httpCalls.Load() != 2 || thriftCalls.Load() != 2 {
+		t.Error("Each server should be called twice")
+	}
+
+	// other error codes aren't retried
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:           ts.URL,
+		Timeout:           time.Second,
+		MaxRetry:          2,
+		WaitToRetry:       time.Millisecond,
+		RetryOnErrorCodes: []string{"SERVER_BUSY"},
+		Protocol:          common.Protocol.HTTP,
+	})
+	httpCalls.Store(0)
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"}); resp.ErrorCode != "OVERLOAD" {
+		t.Error("Non retryable error code should be returned, got " + resp.Status + " " + resp.ErrorCode)
+	}
+
+	// error codes share the attempts of the other retries, and the last response is returned once exhausted
+	var busyCalls atomic.Int32
+	busy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		busyCalls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"status":"ERROR","error_code":"OVERLOAD","message":"busy"}`))
+	}))
+	defer busy.Close()
+	var exhausted atomic.Int32
+	cli = client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:           busy.URL,
+		Timeout:           time.Second,
+		MaxRetry:          2,
+		WaitToRetry:       time.Millisecond,
+		RetryOnErrorCodes: []string{"OVERLOAD"},
+		OnRetryExhausted:  func(entry *client.RequestLogEntry) { exhausted.Add(1) },
+		Protocol:          common.Protocol.HTTP,
+	})
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"}); resp.ErrorCode != "OVERLOAD" {
+		t.Error("Last OVERLOAD response should be returned, got " + resp.Status + " " + resp.ErrorCode)
+	}
+	if busyCalls.Load() != 3 || exhausted.Load() != 1 {
+		t.Errorf("Expected 3 attempts and OnRetryExhausted called once, got %d attempts and %d calls", busyCalls.Load(), exhausted.Load())
+	}
+}
+
+func TestHTTPClientMaxConnAge(t *testing.T) {
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:    ts.URL,
+		Timeout:    time.Second,
+		MaxConnAge: 100 * time.Millisecond,
+		Protocol:   common.Protocol.HTTP,
+	})
+	call := func() {
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		if resp.Status != common.APIStatus.Ok {
+			t.Fatal("Request should succeed, got " + resp.Status + ": " + resp.Message)
+		}
+	}
+
+	call()
+	call()
+	if conns.Load() != 1 {
+		t.Error("Young connection should be reused, got " + strconv.Itoa(int(conns.Load())) + " connections")
+	}
+
+	// the first request after the age retires the connection, the next one opens a new connection
+	time.Sleep(150 * time.Millisecond)
+	call()
+	call()
+	if conns.Load() != 2 {
+		t.Error("Connection should be recreated after the max age, got " + strconv.Itoa(int(conns.Load())) + " connections")
+	}
+}
+
+func TestHTTPClientFormResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		w.Write([]byte("result=approved&amount=10.50&tag=a&tag=b"))
+	}))
+	defer ts.Close()
+
+	type payment struct {
+		Result string   `json:"result"`
+		Amount string   `json:"amount"`
+		Tag    []string `json:"tag"`
+	}
+	cli := client.NewAPIClient[payment](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/pay"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Fatal("Form-encoded response should be OK, got " + resp.Status + ": " + resp.Message)
+	}
+	if len(resp.Data) != 1 {
+		t.Fatal("Form-encoded response should be decoded as one data item, got " + strconv.Itoa(len(resp.Data)))
+	}
+	item := resp.Data[0]
+	if item.Result != "approved" || item.Amount != "10.50" || strings.Join(item.Tag, ",") != "a,b" {
+		t.Error("Unexpected form data: " + item.Result + " " + item.Amount + " " + strings.Join(item.Tag, ","))
+	}
+}
+
+func TestHTTPClientLogMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	var logged *client.RequestLogEntry
+	cli := client.NewRESTClient[any](ts.URL, "test", time.Second, 0, time.Millisecond)
+	cli.SetOnRetryExhausted(func(entry *client.RequestLogEntry) {
+		logged = entry
+	})
+	keys := []string{"order-1"}
+	cli.MakeHTTPRequestWithMetadata(client.HTTPMethods.Get, nil, nil, nil, "/", &keys, map[string]string{"tenant": "acme", "userId": "42"})
+
+	if logged == nil {
+		t.Fatal("Failed request should be logged")
+	}
+	if logged.Metadata["tenant"] != "acme" || logged.Metadata["userId"] != "42" {
+		t.Error("Log entry should carry the request metadata")
+	}
+	if logged.Keys == nil || (*logged.Keys)[0] != "order-1" {
+		t.Error("Log entry should keep the request keys")
+	}
+	encoded, _ := json.Marshal(logged)
+	if !strings.Contains(string(encoded), `"metadata":{"tenant":"acme","userId":"42"}`) {
+		t.Error("Encoded log entry should contain the metadata: " + string(encoded))
+	}
+}
+
+func TestClientMakeRequestInto(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+	type order struct {
+		ID    int     `json:"id"`
+		Total float64 `json:"total"`
+	}
+
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/me", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse([]any{user{Name: "alice"}}, "me"))
+		})
+		srv.SetHandler(common.APIMethod.GET, "/orders", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse([]any{order{ID: 1, Total: 9.5}, order{ID: 2, Total: 20}}, "orders"))
+		})
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		var me user
+		resp := cli.MakeRequestInto(&request.OutboundAPIRequest{Method: "GET", Path: "/me"}, &me)
+		if resp.Status != common.APIStatus.Ok || me.Name != "alice" {
+			t.Error(protocol + " should decode the user, got " + resp.Status + ": " + resp.Message)
+		}
+
+		var orders []order
+		resp = cli.MakeRequestInto(&request.OutboundAPIRequest{Method: "GET", Path: "/orders"}, &orders)
+		if resp.Status != common.APIStatus.Ok || len(orders) != 2 || orders[1].ID != 2 || orders[1].Total != 20 {
+			t.Error(protocol + " should decode the orders, got " + resp.Status + ": " + resp.Message)
+		}
+	}
+}
+
+func TestClientConfigurationClone(t *testing.T) {
+	keep := true
+	base := &client.APIClientConfiguration{
+		Addresses:            []string{"a:1", "b:2"},
+		Protocol:             common.Protocol.HTTP,
+		Timeout:              time.Second,
+		RetryOnErrorCodes:    []string{"OVERLOAD"},
+		KeepDataStringFormat: &keep,
+		ResponseCache:        &client.ResponseCacheConfig{MaxSize: 10},
+	}
+
+	clone := base.Clone()
+	clone.Addresses[0] = "c:3"
+	clone.RetryOnErrorCodes = append(clone.RetryOnErrorCodes[:0], "BUSY")
+	*clone.KeepDataStringFormat = false
+	clone.ResponseCache.MaxSize = 20
+	clone.Timeout = 5 * time.Second
+
+	if base.Addresses[0] != "a:1" || base.RetryOnErrorCodes[0] != "OVERLOAD" || !*base.KeepDataStringFormat ||
+		base.ResponseCache.MaxSize != 10 || base.Timeout != time.Second {
+		t.Error("Mutating a clone shouldn't affect the original configuration")
+	}
+
+	slow := base.WithTimeout(10*time.Second).WithRetry(3, time.Millisecond)
+	if slow.Timeout != 10*time.Second || slow.MaxRetry != 3 || slow.WaitToRetry != time.Millisecond || slow.Addresses[1] != "b:2" {
+		t.Error("WithTimeout/WithRetry should return tweaked copies")
+	}
+	if base.Timeout != time.Second || base.MaxRetry != 0 {
+		t.Error("WithTimeout/WithRetry shouldn't modify the original configuration")
+	}
+}
+
+func TestHTTPClientStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"OK","data":[{"id":1}]}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewRESTClient[any](ts.URL, "test", time.Second, 1, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		cli.MakeHTTPRequest(client.HTTPMethods.Get, nil, nil, nil, "/ok")
+	}
+	for i := 0; i < 2; i++ {
+		cli.MakeHTTPRequest(client.HTTPMethods.Get, nil, nil, nil, "/fail")
+	}
+
+	stats := cli.Stats()
+	if stats.Requests != 5 || stats.Successes != 3 || stats.Failures != 2 {
+		t.Errorf("Expected 5 requests (3 successes, 2 failures), got %+v", stats)
+	}
+	if stats.Retries != 2 {
+		t.Errorf("Expected 1 retry per failed request, got %d", stats.Retries)
+	}
+	if stats.AverageLatency <= 0 {
+		t.Error("Average latency should be positive")
+	}
+
+	cli.ResetStats()
+	if stats := cli.Stats(); stats != (client.ClientStats{}) {
+		t.Errorf("Stats should be cleared after reset, got %+v", stats)
+	}
+}
+
+func TestClientIfMatchPreconditionFailed(t *testing.T) {
+	updateItem := func(req request.APIRequest, res responder.APIResponder) error {
+		if req.GetHeader("If-Match") != `"v2"` {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.PreconditionFailed, "PRECONDITION_FAILED", "Item was modified"))
+		}
+		return res.Respond(common.NewOkResponse(nil, "Updated"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.PUT, "/items/1", updateItem)
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		stale, _ := request.NewRequest("PUT", "/items/1").WithIfMatch("v1").Build()
+		resp := cli.MakeRequest(stale)
+		if resp.Status != common.APIStatus.PreconditionFailed || resp.ErrorCode != "PRECONDITION_FAILED" {
+			t.Error(protocol + " stale update should fail with PRECONDITION_FAILED, got " + resp.Status)
+		}
+		current, _ := request.NewRequest("PUT", "/items/1").WithIfMatch(`"v2"`).Build()
+		if resp := cli.MakeRequest(current); resp.Status != common.APIStatus.Ok {
+			t.Error(protocol + " update with the current ETag should succeed, got " + resp.Status)
+		}
+	}
+
+	// upstreams answering a bare 412
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	defer ts.Close()
+	cli := client.NewRESTClient[any](ts.URL, "test", time.Second, 0, time.Millisecond)
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "PUT", Path: "/items/1", Headers: map[string]string{"If-Match": `"v1"`}})
+	if resp.Status != common.APIStatus.PreconditionFailed || resp.ErrorCode != "PRECONDITION_FAILED" {
+		t.Error("HTTP 412 should map to PRECONDITION_FAILED, got " + resp.Status + "/" + resp.ErrorCode)
+	}
+}
+
+// patternReader produces an endless stream of bytes without allocating.
+type patternReader struct{}
+
+func (patternReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(i)
+	}
+	return len(p), nil
+}
+
+func TestHTTPClientStreamBody(t *testing.T) {
+	const size = 64 << 20
+	var received atomic.Int64
+	var chunked atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chunked.Store(r.ContentLength == -1)
+		n, _ := io.Copy(io.Discard, r.Body)
+		received.Store(n)
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewRESTClient[any](ts.URL, "test", 10*time.Second, 2, time.Millisecond)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	result, err := cli.MakeHTTPRequest(client.HTTPMethods.Put, nil, nil, io.LimitReader(patternReader{}, size), "/upload")
+	runtime.ReadMemStats(&after)
+
+	if err != nil || result.Code != http.StatusOK {
+		t.Fatal("Streamed upload should succeed", err)
+	}
+	if received.Load() != size || !chunked.Load() {
+		t.Errorf("Expected %d bytes sent chunked, got %d bytes (chunked: %v)", size, received.Load(), chunked.Load())
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+		t.Errorf("Streaming %d bytes shouldn't buffer the body, allocated %d bytes", size, allocated)
+	}
+}
+
+func TestClientDo(t *testing.T) {
+	purge := func(req request.APIRequest, res responder.APIResponder) error {
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		req.ParseBody(&body)
+		return res.Respond(common.NewOkResponse([]any{req.GetMethod().Value + " " + req.GetParam("key") + " " + body.Reason + " " + req.GetHeader("X-Tenant")}, "purged"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.MethodFromString("PURGE"), "/cache", purge)
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		resp := cli.Do("PURGE", "/cache", client.RequestOptions{
+			Params:  map[string]string{"key": "users"},
+			Headers: map[string]string{"X-Tenant": "acme"},
+			Body:    map[string]string{"reason": "stale"},
+		})
+		if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != "PURGE users stale acme" {
+			t.Errorf("%s custom method should be dispatched with its options, got %+v", protocol, resp)
+		}
+
+		if resp := cli.Do("BAD METHOD", "/cache", client.RequestOptions{}); resp.Status != common.APIStatus.Invalid || resp.ErrorCode != "INVALID_METHOD" {
+			t.Error(protocol + " invalid method should be rejected, got " + resp.Status)
+		}
+	}
+}
+
+func TestClientQueryBody(t *testing.T) {
+	search := func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse([]any{req.GetContentText()}, "echo"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.QUERY, "/search", search)
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		for _, body := range []string{`{"q":"shoes","limit":10}`, `SELECT * FROM items`} {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "QUERY", Path: "/search", Content: body})
+			if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || strings.TrimSpace(resp.Data[0]) != body {
+				t.Errorf("%s QUERY body should be echoed, got %+v", protocol, resp)
+			}
+		}
+	}
+}
+
+func TestHTTPClientLogRedaction(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":"ERROR","data":[{"token":"t0k3n","id":7}]}`))
+	}))
+	defer ts.Close()
+
+	var logged *client.RequestLogEntry
+	cli := client.NewHTTPClient[any](&client.APIClientConfiguration{
+		Address:      ts.URL,
+		Timeout:      time.Second,
+		WaitToRetry:  time.Millisecond,
+		RedactFields: []string{"password", "token"},
+		OnRetryExhausted: func(entry *client.RequestLogEntry) {
+			logged = entry
+		},
+	}).(*client.RestClient[any])
+	headers := map[string]string{"Authorization": "Bearer secret", "X-Tenant": "acme"}
+	body := map[string]any{"user": "alice", "credentials": map[string]any{"Password": "hunter2"}}
+	cli.MakeHTTPRequest(client.HTTPMethods.Post, headers, nil, body, "/login")
+
+	if logged == nil {
+		t.Fatal("Failed request should be logged")
+	}
+	encoded, _ := json.Marshal(logged)
+	log := string(encoded)
+	for _, secret := range []string{"Bearer secret", "hunter2", "t0k3n"} {
+		if strings.Contains(log, secret) {
+			t.Error("Log entry shouldn't contain " + secret + ": " + log)
+		}
+	}
+	for _, kept := range []string{`"Authorization":"***"`, `"Password":"***"`, `"user":"alice"`, `"X-Tenant":"acme"`, `\"id\":7`} {
+		if !strings.Contains(log, kept) {
+			t.Error("Log entry should contain " + kept + ": " + log)
+		}
+	}
+	if headers["Authorization"] != "Bearer secret" {
+		t.Error("Redaction shouldn't modify the request headers")
+	}
+}
+
+func TestHTTPClientMaxConcurrentRequests(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		if r.URL.Path == "/hold" {
+			<-release
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+		inFlight.Add(-1)
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:               ts.URL,
+		Protocol:              common.Protocol.HTTP,
+		Timeout:               time.Second,
+		MaxConcurrentRequests: 3,
+	})
+	done := make(chan *common.APIResponse[any], 20)
+	for i := 0; i < 20; i++ {
+		go func() {
+			done <- cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/work"})
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if resp := <-done; resp.Status != common.APIStatus.Ok {
+			t.Error("Requests over the limit should wait for a free slot, got " + resp.Status + ": " + resp.Message)
+		}
+	}
+	if max := maxInFlight.Load(); max != 3 {
+		t.Errorf("Expected at most 3 concurrent requests reaching the limit, got %d", max)
+	}
+
+	// failing fast rejects requests over the limit instead of waiting
+	busy := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:               ts.URL,
+		Protocol:              common.Protocol.HTTP,
+		Timeout:               time.Second,
+		MaxConcurrentRequests: 1,
+		FailFastWhenBusy:      true,
+	})
+	held := make(chan *common.APIResponse[any], 1)
+	go func() {
+		held <- busy.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/hold"})
+	}()
+	for inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if resp := busy.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/work"}); resp.ErrorCode != "CLIENT_BUSY" {
+		t.Errorf("Expected a CLIENT_BUSY error over the limit, got %+v", resp)
+	}
+	close(release)
+	if resp := <-held; resp.Status != common.APIStatus.Ok {
+		t.Error("Held request should succeed: " + resp.Message)
+	}
+	if resp := busy.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/work"}); resp.Status != common.APIStatus.Ok {
+		t.Error("Slot should be released after the request: " + resp.Message)
+	}
+}
+
+func TestClientDecodeObjectData(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/object" {
+			w.Write([]byte(`{"status":"OK","data":{"id":1,"name":"a"},"message":"one"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"OK","data":[{"id":1,"name":"a"},{"id":2,"name":"b"}],"message":"many"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[item](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Protocol: common.Protocol.HTTP,
+		Timeout:  time.Second,
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/object"})
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0].Name != "a" || !resp.SingleObject {
+		t.Errorf("Object data should be decoded as a single item, got %+v", resp)
+	}
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/array"})
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 2 || resp.Data[1].Name != "b" || resp.SingleObject {
+		t.Errorf("Array data should be decoded as items, got %+v", resp)
+	}
+
+	// object responses of the servers round-trip over both protocols
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/item", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, item{ID: 3, Name: "c"}, "item", "", 0, nil))
+		})
+		cli := client.NewAPIClient[item](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Protocol:      protocol,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/item"})
+		if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0].ID != 3 {
+			t.Errorf("%s object response should be decoded as a single item, got %+v", protocol, resp)
+		}
+	}
+}
+
+func TestClientForEachPage(t *testing.T) {
+	pages := map[string]struct {
+		items []any
+		next  string
+	}{
+		"":   {[]any{1, 2}, "p2"},
+		"p2": {[]any{3, 4}, "p3"},
+		"p3": {[]any{5}, ""},
+	}
+	listItems := func(req request.APIRequest, res responder.APIResponder) error {
+		page, ok := pages[req.GetParam(common.CursorParam)]
+		if !ok {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.Invalid, "INVALID_CURSOR", "unknown cursor"))
+		}
+		if page.next != "" {
+			res.SetNextCursor(page.next)
+		}
+		return res.Respond(common.NewOkResponse(page.items, "items"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/items", listItems)
+		cli := client.NewAPIClient[int](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		req := &request.OutboundAPIRequest{Method: "GET", Path: "/items", Params: map[string]string{"sort": "id"}}
+		var items []int
+		pageCount := 0
+		err := client.ForEachPage(cli, req, func(page *common.APIResponse[int]) error {
+			pageCount++
+			items = append(items, page.Data...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s pagination failed: %v", protocol, err)
+		}
+		if pageCount != 3 || len(items) != 5 || items[0] != 1 || items[4] != 5 {
+			t.Errorf("%s should iterate over 3 pages of 5 items, got %d pages: %v", protocol, pageCount, items)
+		}
+		if _, ok := req.Params[common.CursorParam]; ok {
+			t.Error(protocol + " request of the first page shouldn't be modified")
+		}
+
+		// a failed page stops the iteration with its error
+		err = client.ForEachPage(cli, &request.OutboundAPIRequest{Method: "GET", Path: "/items", Params: map[string]string{common.CursorParam: "bad"}},
+			func(page *common.APIResponse[int]) error { return nil })
+		var e common.Error
+		if !errors.As(err, &e) || e.ErrorCode != "INVALID_CURSOR" {
+			t.Errorf("%s expected the INVALID_CURSOR error of the failed page, got %v", protocol, err)
+		}
+	}
+}
+
+// unroutableAddress returns the address of a listener that never completes TCP handshakes:
+// it has no accept queue, filled by a first connection, so the following SYNs are dropped.
+func unroutableAddress(t *testing.T) string {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}})
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, _ := syscall.Getsockname(fd)
+	address := "127.0.0.1:" + strconv.Itoa(sa.(*syscall.SockaddrInet4).Port)
+
+	con, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { con.Close() })
+	return address
+}
+
+func TestClientConnectTimeout(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:        unroutableAddress(t),
+			Protocol:       protocol,
+			Timeout:        5 * time.Second,
+			ConnectTimeout: 200 * time.Millisecond,
+			MaxConnection:  1,
+		})
+		start := time.Now()
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"})
+		elapsed := time.Since(start)
+		if resp.Status == common.APIStatus.Ok {
+			t.Fatal(protocol + " request to an unroutable host shouldn't succeed")
+		}
+		// the Thrift client makes a free retry on a new connection
+		if elapsed < 200*time.Millisecond || elapsed > time.Second {
+			t.Errorf("%s dial should fail after the connect timeout rather than the request timeout, took %v", protocol, elapsed)
+		}
+	}
+}
+
+func TestHTTPClientRecentLogs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       ts.URL,
+		Protocol:      common.Protocol.HTTP,
+		Timeout:       time.Second,
+		LogExpiration: 100 * time.Millisecond,
+	}).(*client.RestClient[any])
+
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/first"})
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/second"})
+	if logs := cli.RecentLogs(); len(logs) != 2 {
+		t.Fatalf("Expected 2 recent logs, got %d", len(logs))
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/third", Headers: map[string]string{"Authorization": "Bearer secret"}})
+	logs := cli.RecentLogs()
+	if len(logs) != 1 || !strings.HasSuffix(logs[0].ReqURL, "/third") {
+		t.Fatalf("Entries older than the log expiration should be evicted, got %d entries", len(logs))
+	}
+	if logs[0].Status != "SUCCESS" || (*logs[0].ReqHeader)["Authorization"] != client.RedactedValue {
+		t.Errorf("Recent log should be the redacted entry of the request, got %+v", logs[0])
+	}
+
+	cli.SetLogExpiration(0)
+	if logs := cli.RecentLogs(); logs != nil {
+		t.Errorf("No logs should be kept without log expiration, got %d", len(logs))
+	}
+}
+
+func TestHTTPClientUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","message":"` + r.URL.Path + `"}`))
+	}))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  "unix://" + socket,
+		Protocol: common.Protocol.HTTP,
+		Timeout:  time.Second,
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"})
+	if resp.Status != common.APIStatus.Ok || resp.Message != "/items" {
+		t.Fatalf("Expected the request to reach the Unix socket server, got %s %s", resp.Status, resp.Message)
+	}
+
+	// a custom dialer receives the connections of TCP addresses
+	var dialed atomic.Int32
+	cli = client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  "http://api.internal",
+		Protocol: common.Protocol.HTTP,
+		Timeout:  time.Second,
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			dialed.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	})
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/dialed"})
+	if resp.Status != common.APIStatus.Ok || dialed.Load() != 1 {
+		t.Fatalf("Expected the request to go through the custom dialer, got %s (dialed %d)", resp.Status, dialed.Load())
+	}
+}
+
+func TestHTTPClientAcceptEncoding(t *testing.T) {
+	var acceptEncoding []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Values("Accept-Encoding")
+		body := []byte(`{"status":"OK","message":"` + r.URL.Path + `"}`)
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "deflate") {
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			zw.Write(body)
+			zw.Close()
+			w.Header().Set("Content-Encoding", "deflate")
+			body = buf.Bytes()
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	// every supported encoding is advertised, and deflate responses are decoded
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Protocol: common.Protocol.HTTP,
+		Timeout:  time.Second,
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/deflated"})
+	if len(acceptEncoding) != 1 || acceptEncoding[0] != "gzip, deflate" {
+		t.Errorf("Accept-Encoding should list the supported decoders, got %v", acceptEncoding)
+	}
+	if resp.Status != common.APIStatus.Ok || resp.Message != "/deflated" {
+		t.Errorf("Deflate response should be decoded, got %s %s", resp.Status, resp.Message)
+	}
+
+	// disabling compression omits the header
+	cli = client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:                    ts.URL,
+		Protocol:                   common.Protocol.HTTP,
+		Timeout:                    time.Second,
+		DisableResponseCompression: true,
+	})
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/plain"})
+	if len(acceptEncoding) != 0 {
+		t.Errorf("Accept-Encoding shouldn't be sent when compression is disabled, got %v", acceptEncoding)
+	}
+	if resp.Status != common.APIStatus.Ok || resp.Message != "/plain" {
+		t.Errorf("Uncompressed response should be read, got %s %s", resp.Status, resp.Message)
+	}
+}
+
+func TestHTTPClientSlowRequestLogs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	for _, errorLogOnly := range []bool{false, true} {
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:              ts.URL,
+			Protocol:             common.Protocol.HTTP,
+			Timeout:              time.Second,
+			LogExpiration:        time.Minute,
+			ErrorLogOnly:         errorLogOnly,
+			SlowRequestThreshold: 50 * time.Millisecond,
+		}).(*client.RestClient[any])
+
+		cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/fast"})
+		cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"})
+		cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/broken"})
+
+		logs := cli.RecentLogs()
+		paths := []string{}
+		for _, entry := range logs {
+			paths = append(paths, entry.ReqURL[len(ts.URL):])
+		}
+		if len(paths) != 2 || paths[0] != "/slow" || paths[1] != "/broken" {
+			t.Errorf("Only slow and failed requests should be logged (errorLogOnly %v), got %v", errorLogOnly, paths)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/phnam/go-protocol-adapter/common"
+)
+
+func TestMethodFromString(t *testing.T) {
+	known := map[string]*common.MethodValue{
+		"GET":     common.APIMethod.GET,
+		"QUERY":   common.APIMethod.QUERY,
+		"POST":    common.APIMethod.POST,
+		"PUT":     common.APIMethod.PUT,
+		"PATCH":   common.APIMethod.PATCH,
+		"DELETE":  common.APIMethod.DELETE,
+		"OPTIONS": common.APIMethod.OPTIONS,
+		"TRACE":   common.APIMethod.TRACE,
+		"CONNECT": common.APIMethod.CONNECT,
+	}
+	for s, method := range known {
+		if common.MethodFromString(s) != method {
+			t.Error("MethodFromString should return the enum pointer for " + s)
+		}
+	}
+
+	unknown := common.MethodFromString("PURGE")
+	if unknown.Value != "PURGE" {
+		t.Error("Unknown method should keep its value, got " + unknown.Value)
+	}
+	if !unknown.Equals(&common.MethodValue{Value: "PURGE"}) || unknown.Equals(common.APIMethod.GET) {
+		t.Error("Equals should compare methods by value")
+	}
+	if !common.APIMethod.GET.Equals(&common.MethodValue{Value: "GET"}) {
+		t.Error("Enum value should equal a fresh value of the same method")
+	}
+}
+
+func TestAPIResponseAsError(t *testing.T) {
+	resp := &common.APIResponse[string]{
+		Status:    common.APIStatus.Invalid,
+		Message:   "Email is invalid",
+		ErrorCode: "INVALID_EMAIL",
+	}
+
+	var e common.Error
+	if !errors.As(resp.AsError(), &e) || e.ErrorCode != "INVALID_EMAIL" || e.Message != "Email is invalid" {
+		t.Error("Error response should convert to a matching common.Error")
+	}
+
+	// round-trip through FromError keeps the status, code and message
+	back := common.FromError(resp.AsError())
+	if back.Status != resp.Status || back.ErrorCode != resp.ErrorCode || back.Message != resp.Message {
+		t.Error("FromError(AsError()) should give back the original response, got " + back.Status + " " + back.ErrorCode)
+	}
+
+	// the status is used when there's no error code
+	noCode := &common.APIResponse[string]{Status: common.APIStatus.NotFound, Message: "missing"}
+	if !errors.As(noCode.AsError(), &e) || e.ErrorCode != common.APIStatus.NotFound {
+		t.Error("Status should be used as error code when none is set")
+	}
+
+	if (&common.APIResponse[string]{Status: common.APIStatus.Ok}).AsError() != nil {
+		t.Error("Ok response shouldn't convert to an error")
+	}
+}
+
+func TestStatusErrorHelpers(t *testing.T) {
+	cases := []struct {
+		err    *common.Error
+		status string
+	}{
+		{common.NotFoundError("missing"), common.APIStatus.NotFound},
+		{common.InvalidError("missing"), common.APIStatus.Invalid},
+		{common.ForbiddenError("missing"), common.APIStatus.Forbidden},
+		{common.UnauthorizedError("missing"), common.APIStatus.Unauthorized},
+		{common.ExistedError("missing"), common.APIStatus.Existed},
+		{common.PreconditionFailedError("missing"), common.APIStatus.PreconditionFailed},
+		{common.TimeoutError("missing"), common.APIStatus.Timeout},
+		{common.InternalError("missing"), common.APIStatus.Error},
+	}
+	for _, c := range cases {
+		resp := common.FromError(c.err)
+		if resp.Status != c.status || resp.ErrorCode != c.err.ErrorCode || resp.Message != "missing" {
+			t.Errorf("%s error should map to the %s status, got %s %s", c.err.ErrorCode, c.status, resp.Status, resp.Message)
+		}
+	}
+
+	// messages containing the "//" separator, e.g. URLs, keep the status of the helper
+	resp := common.FromError(fmt.Errorf("fetching: %w", common.NotFoundError("no item at http://example.com/items/1")))
+	if resp.Status != common.APIStatus.NotFound || resp.ErrorCode != common.APIStatus.NotFound ||
+		resp.Message != "no item at http://example.com/items/1" {
+		t.Errorf("Wrapped error with a URL should map to the NOT_FOUND status, got %s %s %s", resp.Status, resp.ErrorCode, resp.Message)
+	}
+}
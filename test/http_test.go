@@ -1,10 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/labstack/echo"
 	"github.com/phnam/go-protocol-adapter/client"
 	"github.com/phnam/go-protocol-adapter/common"
 	"github.com/phnam/go-protocol-adapter/request"
@@ -61,3 +74,595 @@ func TestHTTPServer(t *testing.T) {
 	}
 
 }
+
+func TestHTTPServerStopDrain(t *testing.T) {
+	port := freePort(t)
+	started := make(chan bool, 1)
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/slow", func(req request.APIRequest, res responder.APIResponder) error {
+		started <- true
+		time.Sleep(300 * time.Millisecond)
+		return res.Respond(common.NewOkResponse(nil, "done"))
+	})
+	srv.Expose(port)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go srv.Start(&wg)
+	time.Sleep(200 * time.Millisecond)
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  "localhost:" + strconv.Itoa(port),
+		Timeout:  2 * time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+	done := make(chan *common.APIResponse[any], 1)
+	go func() {
+		done <- cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"})
+	}()
+	<-started
+
+	if srv.InFlight() != 1 {
+		t.Error("Expected 1 in-flight request, got " + strconv.FormatInt(srv.InFlight(), 10))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Stop(ctx); err != nil {
+		t.Error("Stop should drain before deadline: " + err.Error())
+	}
+	if srv.InFlight() != 0 {
+		t.Error("Expected no in-flight request after Stop")
+	}
+
+	resp := <-done
+	if resp.Status != common.APIStatus.Ok {
+		t.Error("In-flight request should complete during drain. Got status: " + resp.Status)
+	}
+	wg.Wait()
+}
+
+func TestHTTPServerGzipMinLength(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:      common.Protocol.HTTP,
+		GzipMinLength: 1024,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/small", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "tiny"))
+	})
+	srv.SetHandler(common.APIMethod.GET, "/large", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, strings.Repeat("large ", 500)))
+	})
+
+	for path, expected := range map[string]string{"/small": "", "/large": "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Error("Wrong status for " + path + ": " + strconv.Itoa(rec.Code))
+		}
+		if rec.Header().Get("Content-Encoding") != expected {
+			t.Error("Wrong Content-Encoding for " + path + ": " + rec.Header().Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestHTTPServerNotFound(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	var resp common.APIResponse[any]
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal("404 body should be an APIResponse: " + err.Error())
+	}
+	if rec.Code != http.StatusNotFound || resp.Status != common.APIStatus.NotFound || resp.ErrorCode != "NOT_FOUND" {
+		t.Error("Wrong 404 response: " + rec.Body.String())
+	}
+
+	// custom not found handler
+	srv.(*server.HTTPAPIServer).SetNotFoundHandler(func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewErrorResponse(common.APIStatus.NotFound, "NO_SUCH_PAGE", "Nothing at "+req.GetPath()))
+	})
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	resp = common.APIResponse[any]{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusNotFound || resp.ErrorCode != "NO_SUCH_PAGE" {
+		t.Error("Custom not found handler wasn't used: " + rec.Body.String())
+	}
+}
+
+func TestHTTPServerRequestID(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	var seen string
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		seen = req.GetRequestID()
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+
+	// incoming id is preserved
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Id", "incoming-id")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if seen != "incoming-id" || rec.Header().Get("X-Request-Id") != "incoming-id" {
+		t.Error("Incoming request id wasn't preserved: " + seen + " / " + rec.Header().Get("X-Request-Id"))
+	}
+
+	// missing id is generated
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if seen == "" || seen == "incoming-id" || rec.Header().Get("X-Request-Id") != seen {
+		t.Error("Request id wasn't generated: " + seen + " / " + rec.Header().Get("X-Request-Id"))
+	}
+}
+
+func TestHTTPServerStream(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/events", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.RespondStream("", func(w io.Writer) error {
+			for i := 1; i <= 3; i++ {
+				if _, err := fmt.Fprintf(w, "data: event %d\n\n", i); err != nil {
+					return err
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return nil
+		})
+	})
+	address := startServer(t, srv)
+
+	httpResp, err := http.Get("http://" + address + "/events")
+	if err != nil {
+		t.Fatal("Cannot open event stream: " + err.Error())
+	}
+	defer httpResp.Body.Close()
+	if httpResp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Error("Wrong content type: " + httpResp.Header.Get("Content-Type"))
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(httpResp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			events = append(events, strings.TrimPrefix(scanner.Text(), "data: "))
+		}
+	}
+	if len(events) != 3 || events[2] != "event 3" {
+		t.Error("Wrong events: " + strings.Join(events, ","))
+	}
+
+	// streaming isn't supported over Thrift
+	if responder.NewThriftAPIResponder("host", "").RespondStream("", func(w io.Writer) error { return nil }) == nil {
+		t.Error("Thrift responder should reject streaming responses")
+	}
+}
+
+func TestHTTPServerQueryMethod(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	var method *common.MethodValue
+	srv.SetHandler(common.APIMethod.QUERY, "/search", func(req request.APIRequest, res responder.APIResponder) error {
+		method = req.GetMethod()
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("QUERY", "/search", strings.NewReader(`{"q":"x"}`)))
+	if rec.Code != http.StatusOK {
+		t.Error("QUERY request wasn't routed: " + strconv.Itoa(rec.Code))
+	}
+	if method != common.APIMethod.QUERY {
+		t.Error("HTTP request should map QUERY to the enum value")
+	}
+
+	// path parameters are resolved for dynamically routed QUERY requests
+	var id string
+	srv.SetHandler(common.APIMethod.QUERY, "/items/:id", func(req request.APIRequest, res responder.APIResponder) error {
+		id = req.GetVar("id")
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("QUERY", "/items/42", nil))
+	if rec.Code != http.StatusOK || id != "42" {
+		t.Error("QUERY path parameter wasn't resolved: " + id)
+	}
+}
+
+func TestHTTPServerUnroutedMiddlewares(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	var inFlight int64
+	handler := func(req request.APIRequest, res responder.APIResponder) error {
+		inFlight = srv.InFlight()
+		return res.Respond(common.NewOkResponse([]any{strings.Repeat("result ", 200)}, "ok"))
+	}
+	srv.SetHandler(common.APIMethod.QUERY, "/search", handler)
+	srv.SetHandler(common.APIMethod.GET, "/items", handler)
+	srv.SetFallbackHandler(handler)
+
+	// custom methods and the fallback handler go through the middlewares: counted in-flight and compressed
+	for _, target := range []struct{ method, path string }{{"QUERY", "/search"}, {"GET", "/missing"}} {
+		inFlight = 0
+		req := httptest.NewRequest(target.method, target.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || inFlight != 1 {
+			t.Errorf("%s %s should be handled while counted in-flight, got %d with %d in-flight", target.method, target.path, rec.Code, inFlight)
+		}
+		if rec.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("%s %s response should be compressed", target.method, target.path)
+		}
+	}
+
+	// a path registered for another method isn't an unmatched route
+	inFlight = 0
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("DELETE", "/items", nil))
+	if rec.Code != http.StatusNotFound || inFlight != 0 {
+		t.Errorf("DELETE /items shouldn't reach the fallback handler, got %d", rec.Code)
+	}
+}
+
+func TestHTTPServerRequestCancellation(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	canceled := make(chan bool, 1)
+	srv.SetHandler(common.APIMethod.GET, "/wait", func(req request.APIRequest, res responder.APIResponder) error {
+		select {
+		case <-req.Context().Done():
+			canceled <- true
+		case <-time.After(2 * time.Second):
+			canceled <- false
+		}
+		return nil
+	})
+	address := startServer(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	httpReq, _ := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+address+"/wait", nil)
+	http.DefaultClient.Do(httpReq)
+
+	if !<-canceled {
+		t.Error("Handler context wasn't canceled when the client disconnected")
+	}
+}
+
+func TestHTTPServerGzipSkipsCompressedContent(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 2048)...)
+	srv.(*server.HTTPAPIServer).Echo.GET("/image", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, "image/png", png)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/image", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), png) {
+		t.Error("Already compressed content shouldn't be gzipped again")
+	}
+}
+
+func TestHTTPServerCORSPreflight(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+		CORS: &server.CORSConfig{
+			AllowOrigins: []string{"https://app.example.com"},
+			MaxAge:       600,
+		},
+	})
+	srv.SetHandler(common.APIMethod.GET, "/users/:id", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	srv.SetHandler(common.APIMethod.DELETE, "/users/me", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+
+	preflight := func(origin string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "Authorization")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := preflight("https://app.example.com", "/users/1")
+	if rec.Code != http.StatusNoContent {
+		t.Fatal("Preflight should return 204, got " + strconv.Itoa(rec.Code))
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		rec.Header().Get("Access-Control-Allow-Methods") != "GET,OPTIONS" ||
+		rec.Header().Get("Access-Control-Allow-Headers") != "Authorization" ||
+		rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Error("Wrong preflight headers: " + fmt.Sprint(rec.Header()))
+	}
+
+	// the methods of all the routes matching the path are allowed
+	if rec := preflight("https://app.example.com", "/users/me"); rec.Header().Get("Access-Control-Allow-Methods") != "DELETE,GET,OPTIONS" {
+		t.Error("Preflight should allow the methods of every matching route, got " + rec.Header().Get("Access-Control-Allow-Methods"))
+	}
+
+	// unregistered paths and unknown origins aren't answered, but the responses still vary by origin
+	if rec := preflight("https://app.example.com", "/unknown"); rec.Code == http.StatusNoContent {
+		t.Error("Preflight to an unregistered path shouldn't succeed")
+	}
+	rec = preflight("https://evil.example.com", "/users/1")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Unknown origin shouldn't be allowed")
+	}
+	if rec.Header().Get("Vary") != "Origin" {
+		t.Error("Response to an unknown origin should vary by origin, got " + rec.Header().Get("Vary"))
+	}
+
+	// actual requests carry the allowed origin
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Error("Actual request should carry the allowed origin")
+	}
+}
+
+func TestParseBodyJSONLimits(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:      protocol,
+			MaxJSONDepth:  10,
+			MaxJSONTokens: 100,
+		})
+		srv.SetHandler(common.APIMethod.POST, "/parse", func(req request.APIRequest, res responder.APIResponder) error {
+			var data any
+			if err := req.ParseBody(&data); err != nil {
+				return res.Respond(common.FromError(err))
+			}
+			return res.Respond(common.NewOkResponse(nil, "parsed"))
+		})
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		cases := map[string]string{
+			"valid":  `{"name":"ok","tags":["a","b"]}`,
+			"nested": strings.Repeat("[", 10000) + strings.Repeat("]", 10000),
+			"huge":   "[" + strings.Repeat("1,", 1000) + "1]",
+		}
+		for name, body := range cases {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/parse", Content: body})
+			if name == "valid" {
+				if resp.Status != common.APIStatus.Ok {
+					t.Error(protocol + " valid body rejected: " + resp.Message)
+				}
+			} else if resp.Status != common.APIStatus.Invalid || resp.ErrorCode != "INVALID_JSON" {
+				t.Error(protocol + " " + name + " body wasn't rejected: " + resp.Status + " " + resp.Message)
+			}
+		}
+	}
+}
+
+func TestHTTPServerReadTimeout(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:    common.Protocol.HTTP,
+		ReadTimeout: 200 * time.Millisecond,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	address := startServer(t, srv)
+
+	// send an incomplete request and stall
+	con, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	con.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n"))
+
+	start := time.Now()
+	con.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = io.ReadAll(con)
+	if err != nil || time.Since(start) > time.Second {
+		t.Error("Stalled connection wasn't closed after ReadTimeout")
+	}
+}
+
+func TestHTTPServerObjectResponse(t *testing.T) {
+	ts := newTestHTTPServer(t, func(srv server.Server) {
+		srv.SetHandler(common.APIMethod.GET, "/profile", func(req request.APIRequest, res responder.APIResponder) error {
+			profile := map[string]string{"name": "alice"}
+			return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, profile, "profile", "", 0, nil))
+		})
+		srv.SetHandler(common.APIMethod.GET, "/list", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, []string{"a", "b"}, "list", "", 0, nil))
+		})
+	})
+
+	cases := map[string]string{
+		"/profile": `"data":{"name":"alice"}`,
+		"/list":    `"data":["a","b"]`,
+	}
+	for path, expected := range cases {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), expected) {
+			t.Error("Response of " + path + " should contain " + expected + ", got " + string(body))
+		}
+	}
+}
+
+func TestHTTPServerRequestDecompression(t *testing.T) {
+	ts := newTestHTTPServer(t, func(srv server.Server) {
+		srv.SetHandler(common.APIMethod.POST, "/users", func(req request.APIRequest, res responder.APIResponder) error {
+			var user struct {
+				Name string `json:"name"`
+			}
+			if err := req.ParseBody(&user); err != nil {
+				return res.Respond(common.FromError(err))
+			}
+			return res.Respond(common.NewOkResponse(nil, "hello "+user.Name))
+		})
+	})
+
+	// gzip body sent by hand
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(`{"name":"alice"}`))
+	gw.Close()
+	httpReq, _ := http.NewRequest(http.MethodPost, ts.URL+"/users", &buf)
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if !strings.Contains(string(body), "hello alice") {
+		t.Error("Gzip body should be decompressed, got " + string(body))
+	}
+
+	// body compressed by the client
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:             ts.URL,
+		Timeout:             time.Second,
+		Protocol:            common.Protocol.HTTP,
+		CompressRequestBody: true,
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/users", Content: `{"name":"bob"}`})
+	if resp.Message != "hello bob" {
+		t.Error("Client-compressed body should be decompressed, got " + resp.Message)
+	}
+
+	// corrupted body
+	httpReq, _ = http.NewRequest(http.MethodPost, ts.URL+"/users", strings.NewReader("not gzip"))
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	httpResp, err = http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusBadRequest {
+		t.Error("Corrupted body should be rejected with 400, got " + strconv.Itoa(httpResp.StatusCode))
+	}
+}
+
+func TestHTTPServerDecompressionLimit(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:           common.Protocol.HTTP,
+		MaxDecodedBodySize: 64 << 10,
+	})
+	srv.SetHandler(common.APIMethod.POST, "/users", func(req request.APIRequest, res responder.APIResponder) error {
+		var user struct {
+			Name string `json:"name"`
+		}
+		if err := req.ParseBody(&user); err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		return res.Respond(common.NewOkResponse(nil, "hello "+user.Name))
+	})
+
+	post := func(content string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		gw.Write([]byte(content))
+		gw.Close()
+		httpReq := httptest.NewRequest(http.MethodPost, "/users", &buf)
+		httpReq.Header.Set("Content-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httpReq)
+		return rec
+	}
+
+	// 10 MB of a repeated byte compresses to a few KB
+	bomb := `{"name":"` + strings.Repeat("a", 10<<20) + `"}`
+	rec := post(bomb)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "INVALID_BODY_SIZE") {
+		t.Error("Body over the decoded size limit should be rejected with INVALID_BODY_SIZE, got " + strconv.Itoa(rec.Code) + " " + rec.Body.String())
+	}
+
+	if rec := post(`{"name":"alice"}`); !strings.Contains(rec.Body.String(), "hello alice") {
+		t.Error("Body within the decoded size limit should be decompressed, got " + rec.Body.String())
+	}
+}
+
+func TestAsHTTPHandler(t *testing.T) {
+	getItem := func(req request.APIRequest, res responder.APIResponder) error {
+		if _, ok := req.GetRaw().(*http.Request); !ok {
+			return common.NewError("NOT_NET_HTTP", "request isn't backed by an *http.Request")
+		}
+		switch req.GetVar("id") {
+		case "0":
+			return common.NotFoundError("item 0 doesn't exist")
+		case "panic":
+			panic("boom")
+		}
+		res.SetHeader("X-Item", req.GetVar("id"))
+		return res.Respond(common.NewOkResponse([]any{req.GetVar("id") + ":" + req.GetParam("fields")}, "item"))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/items/{id}", server.AsHTTPHandler(common.APIMethod.GET, getItem))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:  ts.URL,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.HTTP,
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items/42", Params: map[string]string{"fields": "name"}})
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != "42:name" {
+		t.Errorf("Handler mounted on a ServeMux should get the path and query parameters, got %s %v", resp.Status, resp.Data)
+	}
+
+	// returned errors are sent as error responses
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items/0"})
+	if resp.Status != common.APIStatus.NotFound || resp.ErrorCode != "NOT_FOUND" {
+		t.Errorf("Returned error should be sent as a NOT_FOUND response, got %s %s", resp.Status, resp.ErrorCode)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/panic", nil))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"status":"ERROR"`) {
+		t.Errorf("Panics should be sent as an ERROR response, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/42", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Item") != "42" || rec.Header().Get("X-Hostname") == "" {
+		t.Errorf("Response should be written with the headers set by the handler, got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items/42", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET" {
+		t.Errorf("Other methods should be answered with 405, got %d", rec.Code)
+	}
+}
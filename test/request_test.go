@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/thriftapi"
+)
+
+func TestRequestGetRaw(t *testing.T) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	if raw, ok := request.NewHTTPAPIRequest(c).GetRaw().(echo.Context); !ok || raw != c {
+		t.Error("HTTP request should expose its echo.Context")
+	}
+
+	thriftReq := &thriftapi.APIRequest{Method: "GET", Path: "/"}
+	if raw, ok := request.NewThriftAPIRequest(thriftReq).GetRaw().(*thriftapi.APIRequest); !ok || raw != thriftReq {
+		t.Error("Thrift request should expose its *thriftapi.APIRequest")
+	}
+
+	outbound := &request.OutboundAPIRequest{Method: "GET", Path: "/"}
+	if raw, ok := outbound.GetRaw().(*request.OutboundAPIRequest); !ok || raw != outbound {
+		t.Error("Outbound request should expose itself")
+	}
+}
+
+func TestOutboundRequestBuilder(t *testing.T) {
+	built, err := request.NewRequest("POST", "/users").
+		WithParam("notify", "true").
+		WithHeader("X-Tenant", "acme").
+		WithJSONBody(map[string]string{"name": "alice"}).
+		Build()
+	if err != nil {
+		t.Fatal("Build failed: " + err.Error())
+	}
+
+	expected := &request.OutboundAPIRequest{
+		Method:  "POST",
+		Path:    "/users",
+		Params:  map[string]string{"notify": "true"},
+		Headers: map[string]string{"X-Tenant": "acme"},
+		Content: `{"name":"alice"}`,
+	}
+	if !reflect.DeepEqual(built, expected) {
+		t.Errorf("Built request %+v doesn't match %+v", built, expected)
+	}
+
+	for _, method := range []string{"", "GET /", "BRÜH"} {
+		if _, err := request.NewRequest(method, "/").Build(); err == nil {
+			t.Error("Method \"" + method + "\" should be rejected")
+		}
+	}
+	if _, err := request.NewRequest("GET", "/").WithJSONBody(make(chan int)).Build(); err == nil {
+		t.Error("Body that can't be encoded should be rejected")
+	}
+}
+
+func TestRequestGetIP(t *testing.T) {
+	for _, test := range []struct {
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"192.168.1.5:52000", "", "192.168.1.5"},
+		{"[::1]:12345", "", "::1"},
+		{"[2001:db8::7]:443", "", "2001:db8::7"},
+		{"10.0.0.1:80", "203.0.113.9, 10.0.0.2, 10.0.0.1", "203.0.113.9"},
+		{"10.0.0.1:80", "2001:db8::1, 10.0.0.2", "2001:db8::1"},
+		{"10.0.0.1:80", "[2001:db8::1]:8080, 10.0.0.2", "2001:db8::1"},
+		{"10.0.0.1:80", "unknown, 203.0.113.9", "203.0.113.9"},
+		{"10.0.0.1:80", " , garbage", "10.0.0.1"},
+	} {
+		httpReq := httptest.NewRequest(http.MethodGet, "/", nil)
+		httpReq.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			httpReq.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		c := echo.New().NewContext(httpReq, httptest.NewRecorder())
+		if ip := request.NewHTTPAPIRequest(c).GetIP(); ip != test.expected {
+			t.Error("GetIP with RemoteAddr " + test.remoteAddr + " and X-Forwarded-For \"" + test.forwarded + "\" should be " + test.expected + ", got " + ip)
+		}
+	}
+
+	thriftReq := request.NewThriftAPIRequest(&thriftapi.APIRequest{Headers: map[string]string{"X-Forwarded-For": "2001:db8::1, 10.0.0.2"}})
+	if ip := thriftReq.GetIP(); ip != "2001:db8::1" {
+		t.Error("Thrift GetIP should take the left-most forwarded IP, got " + ip)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := request.ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::1"})
+	if err != nil {
+		t.Fatal("Valid proxies should parse: " + err.Error())
+	}
+	if len(proxies) != 4 || proxies[1].String() != "192.168.1.1/32" || proxies[3].String() != "::1/128" {
+		t.Error("Unexpected trusted proxies")
+	}
+
+	_, err = request.ParseTrustedProxies([]string{"10.0.0.0/8", "not-a-cidr"})
+	var e *common.Error
+	if !errors.As(err, &e) || e.ErrorCode != "INVALID_CIDR" {
+		t.Error("Invalid proxy should fail with INVALID_CIDR")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/responder"
+	"github.com/phnam/go-protocol-adapter/thriftapi"
+)
+
+// headerDuration parses an X-Execution-Time header value ("1.2345 ms").
+func headerDuration(t *testing.T, value string) time.Duration {
+	ms, err := strconv.ParseFloat(strings.TrimSuffix(value, " ms"), 64)
+	if err != nil {
+		t.Fatal("Invalid X-Execution-Time header: " + value)
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func TestHTTPResponderRespondOnceShared(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	pre := responder.NewHTTPAPIResponder(c, "host", "")
+	main := responder.NewHTTPAPIResponder(c, "host", "")
+
+	if err := pre.Respond(common.NewOkResponse(nil, "pre")); err != nil {
+		t.Fatal(err)
+	}
+	// the responders of the same request share whether a response was sent
+	if err := main.Respond(common.NewOkResponse(nil, "main")); err == nil || !strings.HasPrefix(err.Error(), "ALREADY_RESPONDED") {
+		t.Errorf("Second responder of the request should get ALREADY_RESPONDED, got %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), `"pre"`) {
+		t.Errorf("First response should be kept, got %s", rec.Body.String())
+	}
+}
+
+func TestResponderExecutionTime(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	httpResponder := responder.NewHTTPAPIResponder(c, "host", "")
+	thriftResponder := responder.NewThriftAPIResponder("host", "")
+	time.Sleep(5 * time.Millisecond)
+
+	httpResponder.Respond(common.NewOkResponse(nil, "ok"))
+	thriftResponder.Respond(common.NewOkResponse(nil, "ok"))
+
+	headers := map[string]string{
+		"HTTP":   rec.Header().Get("X-Execution-Time"),
+		"THRIFT": thriftResponder.GetRawResponse().(*thriftapi.APIResponse).Headers["X-Execution-Time"],
+	}
+	for protocol, res := range map[string]responder.APIResponder{"HTTP": httpResponder, "THRIFT": thriftResponder} {
+		elapsed := res.GetExecutionTime()
+		if elapsed < 5*time.Millisecond {
+			t.Error(protocol + " execution time is too small: " + elapsed.String())
+		}
+		diff := elapsed - headerDuration(t, headers[protocol])
+		if diff < -time.Microsecond || diff > time.Microsecond {
+			t.Error(protocol + " execution time doesn't match header: " + elapsed.String() + " vs " + headers[protocol])
+		}
+	}
+}
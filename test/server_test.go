@@ -0,0 +1,1477 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/phnam/go-protocol-adapter/client"
+	"github.com/phnam/go-protocol-adapter/common"
+	"github.com/phnam/go-protocol-adapter/request"
+	"github.com/phnam/go-protocol-adapter/responder"
+	"github.com/phnam/go-protocol-adapter/server"
+)
+
+func listUsers(req request.APIRequest, res responder.APIResponder) error {
+	return res.Respond(common.NewOkResponse(nil, "users"))
+}
+
+func TestServerRoutes(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/users", listUsers)
+		srv.SetHandler(common.APIMethod.POST, "/users", listUsers)
+		srv.SetHandler(common.APIMethod.GET, "/users/:id", listUsers)
+
+		routes := srv.Routes()
+		expected := []server.RouteInfo{
+			{Method: "GET", Path: "/users"},
+			{Method: "POST", Path: "/users"},
+			{Method: "GET", Path: "/users/:id"},
+		}
+		if len(routes) != len(expected) {
+			t.Fatal(protocol + " server should list 3 routes")
+		}
+		for i, route := range routes {
+			if route.Method != expected[i].Method || route.Path != expected[i].Path {
+				t.Error(protocol + " wrong route: " + route.Method + " " + route.Path)
+			}
+			if !strings.HasSuffix(route.FuncName, "listUsers") {
+				t.Error(protocol + " wrong handler name: " + route.FuncName)
+			}
+		}
+	}
+}
+
+func TestServerSetHandlerMulti(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandlerMulti([]*common.MethodValue{common.APIMethod.GET, common.APIMethod.POST}, "/users",
+			func(req request.APIRequest, res responder.APIResponder) error {
+				return res.Respond(common.NewOkResponse([]any{req.GetMethod().Value}, "ok"))
+			})
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		for _, method := range []string{"GET", "POST"} {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: method, Path: "/users", Content: "{}"})
+			if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != method {
+				t.Error(protocol + " " + method + " wasn't handled: " + resp.Status + " " + resp.Message)
+			}
+		}
+
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "DELETE", Path: "/users"})
+		if resp.Status != common.APIStatus.NotFound {
+			t.Error(protocol + " DELETE shouldn't be handled: " + resp.Status)
+		}
+	}
+}
+
+func TestServerCustomMethods(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		for _, method := range []*common.MethodValue{common.APIMethod.TRACE, common.MethodFromString("PURGE")} {
+			srv.SetHandler(method, "/cache/:key", func(req request.APIRequest, res responder.APIResponder) error {
+				return res.Respond(common.NewOkResponse([]any{req.GetMethod().Value + " " + req.GetVar("key")}, "ok"))
+			})
+		}
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		for _, method := range []string{"TRACE", "PURGE"} {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: method, Path: "/cache/users"})
+			if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != method+" users" {
+				t.Error(protocol + " " + method + " wasn't handled: " + resp.Status + " " + resp.Message)
+			}
+		}
+	}
+}
+
+func TestServerHostnameOverride(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+			Hostname: "api-instance-1",
+		})
+		srv.SetHandler(common.APIMethod.GET, "/", listUsers)
+		address := startServer(t, srv)
+
+		hostname := ""
+		if protocol == common.Protocol.HTTP {
+			resp, err := http.Get("http://" + address + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			hostname = resp.Header.Get("X-Hostname")
+		} else {
+			cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+				Address:       address,
+				Timeout:       time.Second,
+				MaxConnection: 1,
+				Protocol:      protocol,
+			})
+			hostname = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"}).Headers["X-Hostname"]
+		}
+
+		if hostname != "api-instance-1" || srv.GetHostname() != "api-instance-1" {
+			t.Error(protocol + " server should report the configured hostname, got " + hostname)
+		}
+	}
+}
+
+// stopRecorder wraps a server and counts the calls to Stop.
+type stopRecorder struct {
+	server.Server
+	stopped atomic.Int32
+}
+
+func (s *stopRecorder) Stop(ctx context.Context) error {
+	s.stopped.Add(1)
+	return s.Server.Stop(ctx)
+}
+
+func TestRunWithGracefulShutdown(t *testing.T) {
+	srv := &stopRecorder{Server: server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})}
+	port := freePort(t)
+	srv.Expose(port)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.RunWithGracefulShutdown(srv)
+	}()
+
+	// wait for the server to listen, the signal handler is registered before it starts
+	for i := 0; i < 100; i++ {
+		con, err := net.Dial("tcp", "localhost:"+strconv.Itoa(port))
+		if err == nil {
+			con.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("Shutdown should succeed, got " + err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Servers weren't stopped after the signal")
+	}
+	if srv.stopped.Load() != 1 {
+		t.Error("Stop should be called once, called " + strconv.Itoa(int(srv.stopped.Load())))
+	}
+}
+
+// namingAccount is serialized with Go field names whatever the server naming strategy.
+type namingAccount struct {
+	AccountID string
+}
+
+func (namingAccount) JSONNaming() responder.NamingStrategy {
+	return nil
+}
+
+func TestServerResponseNaming(t *testing.T) {
+	type namingProfile struct {
+		UserID     int
+		FirstName  string
+		HTTPServer string
+		Nickname   string `json:"nick"`
+		Note       string `json:",omitempty"`
+		Account    namingAccount
+	}
+	handler := func(req request.APIRequest, res responder.APIResponder) error {
+		profile := namingProfile{UserID: 7, FirstName: "Alice", HTTPServer: "web", Nickname: "al", Account: namingAccount{AccountID: "a1"}}
+		return res.Respond(common.NewOkResponse([]any{profile}, "profile"))
+	}
+	expected := `"data":[{"user_id":7,"first_name":"Alice","http_server":"web","nick":"al","account":{"AccountID":"a1"}}]`
+
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:       protocol,
+			ResponseNaming: responder.SnakeCase,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/profile", handler)
+		address := startServer(t, srv)
+
+		body := ""
+		if protocol == common.Protocol.HTTP {
+			resp, err := http.Get("http://" + address + "/profile")
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body = string(content)
+		} else {
+			cli := client.NewAPIClient[map[string]any](&client.APIClientConfiguration{
+				Address:       address,
+				Timeout:       time.Second,
+				MaxConnection: 1,
+				Protocol:      common.Protocol.THRIFT,
+			})
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/profile"})
+			content, _ := json.Marshal(map[string]any{"data": resp.Data})
+			body = string(content)
+			expected = `"data":[{"account":{"AccountID":"a1"},"first_name":"Alice","http_server":"web","nick":"al","user_id":7}]`
+		}
+		if !strings.Contains(body, expected) {
+			t.Error(protocol + " response should use snake_case names, got " + body)
+		}
+	}
+}
+
+func TestServerPanicHandler(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		var recovered interface{}
+		srv.SetPanicHandler(func(rec interface{}, req request.APIRequest) *common.APIResponse[any] {
+			recovered = rec
+			return common.NewErrorResponse(common.APIStatus.Error, "PANIC", "trace "+req.GetRequestID())
+		})
+		srv.SetHandler(common.APIMethod.GET, "/boom", func(req request.APIRequest, res responder.APIResponder) error {
+			panic("boom")
+		})
+		address := startServer(t, srv)
+
+		message := ""
+		if protocol == common.Protocol.HTTP {
+			httpReq, _ := http.NewRequest(http.MethodGet, "http://"+address+"/boom", nil)
+			httpReq.Header.Set(request.RequestIDHeader, "req-1")
+			resp, err := http.DefaultClient.Do(httpReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body common.APIResponse[any]
+			json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+			message = body.Message
+		} else {
+			cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+				Address:       address,
+				Timeout:       time.Second,
+				MaxConnection: 1,
+				Protocol:      common.Protocol.THRIFT,
+			})
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{
+				Method:  "GET",
+				Path:    "/boom",
+				Headers: map[string]string{request.RequestIDHeader: "req-1"},
+			})
+			message = resp.Message
+		}
+
+		if recovered != "boom" {
+			t.Error(protocol + " panic handler should receive the recovered value")
+		}
+		if message != "trace req-1" {
+			t.Error(protocol + " should respond with the panic handler response, got " + message)
+		}
+	}
+}
+
+func TestServerRespondTwice(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		var secondErr error
+		srv.SetHandler(common.APIMethod.GET, "/twice", func(req request.APIRequest, res responder.APIResponder) error {
+			res.Respond(common.NewOkResponse(nil, "first"))
+			secondErr = res.Respond(common.NewErrorResponse(common.APIStatus.Error, "FAILED", "second"))
+			return nil
+		})
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/twice"})
+
+		var e *common.Error
+		if !errors.As(secondErr, &e) || e.ErrorCode != "ALREADY_RESPONDED" {
+			t.Error(protocol + " second Respond should fail with ALREADY_RESPONDED")
+		}
+		if resp.Status != common.APIStatus.Ok || resp.Message != "first" {
+			t.Error(protocol + " first response should win, got " + resp.Status + ": " + resp.Message)
+		}
+	}
+}
+
+func TestServerResponseEnvelope(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+		ResponseEnvelope: func(response *common.APIResponse[any], data interface{}) interface{} {
+			return map[string]interface{}{
+				"success": response.Status == common.APIStatus.Ok,
+				"result":  data,
+				"error":   response.ErrorCode,
+			}
+		},
+	})
+	srv.SetHandler(common.APIMethod.GET, "/item", func(req request.APIRequest, res responder.APIResponder) error {
+		if req.GetParam("id") != "1" {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.NotFound, "ITEM_NOT_FOUND", "no such item"))
+		}
+		return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, map[string]any{"id": 1}, "item", "", 0, nil))
+	})
+	address := startServer(t, srv)
+
+	for _, test := range []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"?id=1", http.StatusOK, `{"error":"","result":{"id":1},"success":true}`},
+		{"?id=2", http.StatusNotFound, `{"error":"ITEM_NOT_FOUND","result":null,"success":false}`},
+	} {
+		resp, err := http.Get("http://" + address + "/item" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Error("Expected status " + strconv.Itoa(test.status) + ", got " + strconv.Itoa(resp.StatusCode))
+		}
+		if strings.TrimSpace(string(content)) != test.body {
+			t.Error("Unexpected envelope: " + string(content))
+		}
+	}
+}
+
+func TestServerFallbackHandler(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/users", listUsers)
+		srv.SetFallbackHandler(func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.NotFound, "NO_ROUTE", "fallback "+req.GetMethod().Value+" "+req.GetPath()))
+		})
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/users"})
+		if resp.Status != common.APIStatus.Ok {
+			t.Error(protocol + " matched route shouldn't hit the fallback, got " + resp.Status + ": " + resp.Message)
+		}
+
+		resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/unknown/path"})
+		if resp.Status != common.APIStatus.NotFound || resp.ErrorCode != "NO_ROUTE" || resp.Message != "fallback POST /unknown/path" {
+			t.Error(protocol + " unmatched path should hit the fallback, got " + resp.Status + "/" + resp.ErrorCode + ": " + resp.Message)
+		}
+	}
+}
+
+func TestServerTrustedProxies(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		trusted  []string
+		expected string
+	}{
+		{"trusted peer", []string{"127.0.0.1", "10.0.0.0/8"}, "198.51.100.1"},
+		{"untrusted peer", []string{"10.0.0.0/8"}, "127.0.0.1"},
+		{"no trusted proxies", nil, "203.0.113.9"},
+	} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:       common.Protocol.HTTP,
+			TrustedProxies: test.trusted,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/ip", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse(nil, req.GetIP()))
+		})
+		address := startServer(t, srv)
+
+		httpReq, _ := http.NewRequest(http.MethodGet, "http://"+address+"/ip", nil)
+		httpReq.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.1, 10.0.0.2")
+		resp, err := http.DefaultClient.Do(httpReq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body common.APIResponse[any]
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if body.Message != test.expected {
+			t.Error(test.name + ": GetIP should be " + test.expected + ", got " + body.Message)
+		}
+	}
+}
+
+func TestServerCountResponse(t *testing.T) {
+	countItems := func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewCountResponse(42))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandlerMulti([]*common.MethodValue{common.APIMethod.GET, common.MethodFromString("HEAD")}, "/items", countItems)
+		address := startServer(t, srv)
+
+		if protocol == common.Protocol.HTTP {
+			resp, err := http.Head("http://" + address + "/items")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.Header.Get(common.TotalCountHeader) != "42" {
+				t.Error("HEAD count probe should convey the total in X-Total-Count, got " + resp.Header.Get(common.TotalCountHeader))
+			}
+		}
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"})
+		if resp.Status != common.APIStatus.Ok || resp.Total != 42 || len(resp.Data) != 0 {
+			t.Error(protocol + " count response should carry the total without data, got total " + strconv.FormatInt(resp.Total, 10))
+		}
+		if protocol == common.Protocol.THRIFT && resp.Headers[common.TotalCountHeader] != "42" {
+			t.Error("Thrift count response should carry the X-Total-Count header")
+		}
+	}
+}
+
+func TestServerRawHandler(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.SetRawHandler(common.APIMethod.GET, "/webhook/status", func(req request.APIRequest) (interface{}, error) {
+		if req.GetParam("id") != "1" {
+			return nil, common.NewError("NOT_FOUND", "no such webhook")
+		}
+		return map[string]any{"id": 1, "active": true}, nil
+	})
+	address := startServer(t, srv)
+
+	for _, test := range []struct {
+		query  string
+		status int
+		body   string
+	}{
+		{"?id=1", http.StatusOK, `{"active":true,"id":1}`},
+		{"?id=2", http.StatusNotFound, `"error_code":"NOT_FOUND"`},
+	} {
+		resp, err := http.Get("http://" + address + "/webhook/status" + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Error("Expected status " + strconv.Itoa(test.status) + ", got " + strconv.Itoa(resp.StatusCode))
+		}
+		if !strings.Contains(string(content), test.body) {
+			t.Error("Unexpected body: " + string(content))
+		}
+		if resp.StatusCode == http.StatusOK && strings.Contains(string(content), `"status"`) {
+			t.Error("Raw handler body shouldn't have a status field: " + string(content))
+		}
+	}
+}
+
+func TestServerAcceptNegotiation(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+		ResponseEncoders: map[string]responder.BodyEncoder{
+			"application/xml": func(body interface{}) ([]byte, error) {
+				return []byte("<response><status>OK</status></response>"), nil
+			},
+		},
+	})
+	srv.SetHandler(common.APIMethod.GET, "/item", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "item"))
+	})
+	address := startServer(t, srv)
+
+	for _, test := range []struct {
+		accept      string
+		contentType string
+	}{
+		{"application/xml;q=0.9, application/json;q=1.0", "application/json"},
+		{"application/json;q=0.5, application/xml", "application/xml"},
+		{"application/*;q=0.8, application/xml;q=0.2", "application/json"},
+		{"*/*", "application/json"},
+		{"text/html", "application/json"},
+		{"", "application/json"},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+address+"/item", nil)
+		req.Header.Set("Accept", test.accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), test.contentType) {
+			t.Error("Accept \"" + test.accept + "\" should select " + test.contentType + ", got " + resp.Header.Get("Content-Type"))
+		}
+	}
+
+	if got := responder.NegotiateContentType("text/*;q=0.5, text/plain;q=0", []string{"text/plain", "text/csv"}); got != "text/csv" {
+		t.Error("The most specific media range should apply, got " + got)
+	}
+}
+
+func TestServerHandlerTimeout(t *testing.T) {
+	slow := func(req request.APIRequest, res responder.APIResponder) error {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+		return res.Respond(common.NewOkResponse(nil, "done"))
+	}
+	fast := func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "done"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:       protocol,
+			HandlerTimeout: 50 * time.Millisecond,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/slow", slow)
+		srv.SetHandler(common.APIMethod.GET, "/fast", fast)
+		address := startServer(t, srv)
+
+		if protocol == common.Protocol.HTTP {
+			start := time.Now()
+			resp, err := http.Get("http://" + address + "/slow")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusGatewayTimeout {
+				t.Error("Timed-out handler should answer 504, got " + strconv.Itoa(resp.StatusCode))
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Error("Timeout response should be sent at the deadline, took " + elapsed.String())
+			}
+		}
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		if protocol == common.Protocol.THRIFT {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"})
+			if resp.Status != common.APIStatus.Timeout || resp.ErrorCode != "TIMEOUT" {
+				t.Error("Thrift timed-out handler should answer TIMEOUT, got " + resp.Status)
+			}
+		}
+		if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/fast"}); resp.Status != common.APIStatus.Ok {
+			t.Error(protocol + " handler within the timeout should answer OK, got " + resp.Status)
+		}
+	}
+}
+
+// countingMarshaler is a pluggable marshaler counting its calls, backed by encoding/json.
+type countingMarshaler struct {
+	marshals, unmarshals atomic.Int64
+}
+
+func (m *countingMarshaler) Marshal(v interface{}) ([]byte, error) {
+	m.marshals.Add(1)
+	return common.StdMarshaler.Marshal(v)
+}
+
+func (m *countingMarshaler) Unmarshal(data []byte, v interface{}) error {
+	m.unmarshals.Add(1)
+	return common.StdMarshaler.Unmarshal(data, v)
+}
+
+func TestServerMarshaler(t *testing.T) {
+	echoItem := func(req request.APIRequest, res responder.APIResponder) error {
+		var item map[string]any
+		if err := req.ParseBody(&item); err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		return res.Respond(common.NewOkResponse([]any{item}, "echo"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		serverMarshaler := &countingMarshaler{}
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:  protocol,
+			Marshaler: serverMarshaler,
+		})
+		srv.SetHandler(common.APIMethod.POST, "/items", echoItem)
+		address := startServer(t, srv)
+
+		clientMarshaler := &countingMarshaler{}
+		cli := client.NewAPIClient[map[string]any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+			Marshaler:     clientMarshaler,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/items", Content: `{"id":7}`})
+		if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0]["id"] != float64(7) {
+			t.Errorf("%s item should round-trip through the marshalers, got %+v", protocol, resp)
+		}
+		if serverMarshaler.marshals.Load() != 1 || serverMarshaler.unmarshals.Load() != 1 {
+			t.Errorf("%s server should encode and decode with its marshaler, got %d/%d calls", protocol, serverMarshaler.marshals.Load(), serverMarshaler.unmarshals.Load())
+		}
+		if clientMarshaler.unmarshals.Load() == 0 {
+			t.Error(protocol + " client should decode with its marshaler")
+		}
+	}
+}
+
+// numberMarshaler is a pluggable marshaler decoding numbers as json.Number, telling its decoding from encoding/json's.
+type numberMarshaler struct{}
+
+func (numberMarshaler) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (numberMarshaler) Unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func TestClientMarshalerDecodesData(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse([]any{map[string]any{"id": 7}, map[string]any{"id": 8}}, "items"))
+		})
+		srv.SetHandler(common.APIMethod.GET, "/item", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, map[string]any{"id": 7}, "item", "", 0, nil))
+		})
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[map[string]any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+			Marshaler:     numberMarshaler{},
+		})
+		for _, path := range []string{"/items", "/item"} {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: path})
+			if len(resp.Data) == 0 || resp.Data[0]["id"] != json.Number("7") {
+				t.Errorf("%s client should decode the data of %s with its marshaler, got %#v", protocol, path, resp.Data)
+			}
+		}
+
+		var items []map[string]any
+		cli.MakeRequestInto(&request.OutboundAPIRequest{Method: "GET", Path: "/items"}, &items)
+		if len(items) != 2 || items[1]["id"] != json.Number("8") {
+			t.Errorf("%s client should decode the data into the target with its marshaler, got %#v", protocol, items)
+		}
+	}
+}
+
+func BenchmarkClientMarshaler(b *testing.B) {
+	items := make([]any, 5000)
+	for i := range items {
+		items[i] = map[string]any{"id": i, "name": "item <" + strconv.Itoa(i) + ">", "tags": []string{"a", "b"}}
+	}
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(items, "items"))
+	})
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	for _, bench := range []struct {
+		name      string
+		marshaler common.Marshaler
+	}{
+		{"stdlib", nil},
+		// measures the hook on the decode path, a faster library like jsoniter or sonic plugs in the same way
+		{"pluggable", &countingMarshaler{}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			cli := client.NewAPIClient[map[string]any](&client.APIClientConfiguration{
+				Address:   ts.URL,
+				Timeout:   5 * time.Second,
+				Protocol:  common.Protocol.HTTP,
+				Marshaler: bench.marshaler,
+			})
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"}); len(resp.Data) != len(items) {
+					b.Fatal("Unexpected response " + resp.Status + " " + resp.Message)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkResponderMarshaler(b *testing.B) {
+	type item struct {
+		ID    int               `json:"id"`
+		Name  string            `json:"name"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+	}
+	items := make([]any, 5000)
+	for i := range items {
+		items[i] = item{ID: i, Name: "item <" + strconv.Itoa(i) + ">", Tags: []string{"a", "b"}, Attrs: map[string]string{"color": "red"}}
+	}
+
+	for _, bench := range []struct {
+		name      string
+		marshaler common.Marshaler
+	}{
+		{"stdlib", nil},
+		// measures the hook itself, a faster library like jsoniter or sonic plugs in the same way
+		{"pluggable", &countingMarshaler{}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			srv := server.NewServer(server.ServerConfig{
+				Protocol:  common.Protocol.HTTP,
+				Marshaler: bench.marshaler,
+			})
+			srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+				return res.Respond(common.NewOkResponse(items, "items"))
+			})
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rec := httptest.NewRecorder()
+				srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items", nil))
+				if rec.Code != http.StatusOK {
+					b.Fatal("Unexpected status " + strconv.Itoa(rec.Code))
+				}
+			}
+		})
+	}
+}
+
+func TestServerPreResponse(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse([]any{"a"}, "items"))
+		})
+		srv.SetHandler(common.APIMethod.GET, "/missing", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewErrorResponse(common.APIStatus.NotFound, "NOT_FOUND", "no such item"))
+		})
+		srv.PreResponse(func(req request.APIRequest, resp *common.APIResponse[any]) error {
+			if resp.Headers == nil {
+				resp.Headers = map[string]string{}
+			}
+			resp.Headers["X-Served-By"] = "pre-response " + req.GetPath()
+			return nil
+		})
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		for _, test := range []struct {
+			path   string
+			status string
+		}{
+			{"/items", common.APIStatus.Ok},
+			{"/missing", common.APIStatus.NotFound},
+		} {
+			resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: test.path})
+			if resp.Status != test.status {
+				t.Errorf("%s %s should keep status %s, got %+v", protocol, test.path, test.status, resp)
+			}
+			header := resp.Headers["X-Served-By"]
+			if protocol == common.Protocol.HTTP {
+				// the HTTP client doesn't expose the response headers
+				httpResp, err := http.Get("http://" + address + test.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				httpResp.Body.Close()
+				header = httpResp.Header.Get("X-Served-By")
+			}
+			if header != "pre-response "+test.path {
+				t.Errorf("%s %s response should have the injected header, got %q", protocol, test.path, header)
+			}
+		}
+	}
+}
+
+func TestServerContentTypeOverride(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/greeting", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewObjectResponse(common.APIStatus.Ok, "hello world", "", "", 0,
+			map[string]string{"Content-Type": "text/plain; charset=utf-8"}))
+	})
+	srv.SetHandler(common.APIMethod.GET, "/problem", func(req request.APIRequest, res responder.APIResponder) error {
+		res.SetHeader("Content-Type", "application/problem+json")
+		return res.Respond(common.NewErrorResponse(common.APIStatus.Invalid, "INVALID_ID", "invalid id"))
+	})
+	srv.SetHandler(common.APIMethod.GET, "/item", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse([]any{"a"}, "item"))
+	})
+	address := startServer(t, srv)
+
+	for _, test := range []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/greeting", http.StatusOK, "text/plain; charset=utf-8", "hello world"},
+		{"/problem", http.StatusBadRequest, "application/problem+json", `"error_code":"INVALID_ID"`},
+		{"/item", http.StatusOK, "application/json; charset=UTF-8", `"data":["a"]`},
+	} {
+		resp, err := http.Get("http://" + address + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != test.status {
+			t.Error(test.path + " should answer " + strconv.Itoa(test.status) + ", got " + strconv.Itoa(resp.StatusCode))
+		}
+		if resp.Header.Get("Content-Type") != test.contentType {
+			t.Error(test.path + " should have content type " + test.contentType + ", got " + resp.Header.Get("Content-Type"))
+		}
+		if !strings.Contains(string(content), test.body) {
+			t.Error(test.path + " has an unexpected body: " + string(content))
+		}
+		if strings.HasPrefix(test.contentType, "text/plain") && string(content) != test.body {
+			t.Error(test.path + " should send the plain text without the JSON envelope, got " + string(content))
+		}
+	}
+}
+
+type createUserInput struct {
+	Name string `json:"name"`
+}
+
+type createdUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestServerSetHandlerTyped(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		server.SetHandlerTyped(srv, common.APIMethod.POST, "/users", func(input createUserInput) (createdUser, error) {
+			if input.Name == "" {
+				return createdUser{}, common.NewError("INVALID_NAME", "name is required")
+			}
+			return createdUser{ID: 7, Name: input.Name}, nil
+		})
+
+		routes := srv.Routes()
+		if len(routes) != 1 || routes[0].RequestType != reflect.TypeOf(createUserInput{}) || routes[0].ResponseType != reflect.TypeOf(createdUser{}) {
+			t.Errorf("%s route should report the handler types, got %+v", protocol, routes)
+		}
+
+		address := startServer(t, srv)
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		var user createdUser
+		resp := cli.MakeRequestInto(&request.OutboundAPIRequest{Method: "POST", Path: "/users", Content: `{"name":"Ann"}`}, &user)
+		if resp.Status != common.APIStatus.Ok || user.ID != 7 || user.Name != "Ann" {
+			t.Errorf("%s typed handler should return the created user, got %+v %+v", protocol, resp, user)
+		}
+
+		resp = cli.MakeRequestInto(&request.OutboundAPIRequest{Method: "POST", Path: "/users", Content: `{"name":""}`}, &user)
+		if resp.Status != common.APIStatus.Invalid || resp.ErrorCode != "INVALID_NAME" {
+			t.Errorf("%s handler error should be sent as an error response, got %+v", protocol, resp)
+		}
+
+		if protocol == common.Protocol.HTTP {
+			// the client only sends valid JSON bodies
+			httpResp, err := http.Post("http://"+address+"/users", "application/json", strings.NewReader(`{"name":`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, _ := io.ReadAll(httpResp.Body)
+			httpResp.Body.Close()
+			if httpResp.StatusCode != http.StatusBadRequest || !strings.Contains(string(content), `"error_code":"INVALID_BODY"`) {
+				t.Error("Malformed body should fail with INVALID_BODY, got " + string(content))
+			}
+		}
+	}
+}
+
+func TestServerStartNilWaitGroup(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		port := freePort(t)
+		address := "localhost:" + strconv.Itoa(port)
+		srv.Expose(port)
+
+		exited := make(chan interface{}, 1)
+		go func() {
+			defer func() { exited <- recover() }()
+			srv.Start(nil)
+		}()
+		for i := 0; i < 100; i++ {
+			if con, err := net.Dial("tcp", address); err == nil {
+				con.Close()
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		srv.Stop(ctx)
+		cancel()
+		select {
+		case recovered := <-exited:
+			if recovered != nil {
+				t.Errorf("%s server Start(nil) shouldn't panic when stopped, got %v", protocol, recovered)
+			}
+		case <-time.After(time.Second):
+			t.Error(protocol + " server Start should return once stopped")
+		}
+	}
+}
+
+type updateUserInput struct {
+	ID     int    `param:"id"`
+	Notify bool   `query:"notify"`
+	Page   *int   `query:"page"`
+	Name   string `json:"name"`
+}
+
+func TestRequestBindInput(t *testing.T) {
+	updateUser := func(req request.APIRequest, res responder.APIResponder) error {
+		var input updateUserInput
+		if err := req.BindInput(&input); err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		return res.Respond(common.NewOkResponse([]any{input}, "updated"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.PUT, "/users/:id", updateUser)
+		cli := client.NewAPIClient[updateUserInput](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{
+			Method:  "PUT",
+			Path:    "/users/42",
+			Params:  map[string]string{"notify": "true"},
+			Content: `{"name":"Ann"}`,
+		})
+		if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 {
+			t.Fatalf("%s binding should succeed, got %+v", protocol, resp)
+		}
+		if input := resp.Data[0]; input.ID != 42 || !input.Notify || input.Page != nil || input.Name != "Ann" {
+			t.Errorf("%s path, query and body should be bound, got %+v", protocol, input)
+		}
+
+		resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "PUT", Path: "/users/abc", Content: `{"name":"Ann"}`})
+		if resp.Status != common.APIStatus.Invalid || resp.ErrorCode != "INVALID_PARAM" {
+			t.Errorf("%s invalid path param should fail with INVALID_PARAM, got %+v", protocol, resp)
+		}
+	}
+}
+
+func TestServerRespondNoContent(t *testing.T) {
+	deleteItem := func(req request.APIRequest, res responder.APIResponder) error {
+		return res.RespondNoContent()
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.DELETE, "/items/:id", deleteItem)
+		address := startServer(t, srv)
+
+		if protocol == common.Protocol.HTTP {
+			req, _ := http.NewRequest(http.MethodDelete, "http://"+address+"/items/1", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent || len(content) != 0 {
+				t.Errorf("Expected a 204 with an empty body, got %d: %q", resp.StatusCode, content)
+			}
+		}
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "DELETE", Path: "/items/1"})
+		if resp.Status != common.APIStatus.Ok || len(resp.Data) != 0 {
+			t.Errorf("%s no content response should be OK without data, got %+v", protocol, resp)
+		}
+	}
+}
+
+func TestServerMultiStatus(t *testing.T) {
+	createItems := func(req request.APIRequest, res responder.APIResponder) error {
+		var names []string
+		if err := req.ParseBody(&names); err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		results := make([]common.ItemResult, len(names))
+		for i, name := range names {
+			if name == "" {
+				results[i] = common.NewItemError(common.NewError("INVALID_NAME", "name is required"))
+				continue
+			}
+			results[i] = common.NewItemResult(map[string]string{"name": name})
+		}
+		return res.Respond(common.NewMultiStatusResponse(results, "batch handled"))
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.POST, "/items/batch", createItems)
+		address := startServer(t, srv)
+
+		if protocol == common.Protocol.HTTP {
+			resp, err := http.Post("http://"+address+"/items/batch", "application/json", strings.NewReader(`["a",""]`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusMultiStatus {
+				t.Errorf("Expected a 207 for a partially failed batch, got %d", resp.StatusCode)
+			}
+		}
+
+		cli := client.NewAPIClient[common.ItemResult](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/items/batch", Content: `["a","","c"]`})
+		if resp.Status != common.APIStatus.PartialSuccess || resp.AsError() != nil || len(resp.Data) != 3 {
+			t.Fatalf("%s batch with a failing item should be a partial success, got %+v", protocol, resp)
+		}
+		if resp.Data[0].Status != common.APIStatus.Ok || resp.Data[2].Status != common.APIStatus.Ok {
+			t.Errorf("%s valid items should succeed, got %+v", protocol, resp.Data)
+		}
+		if resp.Data[1].Status != common.APIStatus.Invalid || resp.Data[1].ErrorCode != "INVALID_NAME" {
+			t.Errorf("%s empty item should fail with its own status, got %+v", protocol, resp.Data[1])
+		}
+
+		resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/items/batch", Content: `["a"]`})
+		if resp.Status != common.APIStatus.Ok {
+			t.Errorf("%s batch without failure should be OK, got %s", protocol, resp.Status)
+		}
+
+		// a batch where every item failed is a failure, not a partial success
+		resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/items/batch", Content: `["",""]`})
+		if resp.Status != common.APIStatus.Invalid || resp.ErrorCode != "INVALID_NAME" || resp.AsError() == nil || len(resp.Data) != 2 {
+			t.Errorf("%s batch where every item failed should fail with the items' status, got %+v", protocol, resp)
+		}
+	}
+
+	mixed := common.NewMultiStatusResponse([]common.ItemResult{
+		common.NewItemError(common.NewError("INVALID_NAME", "name is required")),
+		common.NewItemError(common.NotFoundError("no such item")),
+	}, "batch handled")
+	if mixed.Status != common.APIStatus.Error || mixed.ErrorCode != "" || mixed.AsError() == nil {
+		t.Errorf("Batch where items failed differently should be an ERROR, got %s %s", mixed.Status, mixed.ErrorCode)
+	}
+}
+
+func TestServerPreRequestState(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.PreRequest(func(req request.APIRequest, res responder.APIResponder) error {
+			req.SetAttribute("tenant", "acme")
+			req.SetVar("scope", "admin")
+			if req.GetHeader("X-User-Id") != "" {
+				req.SetVar("id", req.GetHeader("X-User-Id"))
+			}
+			return nil
+		})
+		srv.SetHandler(common.APIMethod.GET, "/users/:id", func(req request.APIRequest, res responder.APIResponder) error {
+			tenant, _ := req.GetAttribute("tenant").(string)
+			return res.Respond(common.NewOkResponse([]any{tenant, req.GetVar("scope"), req.GetVar("id")}, "user"))
+		})
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/users/42"})
+		if len(resp.Data) != 3 || resp.Data[0] != "acme" || resp.Data[1] != "admin" || resp.Data[2] != "42" {
+			t.Errorf("%s attribute and var set in PreRequest should reach the handler, got %v", protocol, resp.Data)
+		}
+
+		// a var set in PreRequest overrides the path parameter
+		resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/users/me", Headers: map[string]string{"X-User-Id": "7"}})
+		if len(resp.Data) != 3 || resp.Data[2] != "7" {
+			t.Errorf("%s var set in PreRequest should override the path parameter, got %v", protocol, resp.Data)
+		}
+	}
+}
+
+func TestServerPreRequestResponded(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.PreRequest(func(req request.APIRequest, res responder.APIResponder) error {
+			if req.GetHeader("X-Cached") != "" {
+				return res.Respond(common.NewOkResponse([]any{"cached"}, "from pre-request"))
+			}
+			return nil
+		})
+		var calls atomic.Int32
+		srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+			calls.Add(1)
+			return res.Respond(common.NewOkResponse([]any{"fresh"}, "from handler"))
+		})
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+
+		// the handler isn't called once the pre-request handler responded
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items", Headers: map[string]string{"X-Cached": "1"}})
+		if len(resp.Data) != 1 || resp.Data[0] != "cached" || calls.Load() != 0 {
+			t.Errorf("%s response of the pre-request handler should be sent without calling the handler, got %v (%d calls)", protocol, resp.Data, calls.Load())
+		}
+
+		resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"})
+		if len(resp.Data) != 1 || resp.Data[0] != "fresh" || calls.Load() != 1 {
+			t.Errorf("%s handler should respond when the pre-request handler didn't, got %v", protocol, resp.Data)
+		}
+	}
+}
+
+func TestServerSecurityHeaders(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.SetSecurityHeaders(server.SecurityHeadersConfig{
+		FrameOptions:          "SAMEORIGIN",
+		HSTSMaxAge:            24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		Disable:               []string{"referrer-policy"},
+	})
+	srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "items"))
+	})
+	address := startServer(t, srv)
+
+	// routed and not found responses both carry the headers
+	for _, path := range []string{"/items", "/missing"} {
+		resp, err := http.Get("http://" + address + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		expected := map[string]string{
+			server.ContentTypeOptionsHeader:      "nosniff",
+			server.FrameOptionsHeader:            "SAMEORIGIN",
+			server.StrictTransportSecurityHeader: "max-age=86400; includeSubDomains",
+			server.ReferrerPolicyHeader:          "",
+		}
+		for name, value := range expected {
+			if got := resp.Header.Get(name); got != value {
+				t.Errorf("%s: expected %s header %q, got %q", path, name, value, got)
+			}
+		}
+	}
+}
+
+func TestServerPreRequestRawBody(t *testing.T) {
+	key := []byte("secret")
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.HTTP,
+	})
+	srv.PreRequest(func(req request.APIRequest, res responder.APIResponder) error {
+		expected := client.ComputeHMACSignature(key, req.GetMethod().Value, req.GetPath(), req.GetHeader("X-Timestamp"), req.GetContentBytes())
+		if req.GetHeader("X-Signature") != expected {
+			err := common.UnauthorizedError("invalid signature")
+			res.Respond(common.FromError(err))
+			return err
+		}
+		return nil
+	})
+	srv.SetHandler(common.APIMethod.POST, "/orders", func(req request.APIRequest, res responder.APIResponder) error {
+		var order struct {
+			Item string `json:"item"`
+		}
+		if err := req.ParseBody(&order); err != nil {
+			return res.Respond(common.FromError(err))
+		}
+		return res.Respond(common.NewOkResponse([]any{order.Item}, "created"))
+	})
+	address := startServer(t, srv)
+
+	// the signature covers the compressed body, verified on the bytes as received
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:             address,
+		Timeout:             time.Second,
+		Protocol:            common.Protocol.HTTP,
+		CompressRequestBody: true,
+		RequestSigner:       client.NewHMACSigner(key),
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/orders", Content: `{"item":"book"}`})
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != "book" {
+		t.Fatalf("Signed body should be verified in PreRequest and parsed by the handler, got %s %s %v", resp.Status, resp.Message, resp.Data)
+	}
+
+	cli = client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:       address,
+		Timeout:       time.Second,
+		Protocol:      common.Protocol.HTTP,
+		RequestSigner: client.NewHMACSigner([]byte("other")),
+	})
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/orders", Content: `{"item":"book"}`})
+	if resp.Status != common.APIStatus.Unauthorized {
+		t.Errorf("Body signed with another key should be rejected, got %s", resp.Status)
+	}
+}
+
+func TestResponderCacheControl(t *testing.T) {
+	policies := map[string]func(res responder.APIResponder){
+		"/public":  func(res responder.APIResponder) { res.SetCacheControl(5*time.Minute, true) },
+		"/private": func(res responder.APIResponder) { res.SetCacheControl(90*time.Second, false) },
+		"/none":    func(res responder.APIResponder) { res.NoCache() },
+	}
+	expected := map[string]string{
+		"/public":  "public, max-age=300",
+		"/private": "private, max-age=90",
+		"/none":    "no-store, no-cache, must-revalidate",
+	}
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		for path, policy := range policies {
+			policy := policy
+			srv.SetHandler(common.APIMethod.GET, path, func(req request.APIRequest, res responder.APIResponder) error {
+				policy(res)
+				return res.Respond(common.NewOkResponse(nil, "cached"))
+			})
+		}
+		address := startServer(t, srv)
+
+		for path, value := range expected {
+			var header string
+			if protocol == common.Protocol.HTTP {
+				resp, err := http.Get("http://" + address + path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				header = resp.Header.Get("Cache-Control")
+			} else {
+				cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+					Address:       address,
+					Timeout:       time.Second,
+					MaxConnection: 1,
+					Protocol:      protocol,
+				})
+				header = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: path}).Headers["Cache-Control"]
+			}
+			if header != value {
+				t.Errorf("%s %s: expected Cache-Control %q, got %q", protocol, path, value, header)
+			}
+		}
+	}
+}
+
+func TestServerIdempotencyKey(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		var calls int32
+		createOrder := func(req request.APIRequest, res responder.APIResponder) error {
+			n := atomic.AddInt32(&calls, 1)
+			return res.Respond(&common.APIResponse[any]{
+				Status: common.APIStatus.Ok,
+				Data:   []any{map[string]int32{"order": n}},
+			})
+		}
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.POST, "/orders", server.Idempotent(server.NewMemoryIdempotencyStore(time.Minute), createOrder))
+		address := startServer(t, srv)
+
+		cli := client.NewAPIClient[map[string]int32](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxConnection: 1,
+			Protocol:      protocol,
+		})
+		post := func(key string) *common.APIResponse[map[string]int32] {
+			req := &request.OutboundAPIRequest{Method: "POST", Path: "/orders", Content: `{}`}
+			if key != "" {
+				req.Headers = map[string]string{server.IdempotencyKeyHeader: key}
+			}
+			return cli.MakeRequest(req)
+		}
+
+		first, second := post("order-1"), post("order-1")
+		if calls != 1 {
+			t.Fatalf("%s handler should run once for a repeated key, ran %d times", protocol, calls)
+		}
+		if second.Status != common.APIStatus.Ok || len(second.Data) != 1 || second.Data[0]["order"] != first.Data[0]["order"] {
+			t.Errorf("%s repeated key should replay the first response %+v, got %+v", protocol, first, second)
+		}
+
+		post("order-2")
+		post("")
+		if calls != 3 {
+			t.Errorf("%s new keys and requests without key should run the handler, ran %d times", protocol, calls)
+		}
+	}
+
+	srv := server.NewServer(server.ServerConfig{Protocol: common.Protocol.HTTP})
+	srv.SetHandler(common.APIMethod.POST, "/orders", server.Idempotent(server.NewMemoryIdempotencyStore(0),
+		func(req request.APIRequest, res responder.APIResponder) error {
+			res.SetHeader("X-Order-Version", "1")
+			res.SetCacheControl(time.Minute, false)
+			return res.Respond(&common.APIResponse[any]{
+				Status:  common.APIStatus.Ok,
+				Headers: map[string]string{"Location": "/orders/1"},
+			})
+		}))
+	address := startServer(t, srv)
+	for i, replayed := range []string{"", "true", "true"} {
+		req, _ := http.NewRequest(http.MethodPost, "http://"+address+"/orders", strings.NewReader(`{}`))
+		req.Header.Set(server.IdempotencyKeyHeader, "key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if header := resp.Header.Get(server.IdempotentReplayedHeader); header != replayed {
+			t.Errorf("request %d: expected %s %q, got %q", i, server.IdempotentReplayedHeader, replayed, header)
+		}
+		// the headers of the response and the ones set on the responder are replayed
+		for name, value := range map[string]string{"Location": "/orders/1", "X-Order-Version": "1", "Cache-Control": "private, max-age=60"} {
+			if header := resp.Header.Get(name); header != value {
+				t.Errorf("request %d: expected %s %q, got %q", i, name, value, header)
+			}
+		}
+	}
+}
+
+func TestServerIdempotencyKeyConcurrent(t *testing.T) {
+	for _, protocol := range []string{common.Protocol.HTTP, common.Protocol.THRIFT} {
+		var calls int32
+		srv := server.NewServer(server.ServerConfig{
+			Protocol: protocol,
+		})
+		srv.SetHandler(common.APIMethod.POST, "/orders", server.Idempotent(server.NewMemoryIdempotencyStore(time.Minute),
+			func(req request.APIRequest, res responder.APIResponder) error {
+				atomic.AddInt32(&calls, 1)
+				return res.Respond(&common.APIResponse[any]{Status: common.APIStatus.Ok})
+			}))
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       startServer(t, srv),
+			Timeout:       time.Second,
+			MaxConnection: 20,
+			Protocol:      protocol,
+		})
+
+		var wg sync.WaitGroup
+		statuses := make(chan string, 50)
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				statuses <- cli.MakeRequest(&request.OutboundAPIRequest{
+					Method:  "POST",
+					Path:    "/orders",
+					Content: `{}`,
+					Headers: map[string]string{server.IdempotencyKeyHeader: "order-1"},
+				}).Status
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+
+		if calls != 1 {
+			t.Errorf("%s handler should run once for concurrent requests with the same key, ran %d times", protocol, calls)
+		}
+		for status := range statuses {
+			// duplicates either get the replay or are told the key is in use
+			if status != common.APIStatus.Ok && status != common.APIStatus.Existed {
+				t.Errorf("%s unexpected status %s for a duplicate request", protocol, status)
+			}
+		}
+	}
+}
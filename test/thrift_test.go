@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/apache/thrift/lib/go/thrift"
+
 	"github.com/phnam/go-protocol-adapter/client"
 	"github.com/phnam/go-protocol-adapter/common"
 	"github.com/phnam/go-protocol-adapter/request"
 	"github.com/phnam/go-protocol-adapter/responder"
 	"github.com/phnam/go-protocol-adapter/server"
+	"github.com/phnam/go-protocol-adapter/thriftapi"
 )
 
 func TestThriftServer(t *testing.T) {
@@ -61,3 +74,1096 @@ func TestThriftServer(t *testing.T) {
 	}
 
 }
+
+func TestThriftServerRequestID(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/id", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse([]any{req.GetRequestID()}, "ok"))
+	})
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{
+		Method:  "GET",
+		Path:    "/id",
+		Headers: map[string]string{"X-Request-Id": "incoming-id"},
+	})
+	if len(resp.Data) != 1 || resp.Data[0] != "incoming-id" || resp.Headers["X-Request-Id"] != "incoming-id" {
+		t.Error("Incoming request id wasn't preserved: " + resp.Headers["X-Request-Id"])
+	}
+
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/id"})
+	if len(resp.Data) != 1 || resp.Data[0] == "" || resp.Headers["X-Request-Id"] != resp.Data[0] {
+		t.Error("Request id wasn't generated: " + resp.Headers["X-Request-Id"])
+	}
+}
+
+func TestThriftServerRequestCancellation(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	canceled := make(chan bool, 1)
+	srv.SetHandler(common.APIMethod.GET, "/wait", func(req request.APIRequest, res responder.APIResponder) error {
+		select {
+		case <-req.Context().Done():
+			canceled <- true
+		case <-time.After(2 * time.Second):
+			canceled <- false
+		}
+		return res.Respond(common.NewOkResponse(nil, "late"))
+	})
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       100 * time.Millisecond,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	// the client times out and closes its connection
+	cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/wait"})
+
+	if !<-canceled {
+		t.Error("Handler context wasn't canceled when the client disconnected")
+	}
+}
+
+func TestThriftServerTransports(t *testing.T) {
+	for _, transport := range []string{common.ThriftTransport.BUFFERED, common.ThriftTransport.PLAIN} {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:        common.Protocol.THRIFT,
+			ThriftTransport: transport,
+			BufferSize:      1024 * 24,
+			MessageSize:     1024 * 4,
+		})
+		srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+			return res.Respond(common.NewOkResponse([]any{transport}, "ok"))
+		})
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:         startServer(t, srv),
+			Timeout:         time.Second,
+			MaxConnection:   1,
+			Protocol:        common.Protocol.THRIFT,
+			ThriftTransport: transport,
+		})
+
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != transport {
+			t.Error(transport + " transport round-trip failed: " + resp.Status + " " + resp.Message)
+		}
+	}
+}
+
+func TestThriftClientSingleConnection(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	proxy := startProxy(t, startServer(t, srv))
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:          proxy.address,
+		Timeout:          time.Second,
+		MaxRetry:         1,
+		MaxConnection:    10,
+		Protocol:         common.Protocol.THRIFT,
+		SingleConnection: true,
+	})
+
+	for i := 0; i < 5; i++ {
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		if resp.Status != common.APIStatus.Ok {
+			t.Fatal("Call " + strconv.Itoa(i) + " failed: " + resp.Message)
+		}
+	}
+	if proxy.count() != 1 {
+		t.Error("Expected a single connection, got " + strconv.Itoa(proxy.count()))
+	}
+
+	// a broken connection is replaced on the next attempt
+	proxy.dropAll()
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Error("Client didn't reconnect: " + resp.Message)
+	}
+	if proxy.count() != 2 {
+		t.Error("Expected one reconnection, got " + strconv.Itoa(proxy.count()) + " connections")
+	}
+}
+
+// exceptionProcessor answers every Thrift call with an application exception of the given type.
+type exceptionProcessor struct {
+	typeID int32
+}
+
+func (p *exceptionProcessor) Process(ctx context.Context, in, out thrift.TProtocol) (bool, thrift.TException) {
+	name, _, seqID, err := in.ReadMessageBegin(ctx)
+	if err != nil {
+		return false, thrift.WrapTException(err)
+	}
+	in.Skip(ctx, thrift.STRUCT)
+	in.ReadMessageEnd(ctx)
+
+	exception := thrift.NewTApplicationException(p.typeID, "simulated exception")
+	out.WriteMessageBegin(ctx, name, thrift.EXCEPTION, seqID)
+	exception.Write(ctx, out)
+	out.WriteMessageEnd(ctx)
+	out.Flush(ctx)
+	return true, nil
+}
+
+func (p *exceptionProcessor) ProcessorMap() map[string]thrift.TProcessorFunction {
+	return nil
+}
+
+func (p *exceptionProcessor) AddToProcessorMap(string, thrift.TProcessorFunction) {}
+
+func TestThriftClientApplicationExceptions(t *testing.T) {
+	cases := []struct {
+		typeID    int32
+		status    string
+		errorCode string
+	}{
+		{thrift.UNKNOWN_METHOD, common.APIStatus.NotFound, "UNKNOWN_METHOD"},
+		{thrift.INTERNAL_ERROR, common.APIStatus.Error, "INTERNAL_ERROR"},
+		{thrift.PROTOCOL_ERROR, common.APIStatus.Invalid, "PROTOCOL_ERROR"},
+		{thrift.UNKNOWN_APPLICATION_EXCEPTION, common.APIStatus.Error, "UNKNOWN_APPLICATION_EXCEPTION"},
+	}
+
+	for _, c := range cases {
+		address := "localhost:" + strconv.Itoa(freePort(t))
+		socket, err := thrift.NewTServerSocket(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := thrift.NewTSimpleServer4(
+			&exceptionProcessor{typeID: c.typeID},
+			socket,
+			thrift.NewTFramedTransportFactoryConf(thrift.NewTBufferedTransportFactory(8192), nil),
+			thrift.NewTBinaryProtocolFactoryConf(nil),
+		)
+		if err := srv.Listen(); err != nil {
+			t.Fatal(err)
+		}
+		go srv.Serve()
+
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:       address,
+			Timeout:       time.Second,
+			MaxRetry:      2,
+			WaitToRetry:   time.Second,
+			MaxConnection: 1,
+			Protocol:      common.Protocol.THRIFT,
+		})
+
+		start := time.Now()
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		if resp.Status != c.status || resp.ErrorCode != c.errorCode {
+			t.Error("Exception " + strconv.Itoa(int(c.typeID)) + " mapped to " + resp.Status + "/" + resp.ErrorCode)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Error("Application exception " + strconv.Itoa(int(c.typeID)) + " was retried")
+		}
+		go srv.Stop()
+	}
+
+	// transport failures keep the generic endpoint error
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       "localhost:" + strconv.Itoa(freePort(t)),
+		Timeout:       100 * time.Millisecond,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Error || resp.ErrorCode != "" {
+		t.Error("Transport error mapped to " + resp.Status + "/" + resp.ErrorCode)
+	}
+}
+
+func TestThriftClientConnAcquireTimeout(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/slow", func(req request.APIRequest, res responder.APIResponder) error {
+		time.Sleep(500 * time.Millisecond)
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:            startServer(t, srv),
+		Timeout:            time.Second,
+		MaxConnection:      1,
+		Protocol:           common.Protocol.THRIFT,
+		ConnAcquireTimeout: 100 * time.Millisecond,
+		ConnAcquireRetries: 5,
+	})
+
+	first := make(chan *common.APIResponse[any], 1)
+	go func() {
+		first <- cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"})
+	}()
+	// let the first call take the only connection
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"})
+	elapsed := time.Since(start)
+	if resp.Status != common.APIStatus.Error || !strings.Contains(resp.Message, "overloaded") {
+		t.Error("Expected an overload error, got " + resp.Status + ": " + resp.Message)
+	}
+	if elapsed < 100*time.Millisecond || elapsed > 300*time.Millisecond {
+		t.Error("Connection acquisition didn't respect the timeout: " + elapsed.String())
+	}
+
+	if r := <-first; r.Status != common.APIStatus.Ok {
+		t.Error("First call failed: " + r.Message)
+	}
+}
+
+// echoPathProcessor is a custom Thrift method replying with the requested path.
+type echoPathProcessor struct{}
+
+func (p *echoPathProcessor) Process(ctx context.Context, seqID int32, in, out thrift.TProtocol) (bool, thrift.TException) {
+	args := thriftapi.NewAPIServiceCallArgs()
+	if err := args.Read(ctx, in); err != nil {
+		return false, thrift.WrapTException(err)
+	}
+	in.ReadMessageEnd(ctx)
+
+	result := thriftapi.NewAPIServiceCallResult()
+	result.Success = &thriftapi.APIResponse{Status: thriftapi.Status_OK, Message: "echo " + args.Request.GetPath()}
+	out.WriteMessageBegin(ctx, "echo", thrift.REPLY, seqID)
+	result.Write(ctx, out)
+	out.WriteMessageEnd(ctx)
+	out.Flush(ctx)
+	return true, nil
+}
+
+func TestThriftServerProcessorFunction(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "call"))
+	})
+	srv.(*server.ThriftServer).AddProcessorFunction("echo", &echoPathProcessor{})
+	address := startServer(t, srv)
+
+	transport, _ := thrift.NewTFramedTransportFactory(thrift.NewTBufferedTransportFactory(8192)).
+		GetTransport(thrift.NewTSocketConf(address, &thrift.TConfiguration{SocketTimeout: time.Second}))
+	if err := transport.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	protocolFactory := thrift.NewTBinaryProtocolFactoryConf(nil)
+	rawClient := thrift.NewTStandardClient(protocolFactory.GetProtocol(transport), protocolFactory.GetProtocol(transport))
+
+	// the custom method is served
+	args := thriftapi.NewAPIServiceCallArgs()
+	args.Request = &thriftapi.APIRequest{Method: "GET", Path: "/custom"}
+	result := thriftapi.NewAPIServiceCallResult()
+	if _, err := rawClient.Call(context.Background(), "echo", args, result); err != nil {
+		t.Fatal("Custom method failed: " + err.Error())
+	}
+	if result.GetSuccess().GetMessage() != "echo /custom" {
+		t.Error("Wrong custom method result: " + result.GetSuccess().GetMessage())
+	}
+
+	// the default method still works on the same connection
+	resp, err := thriftapi.NewAPIServiceClient(rawClient).Call(context.Background(), &thriftapi.APIRequest{Method: "GET", Path: "/"})
+	if err != nil || resp.GetMessage() != "call" {
+		t.Error("Default call method should still be served")
+	}
+}
+
+func TestThriftServerRespondRaw(t *testing.T) {
+	// PNG signature followed by bytes that aren't valid UTF-8
+	png := []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0x00, 0x00, 0x00, 0x0d, 'I', 'H', 'D', 'R', 0xff, 0xfe, 0x80}
+
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/image", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.RespondRaw("image/png", png)
+	})
+	cli := client.NewAPIClient[[]byte](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/image"})
+	if resp.Status != common.APIStatus.Ok || resp.Headers["Content-Type"] != "image/png" {
+		t.Fatal("Raw response failed: " + resp.Status + " " + resp.Headers["Content-Type"])
+	}
+	if len(resp.Data) != 1 || !bytes.Equal(resp.Data[0], png) {
+		t.Error("PNG bytes were mangled")
+	}
+}
+
+// unknownStatusHandler answers every call with a status value missing from the Thrift enum.
+type unknownStatusHandler struct{}
+
+func (h *unknownStatusHandler) Call(ctx context.Context, req *thriftapi.APIRequest) (*thriftapi.APIResponse, error) {
+	return &thriftapi.APIResponse{Status: thriftapi.Status(99), Message: "unknown status"}, nil
+}
+
+func TestThriftClientUnknownStatus(t *testing.T) {
+	address := "localhost:" + strconv.Itoa(freePort(t))
+	socket, err := thrift.NewTServerSocket(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := thrift.NewTSimpleServer4(
+		thriftapi.NewAPIServiceProcessor(&unknownStatusHandler{}),
+		socket,
+		thrift.NewTFramedTransportFactoryConf(thrift.NewTBufferedTransportFactory(8192), nil),
+		thrift.NewTBinaryProtocolFactoryConf(nil),
+	)
+	if err := srv.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	defer func() { go srv.Stop() }()
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       address,
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Error {
+		t.Error("Unknown status should be mapped to " + common.APIStatus.Error + ", got " + resp.Status)
+	}
+	if resp.Message != "unknown status" {
+		t.Error("Message should be kept, got " + resp.Message)
+	}
+}
+
+func TestThriftServerPreRequestHeaders(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.PreRequest(func(req request.APIRequest, res responder.APIResponder) error {
+		res.SetHeader("X-Tenant", "acme")
+		return nil
+	})
+	srv.SetHandler(common.APIMethod.GET, "/users/:id", func(req request.APIRequest, res responder.APIResponder) error {
+		res.SetHeader("X-User", req.GetVar("id"))
+		return res.Respond(common.NewOkResponse(nil, "user"))
+	})
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/users/42"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Fatal("Request failed: " + resp.Message)
+	}
+	if resp.Headers["X-Tenant"] != "acme" {
+		t.Error("Header set in PreRequest should be kept, got " + resp.Headers["X-Tenant"])
+	}
+	if resp.Headers["X-User"] != "42" || resp.Headers[request.RequestIDHeader] == "" {
+		t.Error("Handler and request ID headers should be kept")
+	}
+}
+
+func TestThriftClientPing(t *testing.T) {
+	downAddress := "localhost:" + strconv.Itoa(freePort(t))
+	down := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:  downAddress,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.THRIFT,
+	})
+	if down.Ping() == nil {
+		t.Error("Ping should fail when the server is down")
+	}
+
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	up := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Addresses: []string{downAddress, startServer(t, srv)},
+		Timeout:   time.Second,
+		Protocol:  common.Protocol.THRIFT,
+	})
+	if err := up.Ping(); err != nil {
+		t.Error("Ping should succeed when a server is up: " + err.Error())
+	}
+
+	// the result is cached briefly, so probes don't hammer the backend
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:  listener.Addr().String(),
+		Timeout:  time.Second,
+		Protocol: common.Protocol.THRIFT,
+	})
+	cached.Ping()
+	listener.Close()
+	if err := cached.Ping(); err != nil {
+		t.Error("Ping result should be cached: " + err.Error())
+	}
+}
+
+func TestThriftServerMethodName(t *testing.T) {
+	users := server.NewServer(server.ServerConfig{
+		Protocol:         common.Protocol.THRIFT,
+		ThriftMethodName: "users",
+	})
+	users.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "users service"))
+	})
+	orders := server.NewServer(server.ServerConfig{
+		Protocol:         common.Protocol.THRIFT,
+		ThriftMethodName: "orders",
+	})
+	orders.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "orders service"))
+	})
+
+	// serve both services on one processor
+	proc := users.(*server.ThriftServer).Processor()
+	for name, fn := range orders.(*server.ThriftServer).Processor().ProcessorMap() {
+		proc.AddToProcessorMap(name, fn)
+	}
+	address := "localhost:" + strconv.Itoa(freePort(t))
+	socket, err := thrift.NewTServerSocket(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := thrift.NewTSimpleServer4(
+		proc,
+		socket,
+		thrift.NewTFramedTransportFactoryConf(thrift.NewTBufferedTransportFactory(8192), nil),
+		thrift.NewTBinaryProtocolFactoryConf(nil),
+	)
+	if err := srv.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve()
+	defer func() { go srv.Stop() }()
+
+	for method, expected := range map[string]string{"users": "users service", "orders": "orders service"} {
+		cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+			Address:          address,
+			Timeout:          time.Second,
+			MaxConnection:    1,
+			Protocol:         common.Protocol.THRIFT,
+			ThriftMethodName: method,
+		})
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		if resp.Status != common.APIStatus.Ok || resp.Message != expected {
+			t.Error("Method " + method + " should reach its service, got " + resp.Status + " " + resp.Message)
+		}
+	}
+
+	// the default method name isn't served anymore
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       address,
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"}); resp.ErrorCode != "UNKNOWN_METHOD" {
+		t.Error("Default method should be unknown, got " + resp.Status + " " + resp.ErrorCode)
+	}
+}
+
+func TestThriftClientDebugConnID(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, req.GetHeader(client.ConnIDHeader)))
+	})
+	cli := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+	cli.SetDebug(true)
+
+	// capture the debug log
+	stdout := os.Stdout
+	reader, writer, _ := os.Pipe()
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		content, _ := io.ReadAll(reader)
+		output <- string(content)
+	}()
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	os.Stdout = stdout
+	writer.Close()
+	log := <-output
+
+	connID := resp.Message
+	if connID == "" || connID == "temporary" {
+		t.Fatal("Request should carry the pooled connection id, got \"" + connID + "\"")
+	}
+	if !strings.Contains(log, "on connection "+connID) {
+		t.Error("Debug log should include the connection id " + connID + ", got " + log)
+	}
+}
+
+func TestThriftClientConnHealthCheck(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	proxy := startProxy(t, startServer(t, srv))
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:                proxy.address,
+		Timeout:                2 * time.Second,
+		MaxConnection:          1,
+		Protocol:               common.Protocol.THRIFT,
+		ConnHealthCheckPath:    "/health",
+		ConnHealthCheckTimeout: 50 * time.Millisecond,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Fatal("First call failed: " + resp.Message)
+	}
+
+	// the pooled connection stays open but nothing goes through anymore
+	proxy.silenceAll()
+	start := time.Now()
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Fatal("Call over a silently dropped connection should use a new one: " + resp.Message)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("Dead connection should be detected by the health check, took " + elapsed.String())
+	}
+	if proxy.count() != 2 {
+		t.Error("Expected one new connection, got " + strconv.Itoa(proxy.count()) + " connections")
+	}
+}
+
+func TestThriftClientConcurrentConnIDs(t *testing.T) {
+	const calls = 30
+	var lock sync.Mutex
+	ids := map[string]int{}
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/", func(req request.APIRequest, res responder.APIResponder) error {
+		lock.Lock()
+		ids[req.GetHeader(client.ConnIDHeader)]++
+		lock.Unlock()
+		// keep the connection busy so every call needs its own
+		time.Sleep(100 * time.Millisecond)
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	cli := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: calls,
+		Protocol:      common.Protocol.THRIFT,
+	})
+	cli.SetDebug(true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/"})
+		}()
+	}
+	wg.Wait()
+
+	if len(ids) != calls {
+		t.Fatal("Expected " + strconv.Itoa(calls) + " distinct connection ids, got " + strconv.Itoa(len(ids)))
+	}
+	// ids are allocated from a counter, so they are exactly 1..calls
+	for i := 1; i <= calls; i++ {
+		if ids[strconv.Itoa(i)] != 1 {
+			t.Error("Connection id " + strconv.Itoa(i) + " should be used once, got " + strconv.Itoa(ids[strconv.Itoa(i)]))
+		}
+	}
+}
+
+func TestThriftClientPoolWaitStats(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/slow", func(req request.APIRequest, res responder.APIResponder) error {
+		time.Sleep(200 * time.Millisecond)
+		return res.Respond(common.NewOkResponse(nil, "ok"))
+	})
+	cli := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:            startServer(t, srv),
+		Timeout:            time.Second,
+		MaxConnection:      1,
+		Protocol:           common.Protocol.THRIFT,
+		ConnAcquireTimeout: time.Second,
+		ConnAcquireRetries: 100,
+	})
+
+	first := make(chan *common.APIResponse[any], 1)
+	go func() {
+		first <- cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"})
+	}()
+	// let the first call take the only connection, so the second one waits for it
+	time.Sleep(50 * time.Millisecond)
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/slow"}); resp.Status != common.APIStatus.Ok {
+		t.Error("Second call should get the connection once freed: " + resp.Message)
+	}
+	<-first
+
+	stats := cli.Stats()
+	if stats.Acquisitions != 2 || stats.Waits != 1 || stats.Timeouts != 0 {
+		t.Errorf("Expected 2 acquisitions with 1 wait, got %+v", stats)
+	}
+	if stats.MaxWait < 100*time.Millisecond || stats.AverageWait <= 0 || stats.AverageWait > stats.MaxWait {
+		t.Errorf("Wait of the saturated pool should be recorded, got %+v", stats)
+	}
+	if stats.OpenConnections != 1 || stats.InUseConnections != 0 {
+		t.Errorf("Expected 1 idle pooled connection, got %+v", stats)
+	}
+
+	cli.ResetStats()
+	if stats := cli.Stats(); stats.Acquisitions != 0 || stats.MaxWait != 0 {
+		t.Errorf("Stats should be cleared after reset, got %+v", stats)
+	}
+}
+
+// staleProxy forwards TCP connections to a backend. Connections marked stale are closed
+// after a delay when they're next used, like pooled connections dropped by a firewall.
+type staleProxy struct {
+	listener net.Listener
+	backend  string
+	delay    time.Duration
+	lock     sync.Mutex
+	conns    map[net.Conn]bool
+	accepted atomic.Int64
+}
+
+func newStaleProxy(t *testing.T, backend string, delay time.Duration) *staleProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	proxy := &staleProxy{listener: listener, backend: backend, delay: delay, conns: map[net.Conn]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			proxy.accepted.Add(1)
+			go proxy.forward(conn)
+		}
+	}()
+	return proxy
+}
+
+func (proxy *staleProxy) forward(conn net.Conn) {
+	backend, err := net.Dial("tcp", proxy.backend)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	proxy.lock.Lock()
+	proxy.conns[conn] = false
+	proxy.lock.Unlock()
+	go func() {
+		io.Copy(conn, backend)
+		conn.Close()
+	}()
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		proxy.lock.Lock()
+		stale := proxy.conns[conn]
+		proxy.lock.Unlock()
+		if stale {
+			time.Sleep(proxy.delay)
+			break
+		}
+		if _, err := backend.Write(buf[:n]); err != nil {
+			break
+		}
+	}
+	conn.Close()
+	backend.Close()
+}
+
+// markStale marks the current connections as stale.
+func (proxy *staleProxy) markStale() {
+	proxy.lock.Lock()
+	defer proxy.lock.Unlock()
+	for conn := range proxy.conns {
+		proxy.conns[conn] = true
+	}
+}
+
+func TestThriftClientStaleConnectionRetry(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/ping", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "pong"))
+	})
+	// the stale connection fails well after the first attempt started,
+	// so the immediate reconnect can't depend on how fast the failure is
+	proxy := newStaleProxy(t, startServer(t, srv), 50*time.Millisecond)
+
+	cli := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:       proxy.listener.Addr().String(),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+		MaxRetry:      0,
+	})
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/ping"}); resp.Status != common.APIStatus.Ok {
+		t.Fatal("First call should succeed: " + resp.Message)
+	}
+
+	proxy.markStale()
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/ping"}); resp.Status != common.APIStatus.Ok {
+		t.Error("Call over a stale connection should be retried on a new one without MaxRetry: " + resp.Message)
+	}
+	if accepted := proxy.accepted.Load(); accepted != 2 {
+		t.Errorf("Expected the retry to open a second connection, got %d connections", accepted)
+	}
+}
+
+func TestThriftClientRetryBackoff(t *testing.T) {
+	var calls atomic.Int64
+	var last atomic.Int64
+	var gaps []time.Duration
+	var lock sync.Mutex
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/busy", func(req request.APIRequest, res responder.APIResponder) error {
+		now := time.Now().UnixNano()
+		if previous := last.Swap(now); previous != 0 {
+			lock.Lock()
+			gaps = append(gaps, time.Duration(now-previous))
+			lock.Unlock()
+		}
+		calls.Add(1)
+		return res.Respond(common.NewErrorResponse(common.APIStatus.Error, "OVERLOADED", "busy"))
+	})
+
+	cli := client.NewThriftClient[any](&client.APIClientConfiguration{
+		Address:            startServer(t, srv),
+		Timeout:            time.Second,
+		MaxConnection:      1,
+		Protocol:           common.Protocol.THRIFT,
+		MaxRetry:           3,
+		WaitToRetry:        20 * time.Millisecond,
+		RetryBackoffFactor: 2,
+		RetryOnErrorCodes:  []string{"OVERLOADED"},
+	})
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/busy"}); resp.ErrorCode != "OVERLOADED" {
+		t.Fatalf("Expected the OVERLOADED error after the retries, got %+v", resp)
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("Expected 1 call and 3 retries, got %d calls", calls.Load())
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for i, minimum := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond} {
+		if gaps[i] < minimum {
+			t.Errorf("Retry %d should wait at least %v, waited %v", i+1, minimum, gaps[i])
+		}
+	}
+}
+
+func TestThriftServerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:   common.Protocol.THRIFT,
+		UnixSocket: socket,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse([]any{req.GetPath()}, "ok"))
+	})
+	go srv.Start(nil)
+	t.Cleanup(func() { srv.Stop(context.Background()) })
+
+	// wait for the server to listen
+	for i := 0; ; i++ {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 50 {
+			t.Fatal("Thrift server isn't listening on the Unix socket: " + err.Error())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:       "unix://" + socket,
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+	if err := cli.(*client.ThriftClient[string]).Ping(); err != nil {
+		t.Fatal("Unix socket endpoint should be reachable: " + err.Error())
+	}
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"})
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != "/items" {
+		t.Fatalf("Expected the call to go through the Unix socket, got %s %s", resp.Status, resp.Message)
+	}
+}
+
+func TestThriftServerUnixSocketPath(t *testing.T) {
+	start := func(socket string) (err interface{}) {
+		srv := server.NewServer(server.ServerConfig{
+			Protocol:   common.Protocol.THRIFT,
+			UnixSocket: socket,
+		})
+		done := make(chan interface{}, 1)
+		go func() {
+			defer func() { done <- recover() }()
+			srv.Start(nil)
+		}()
+		select {
+		case err = <-done:
+		case <-time.After(200 * time.Millisecond):
+			// still serving
+		}
+		srv.Stop(context.Background())
+		return err
+	}
+
+	// a file that isn't a socket is kept, and the server doesn't start
+	file := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(file, []byte(`{"keep":true}`), 0o600)
+	if err := start(file); err == nil || !strings.Contains(fmt.Sprint(err), "isn't a socket") {
+		t.Errorf("Start should fail for a path that isn't a socket, got %v", err)
+	}
+	if content, err := os.ReadFile(file); err != nil || string(content) != `{"keep":true}` {
+		t.Error("A file that isn't a socket shouldn't be removed")
+	}
+
+	// a stale socket file left by a previous run is replaced
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.SetUnlinkOnClose(false)
+	listener.Close()
+	if err := start(socket); err != nil {
+		t.Errorf("Start should replace a stale socket file, got %v", err)
+	}
+}
+
+func TestThriftContentCompression(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:    common.Protocol.THRIFT,
+		MessageSize: 10 << 20,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/report", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse([]any{strings.Repeat("compressible line\n", 20000)}, "report"))
+	})
+	proxy := startProxy(t, startServer(t, srv))
+
+	call := func(disable bool, maxSize int64) (int64, *common.APIResponse[string]) {
+		cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+			Address:                    proxy.address,
+			Timeout:                    time.Second,
+			MaxConnection:              1,
+			Protocol:                   common.Protocol.THRIFT,
+			DisableResponseCompression: disable,
+			MaxResponseBodySize:        maxSize,
+		})
+		before := proxy.bytesReceived()
+		resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/report"})
+		return proxy.bytesReceived() - before, resp
+	}
+
+	plainSize, resp := call(true, 0)
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || len(resp.Data[0]) != 18*20000 {
+		t.Fatalf("Uncompressed call failed: %s %s", resp.Status, resp.Message)
+	}
+	compressedSize, resp := call(false, 0)
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 1 || resp.Data[0] != strings.Repeat("compressible line\n", 20000) {
+		t.Fatalf("Compressed content should be decoded by the client: %s %s", resp.Status, resp.Message)
+	}
+	if resp.Headers["Content-Encoding"] != "" {
+		t.Error("Content-Encoding header should be removed once the content is decoded")
+	}
+	if compressedSize*10 > plainSize {
+		t.Errorf("Compressed frame should be much smaller, got %d bytes against %d", compressedSize, plainSize)
+	}
+
+	// the content is decoded up to the maximum size only
+	_, resp = call(false, 64<<10)
+	if resp.Status != common.APIStatus.Error || resp.ErrorCode != "RESPONSE_TOO_LARGE" || len(resp.Data) != 0 {
+		t.Errorf("Content over the maximum size once decoded should fail with RESPONSE_TOO_LARGE, got %s %s", resp.Status, resp.ErrorCode)
+	}
+}
+
+func TestThriftServerNotFoundHandler(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse(nil, "items"))
+	})
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	// the default response has the shape of the HTTP one
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/missing"})
+	if resp.Status != common.APIStatus.NotFound || resp.ErrorCode != "NOT_FOUND" || resp.Message != "[SDK] Route not found for GET /missing" {
+		t.Errorf("Unmatched path should get the standard NOT_FOUND response, got %s %s %s", resp.Status, resp.ErrorCode, resp.Message)
+	}
+	if resp.Headers[request.RequestIDHeader] == "" {
+		t.Error("NOT_FOUND response should carry the request ID")
+	}
+
+	srv.(*server.ThriftServer).SetNotFoundHandler(func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewErrorResponse(common.APIStatus.NotFound, "NO_SUCH_PAGE", "Nothing at "+req.GetPath()))
+	})
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/missing"})
+	if resp.Status != common.APIStatus.NotFound || resp.ErrorCode != "NO_SUCH_PAGE" || resp.Message != "Nothing at /missing" {
+		t.Errorf("Custom not found handler wasn't used, got %s %s %s", resp.Status, resp.ErrorCode, resp.Message)
+	}
+}
+
+func TestThriftServerHeaderSizeLimit(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:            common.Protocol.THRIFT,
+		MaxThriftHeaderSize: 4096,
+	})
+	srv.SetHandler(common.APIMethod.GET, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+		count, _ := strconv.Atoi(req.GetParam("headers"))
+		for i := 0; i < count; i++ {
+			res.SetHeader("X-Debug-"+strconv.Itoa(i), strings.Repeat("x", 1000))
+		}
+		return res.Respond(common.NewOkResponse([]any{"item"}, "items"))
+	})
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items", Params: map[string]string{"headers": "2"}})
+	if resp.Status != common.APIStatus.Ok || resp.Headers["X-Debug-1"] == "" {
+		t.Fatalf("Headers within the limit should be sent, got %s %s", resp.Status, resp.Message)
+	}
+
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items", Params: map[string]string{"headers": "10"}})
+	if resp.Status != common.APIStatus.Error || resp.ErrorCode != "HEADERS_TOO_LARGE" {
+		t.Fatalf("Oversized headers should be reported as HEADERS_TOO_LARGE, got %s %s %s", resp.Status, resp.ErrorCode, resp.Message)
+	}
+	if resp.Headers["X-Debug-0"] != "" || resp.Headers[request.RequestIDHeader] == "" || resp.Headers["X-Hostname"] == "" {
+		t.Error("Only the adapter headers should be kept on the error response")
+	}
+
+	// the connection stays usable
+	resp = cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/items"})
+	if resp.Status != common.APIStatus.Ok {
+		t.Errorf("Request after an oversized response should succeed, got %s %s", resp.Status, resp.Message)
+	}
+}
+
+func TestThriftClientRequestMapper(t *testing.T) {
+	srv := server.NewServer(server.ServerConfig{
+		Protocol: common.Protocol.THRIFT,
+	})
+	srv.SetHandler(common.APIMethod.POST, "/items", func(req request.APIRequest, res responder.APIResponder) error {
+		return res.Respond(common.NewOkResponse([]any{
+			req.GetHeader("X-Payload"), req.GetContentText(), req.GetParam("tenant"),
+		}, "mapped"))
+	})
+	cli := client.NewAPIClient[string](&client.APIClientConfiguration{
+		Address:       startServer(t, srv),
+		Timeout:       time.Second,
+		MaxConnection: 1,
+		Protocol:      common.Protocol.THRIFT,
+		RequestMapper: func(req request.APIRequest) *thriftapi.APIRequest {
+			r := client.DefaultThriftRequestMapper(req)
+			r.Headers = map[string]string{"X-Payload": r.Content}
+			r.Content = ""
+			r.Params = map[string]string{"tenant": "default"}
+			return r
+		},
+	})
+
+	resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "POST", Path: "/items", Content: `{"name":"a"}`})
+	if resp.Status != common.APIStatus.Ok || len(resp.Data) != 3 {
+		t.Fatalf("Mapped request should succeed, got %+v", resp)
+	}
+	if resp.Data[0] != `{"name":"a"}` || resp.Data[1] != "" || resp.Data[2] != "default" {
+		t.Errorf("Request should be sent as mapped, got %q", resp.Data)
+	}
+}
+
+func TestThriftServerTimeoutStopDrain(t *testing.T) {
+	var finished atomic.Bool
+	srv := server.NewServer(server.ServerConfig{
+		Protocol:       common.Protocol.THRIFT,
+		HandlerTimeout: 50 * time.Millisecond,
+	})
+	// the handler ignores the context, setting headers after the timeout response was sent
+	srv.SetHandler(common.APIMethod.GET, "/stubborn", func(req request.APIRequest, res responder.APIResponder) error {
+		for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); {
+			res.SetHeader("X-Progress", time.Now().String())
+			time.Sleep(time.Millisecond)
+		}
+		finished.Store(true)
+		return res.Respond(common.NewOkResponse(nil, "done"))
+	})
+	address := startServer(t, srv)
+
+	cli := client.NewAPIClient[any](&client.APIClientConfiguration{
+		Address:  address,
+		Timeout:  time.Second,
+		Protocol: common.Protocol.THRIFT,
+	})
+	if resp := cli.MakeRequest(&request.OutboundAPIRequest{Method: "GET", Path: "/stubborn"}); resp.Status != common.APIStatus.Timeout {
+		t.Fatal("Timed-out handler should answer TIMEOUT, got " + resp.Status)
+	}
+	if srv.InFlight() != 1 {
+		t.Error("Handler still running after the timeout should be in flight, got " + strconv.FormatInt(srv.InFlight(), 10))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Stop(ctx); err != nil {
+		t.Fatal("Stop should drain before deadline: " + err.Error())
+	}
+	if !finished.Load() {
+		t.Error("Stop should wait for the handler still running after the timeout")
+	}
+}
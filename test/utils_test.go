@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/phnam/go-protocol-adapter/server"
+)
+
+// freePort asks the OS for an unused TCP port so tests can run side by side.
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal("Cannot allocate free port: " + err.Error())
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// startServer exposes srv on a free port, starts it and waits until it accepts connections.
+// The server is stopped when the test finishes. Returns the address to connect to.
+func startServer(t *testing.T, srv server.Server) string {
+	port := freePort(t)
+	address := "localhost:" + strconv.Itoa(port)
+	srv.Expose(port)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go srv.Start(&wg)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Stop(ctx)
+	})
+
+	for i := 0; i < 100; i++ {
+		con, err := net.Dial("tcp", address)
+		if err == nil {
+			con.Close()
+			return address
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Server didn't start at " + address)
+	return ""
+}
+
+// connProxy forwards TCP connections to a target address and counts the connections it accepted
+// and the bytes the target sent.
+type connProxy struct {
+	address  string
+	lock     sync.Mutex
+	accepted int
+	conns    []net.Conn
+	silenced []*atomic.Bool
+	received atomic.Int64
+}
+
+// startProxy starts a connProxy in front of target. The proxy is closed when the test finishes.
+func startProxy(t *testing.T, target string) *connProxy {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal("Cannot start proxy: " + err.Error())
+	}
+	t.Cleanup(func() { l.Close() })
+
+	proxy := &connProxy{address: l.Addr().String()}
+	go func() {
+		for {
+			con, err := l.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				con.Close()
+				continue
+			}
+			silenced := &atomic.Bool{}
+			proxy.lock.Lock()
+			proxy.accepted++
+			proxy.conns = append(proxy.conns, con, upstream)
+			proxy.silenced = append(proxy.silenced, silenced)
+			proxy.lock.Unlock()
+			go func() { forward(upstream, con, silenced); upstream.Close() }()
+			go func() { forward(&countingWriter{con, &proxy.received}, upstream, silenced); con.Close() }()
+		}
+	}()
+	return proxy
+}
+
+// count returns the number of connections accepted so far.
+func (p *connProxy) count() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.accepted
+}
+
+// bytesReceived returns the number of bytes sent by the target so far.
+func (p *connProxy) bytesReceived() int64 {
+	return p.received.Load()
+}
+
+// countingWriter counts the bytes written to the wrapped writer.
+type countingWriter struct {
+	io.Writer
+	count *atomic.Int64
+}
+
+// Write implements io.Writer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.count.Add(int64(n))
+	return n, err
+}
+
+// dropAll closes every proxied connection, simulating a network failure.
+func (p *connProxy) dropAll() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, con := range p.conns {
+		con.Close()
+	}
+	p.conns = nil
+}
+
+// silenceAll stops forwarding data on every proxied connection without closing them,
+// simulating a peer that vanished silently, e.g. behind a firewall dropping the connection.
+// New connections are forwarded normally.
+func (p *connProxy) silenceAll() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, silenced := range p.silenced {
+		silenced.Store(true)
+	}
+}
+
+// forward copies src to dst until src fails, discarding the data once silenced.
+func forward(dst io.Writer, src io.Reader, silenced *atomic.Bool) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 && !silenced.Load() {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -30,11 +30,14 @@ var _ = regexp.MatchString
 type Status int64
 const (
 	Status_OK           Status = 200
+	Status_PARTIAL_SUCCESS Status = 207
 	Status_INVALID      Status = 400
 	Status_UNAUTHORIZED Status = 401
 	Status_FORBIDDEN    Status = 403
 	Status_NOT_FOUND    Status = 404
 	Status_EXISTED      Status = 409
+	Status_PRECONDITION_FAILED Status = 412
+	Status_TIMEOUT      Status = 504
 	Status_ERROR        Status = 500
 	Status_REDIRECTED   Status = 302
 )
@@ -42,11 +45,14 @@ const (
 func (p Status) String() string {
 	switch p {
 	case Status_OK: return "OK"
+	case Status_PARTIAL_SUCCESS: return "PARTIAL_SUCCESS"
 	case Status_INVALID: return "INVALID"
 	case Status_UNAUTHORIZED: return "UNAUTHORIZED"
 	case Status_FORBIDDEN: return "FORBIDDEN"
 	case Status_NOT_FOUND: return "NOT_FOUND"
 	case Status_EXISTED: return "EXISTED"
+	case Status_PRECONDITION_FAILED: return "PRECONDITION_FAILED"
+	case Status_TIMEOUT: return "TIMEOUT"
 	case Status_ERROR: return "ERROR"
 	case Status_REDIRECTED: return "REDIRECTED"
 	}
@@ -56,11 +62,14 @@ func (p Status) String() string {
 func StatusFromString(s string) (Status, error) {
 	switch s {
 	case "OK": return Status_OK, nil
+	case "PARTIAL_SUCCESS": return Status_PARTIAL_SUCCESS, nil
 	case "INVALID": return Status_INVALID, nil
 	case "UNAUTHORIZED": return Status_UNAUTHORIZED, nil
 	case "FORBIDDEN": return Status_FORBIDDEN, nil
 	case "NOT_FOUND": return Status_NOT_FOUND, nil
 	case "EXISTED": return Status_EXISTED, nil
+	case "PRECONDITION_FAILED": return Status_PRECONDITION_FAILED, nil
+	case "TIMEOUT": return Status_TIMEOUT, nil
 	case "ERROR": return Status_ERROR, nil
 	case "REDIRECTED": return Status_REDIRECTED, nil
 	}
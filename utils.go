@@ -1,7 +1,9 @@
 package sdk
 
 import (
+	"crypto/rand"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
@@ -24,3 +26,15 @@ func ConvertToObjectSlice[T any](jsonString string) []T {
 	}
 	return result
 }
+
+// NewUUID generates a random (version 4) UUID string.
+// It panics if the random source fails, like uuid.New, rather than returning a predictable id.
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("cannot generate UUID: " + err.Error())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}